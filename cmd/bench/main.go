@@ -0,0 +1,113 @@
+package main
+
+import (
+	"caching-strategies/internal/accesstrace"
+	"caching-strategies/internal/bench"
+	"caching-strategies/internal/chaos"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	cfg := bench.DefaultConfig()
+
+	strategies := flag.String("strategies", strings.Join(cfg.Strategies, ","), "comma separated strategies to run")
+	format := flag.String("format", bench.FormatTable, "report format: "+strings.Join(bench.Formats, ", "))
+	flag.IntVar(&cfg.App.CacheSize, "cache-size", cfg.App.CacheSize, "cache size in orders")
+	flag.DurationVar(&cfg.App.CacheTTL, "ttl", cfg.App.CacheTTL, "cache TTL")
+	flag.IntVar(&cfg.App.RefreshChSize, "refresh-ch-size", cfg.App.RefreshChSize, "refresh-ahead queue size")
+	flag.IntVar(&cfg.App.BreakerThreshold, "breaker-threshold", cfg.App.BreakerThreshold,
+		"consecutive failed loads opening circuit breaker, 0 disables it")
+	flag.DurationVar(&cfg.App.BreakerOpenTimeout, "breaker-open-timeout", cfg.App.BreakerOpenTimeout, "open breaker timeout before probing")
+	flag.IntVar(&cfg.App.RepoConcurrency, "repo-concurrency", cfg.App.RepoConcurrency, "concurrent repository loads, 0 is unlimited")
+	flag.IntVar(&cfg.App.RepoQueue, "repo-queue", cfg.App.RepoQueue, "loads waiting for repository, the rest are shed")
+	flag.DurationVar(&cfg.App.StaleTTL, "stale-ttl", cfg.App.StaleTTL, "how long orders are served stale when repository fails, 0 disables it")
+	flag.Float64Var(&cfg.App.HedgeBudget, "hedge-budget", cfg.App.HedgeBudget, "share of repository loads which may be hedged, 0 disables hedging")
+	flag.Float64Var(&cfg.App.HedgeQuantile, "hedge-quantile", cfg.App.HedgeQuantile, "quantile of load latency after which the load is hedged")
+	flag.IntVar(&cfg.App.HedgeBatch, "hedge-batch", cfg.App.HedgeBatch, "IDs per hedged load, slow parts of a batch are hedged separately")
+	flag.IntVar(&cfg.App.LoaderBatch, "loader-batch", cfg.App.LoaderBatch, "max IDs of merged repository load, 0 disables merging of concurrent loads")
+	flag.IntVar(&cfg.App.LoaderInFlight, "loader-in-flight", cfg.App.LoaderInFlight, "concurrent merged loads, the rest are collected into batches")
+	flag.DurationVar(&cfg.App.LoaderWindow, "loader-window", cfg.App.LoaderWindow, "how long collected IDs wait for a busy repository")
+	flag.IntVar(&cfg.Keys, "keys", cfg.Keys, "number of orders in repository")
+	cfg.Workload.RegisterFlags(flag.CommandLine)
+	flag.IntVar(&cfg.BatchSize, "batch", cfg.BatchSize, "orders per read")
+	flag.IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "concurrent clients")
+	flag.Float64Var(&cfg.ReadRatio, "read-ratio", cfg.ReadRatio, "share of reads in range [0, 1], the rest are writes")
+	flag.DurationVar(&cfg.Duration, "duration", cfg.Duration, "run duration per strategy")
+	flag.Int64Var(&cfg.Seed, "seed", cfg.Seed, "random seed")
+	flag.StringVar(&cfg.Trace, "trace", cfg.Trace, "access trace to replay instead of synthetic workload")
+	traceFormat := flag.String("trace-format", "", "format of foreign trace to import: "+strings.Join(accesstrace.Formats, ", "))
+	flag.Float64Var(&cfg.ReplaySpeed, "replay-speed", cfg.ReplaySpeed, "trace speed multiplier, 0 replays records back to back")
+	cfg.Faults.RegisterFlags(flag.CommandLine)
+	flag.Func("outages", "repository outage windows since the start of the run, e.g. 1s-2s,5s-7s", func(s string) (err error) {
+		cfg.Outages, err = chaos.ParseWindows(s)
+		return err
+	})
+	flag.Parse()
+
+	cfg.Strategies = strings.Split(*strategies, ",")
+	if !slices.Contains(bench.Formats, *format) {
+		log.Fatal().Str("format", *format).Strs("formats", bench.Formats).Msg("unknown report format")
+	}
+
+	// strategies log every cache update and refresh queue overflow, keep report readable
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	if err := run(cfg, *format, *traceFormat); err != nil {
+		log.Fatal().Err(err).Msg("benchmark error")
+	}
+}
+
+func run(cfg bench.Config, format, traceFormat string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.Trace != "" && traceFormat != "" {
+		imported, err := importTrace(cfg.Trace, traceFormat)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(imported)
+		cfg.Trace = imported
+	}
+
+	results, err := bench.Run(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	return bench.Write(os.Stdout, format, results)
+}
+
+// importTrace converts foreign trace to a temporary file and returns its path
+func importTrace(path, format string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp("", "trace-*.jsonl")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	count, err := accesstrace.Import(format, in, accesstrace.NewWriter(out))
+	if err != nil {
+		_ = os.Remove(out.Name())
+		return "", err
+	}
+	if count == 0 {
+		_ = os.Remove(out.Name())
+		return "", fmt.Errorf("trace %s is empty", path)
+	}
+	return out.Name(), nil
+}
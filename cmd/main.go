@@ -1,53 +1,142 @@
 package main
 
 import (
-	"caching-strategies/internal/cache_implementations/refresh_ahead"
-	repo "caching-strategies/internal/repository"
-	"caching-strategies/internal/repository/entity/order"
-	order_usecase_with_cache_refresh "caching-strategies/internal/usecases/3_refresh_ahead"
-	"caching-strategies/internal/watcher"
+	"caching-strategies/internal/app"
+	"caching-strategies/internal/config"
+	"caching-strategies/internal/grpcapi"
+	"caching-strategies/internal/grpcapi/orderpb"
+	"caching-strategies/internal/server"
 	"context"
-	"github.com/hashicorp/golang-lru/v2/expirable"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
-const (
-	refreshChSize = 1000
-)
-
 func main() {
-	ctx := context.Background()
-	repository := repo.New()
-	ttl := time.Millisecond * 100
-	refreshCh := make(chan uint64, refreshChSize)
-	defer close(refreshCh)
-
-	// make cache with 100ms TTL and 5 max keys
-	cache := expirable.NewLRU[uint64, *order.Order](5, nil, ttl)
-	//asideCache := cache_aside.New(cache)
-	//readWriteThroughCache := read_write_through.New(cache, repository)
-	refreshAheadCache := refresh_ahead.New(cache, repository, ttl, refreshCh)
-
-	// start cache-refresh watcher
-	cacheWatcher := watcher.New(cache, repository, refreshCh, ttl)
-	go cacheWatcher.Start(ctx)
-
-	//orderUsecase := order_usecase.New(repository)
-	//orderUsecaseWithCacheAside := order_usecase_with_cache_aside.New(repository, asideCache)
-	//orderUsecaseWithCacheThrough := order_usecase_with_cache_through.New(readWriteThroughCache)
-	orderUsecaseWithCacheRefresh := order_usecase_with_cache_refresh.New(refreshAheadCache)
-
-	//orderUsecase.Save(ctx, &order.Order{ID: 1})
-	//orderUsecase.Get(ctx, []uint64{1})
-	//
-	//orderUsecaseWithCacheAside.Save(ctx, &order.Order{ID: 2})
-	//orderUsecaseWithCacheAside.Get(ctx, []uint64{2})
-	//
-	//orderUsecaseWithCacheThrough.Save(ctx, &order.Order{ID: 3})
-	//orderUsecaseWithCacheThrough.Get(ctx, []uint64{3})
-
-	orderUsecaseWithCacheRefresh.Save(ctx, &order.Order{ID: 4})
-	orderUsecaseWithCacheRefresh.Get(ctx, []uint64{4})
-
-	<-ctx.Done()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("config loading error")
+	}
+
+	application, err := app.New(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("app creating error")
+	}
+
+	// warm start: orders of the last snapshot still valid in repository are cached before serving,
+	// in-memory repository is empty after restart, so every entry would be skipped as missing
+	switch {
+	case application.Snapshots == nil:
+	case cfg.Repo == config.RepoMemory:
+		log.Warn().Str("path", cfg.SnapshotPath).Msg("in-memory repository is empty after restart, snapshot isn't restored")
+	default:
+		stats, err := application.Snapshots.Restore(ctx, application.Repo)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			log.Info().Str("path", cfg.SnapshotPath).Msg("no cache snapshot, cold start")
+		case err != nil:
+			log.Err(err).Msg("cache snapshot restore error, cold start")
+		default:
+			log.Info().
+				Int("restored", stats.Restored).
+				Int("updated", stats.Updated).
+				Int("missing", stats.Missing).
+				Int("expired", stats.Expired).
+				Msg("cache warmed from snapshot")
+		}
+	}
+
+	// preload the most accessed orders, serving waits for it at most WARMUP_WAIT
+	if application.Warmer != nil {
+		go func() {
+			stats, err := application.Warmer.Run(ctx)
+			if err != nil {
+				log.Err(err).Msg("cache warmup error")
+			}
+			log.Info().
+				Int("keys", stats.Keys).
+				Int("cached", stats.Cached).
+				Int("loaded", stats.Loaded).
+				Int("missing", stats.Missing).
+				Int("failed", stats.Failed).
+				Msg("cache warmup finished")
+		}()
+
+		select {
+		case <-application.Warmer.Ready():
+		case <-time.After(cfg.WarmupWait):
+			log.Warn().Interface("stats", application.Warmer.Stats()).Msg("serving before cache is warm")
+		case <-ctx.Done():
+		}
+	}
+
+	// start cache-refresh watcher if strategy needs it
+	application.Start(ctx)
+
+	srv := &http.Server{
+		Addr:    cfg.HTTPAddr,
+		Handler: server.New(application.Usecase, application.Metrics),
+	}
+
+	grpcService := grpcapi.New(application.Usecase)
+	grpcServer := grpc.NewServer()
+	orderpb.RegisterOrderServiceServer(grpcServer, grpcService)
+
+	serveErr := make(chan error, 2)
+	go func() {
+		log.Info().Str("addr", cfg.HTTPAddr).Str("strategy", cfg.Strategy).Msg("http server started")
+		serveErr <- srv.ListenAndServe()
+	}()
+	go func() {
+		lis, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			serveErr <- err
+			return
+		}
+		log.Info().Str("addr", cfg.GRPCAddr).Str("strategy", cfg.Strategy).Msg("grpc server started")
+		serveErr <- grpcServer.Serve(lis)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Err(err).Msg("http server error")
+		}
+	}
+
+	// stop accepting requests first, so nothing is sent to the watcher after it's stopped
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Err(err).Msg("http server shutdown error")
+	}
+	grpcService.Close()
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+	}
+	// refreshes queued by the last requests are finished within the same shutdown timeout
+	if _, err := application.Stop(shutdownCtx); err != nil {
+		log.Err(err).Msg("background workers stop error")
+	}
+
+	log.Info().Msg("stopped")
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"caching-strategies/internal/accesstrace"
+	"caching-strategies/internal/mrc"
+	"caching-strategies/internal/workload"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+func main() {
+	spec := workload.DefaultSpec(10000)
+
+	policies := flag.String("policies", strings.Join(mrc.Policies, ","), "comma separated eviction policies")
+	maxSize := flag.Int("max-size", 1000, "the biggest simulated cache size")
+	points := flag.Int("points", 20, "number of cache sizes up to max size")
+	sampleRate := flag.Float64("sample-rate", 1, "share of keys simulated, e.g. 0.01 for huge key spaces")
+	keys := flag.Uint64("keys", spec.Keys, "number of keys of synthetic workload")
+	requests := flag.Int("requests", 1000000, "number of requests of synthetic workload")
+	seed := flag.Int64("seed", 1, "random seed of synthetic workload")
+	trace := flag.String("trace", "", "access trace to simulate instead of synthetic workload")
+	traceFormat := flag.String("trace-format", "", "format of foreign trace: "+strings.Join(accesstrace.Formats, ", "))
+	spec.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	simulator, err := mrc.New(mrc.Config{
+		Policies:   strings.Split(*policies, ","),
+		Sizes:      mrc.LinearSizes(*maxSize, *points),
+		SampleRate: *sampleRate,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("simulator creating error")
+	}
+
+	if *trace != "" {
+		err = replayTrace(simulator, *trace, *traceFormat)
+	} else {
+		spec.Keys = *keys
+		err = generate(simulator, spec, *requests, *seed)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("simulation error")
+	}
+
+	if err := mrc.WriteCSV(os.Stdout, simulator.Curves()); err != nil {
+		log.Fatal().Err(err).Msg("csv writing error")
+	}
+}
+
+func generate(simulator *mrc.Simulator, spec workload.Spec, requests int, seed int64) error {
+	g, err := spec.New(rand.New(rand.NewSource(seed)))
+	if err != nil {
+		return err
+	}
+	for i := 0; i < requests; i++ {
+		simulator.Access(g.Next())
+	}
+	return nil
+}
+
+// replayTrace accesses IDs of gets and saves, deletes are skipped
+func replayTrace(simulator *mrc.Simulator, path, format string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if format != "" {
+		pr, pw := io.Pipe()
+		go func() {
+			_, err := accesstrace.Import(format, f, accesstrace.NewWriter(pw))
+			_ = pw.CloseWithError(err)
+		}()
+		defer pr.Close()
+		r = pr
+	}
+
+	reader := accesstrace.NewReader(r)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("trace reading error: %w", err)
+		}
+		if record.Op == accesstrace.OpDelete {
+			continue
+		}
+		for _, ID := range record.IDs {
+			simulator.Access(ID)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package accesstrace
+
+import (
+	"bytes"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	order_usecase "caching-strategies/internal/usecases/0_without_cache"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, r io.Reader) []Record {
+	t.Helper()
+
+	var records []Record
+	reader := NewReader(r)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	recorder := NewRecorder(order_usecase.New(repo.New()), w)
+
+	if err := recorder.Save(ctx, &order.Order{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.Get(ctx, []uint64{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := recorder.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Delete(ctx, 1); err == nil {
+		t.Fatal("expected error for deleting missing order")
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	trace := buf.String()
+	records := readAll(t, strings.NewReader(trace))
+	ops := make([]string, 0, len(records))
+	for _, record := range records {
+		ops = append(ops, record.Op)
+	}
+	if !reflect.DeepEqual(ops, []string{OpSave, OpGet, OpDelete, OpDelete}) {
+		t.Fatalf("unexpected ops: %v", ops)
+	}
+	if !reflect.DeepEqual(records[1].IDs, []uint64{1, 2}) {
+		t.Fatalf("unexpected get IDs: %v", records[1].IDs)
+	}
+
+	for _, speed := range []float64{1, 0} {
+		stats, err := Replay(ctx, NewReader(strings.NewReader(trace)), order_usecase.New(repo.New()), ReplayOptions{
+			Speed:       speed,
+			Concurrency: 1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Gets != 1 || stats.Saves != 1 || stats.Deletes != 2 || stats.Errors != 1 {
+			t.Fatalf("speed %g: unexpected stats %+v", speed, stats)
+		}
+		// original speed keeps the pause before deletes
+		if paused := stats.Elapsed >= 50*time.Millisecond; paused != (speed == 1) {
+			t.Fatalf("speed %g: unexpected elapsed %s", speed, stats.Elapsed)
+		}
+	}
+}
+
+func TestReplayCancel(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := w.Write(Record{Time: start.Add(time.Duration(i) * time.Hour), Op: OpGet, IDs: []uint64{1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	stats, err := Replay(ctx, NewReader(&buf), order_usecase.New(repo.New()), ReplayOptions{Speed: 1, Concurrency: 2})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if stats.Gets != 1 {
+		t.Fatalf("expected only the first record to be replayed, got %+v", stats)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	for _, trace := range []string{
+		`{"op":"get","ids":[]}`,
+		`{"op":"save","ids":[1,2]}`,
+		`{"op":"list","ids":[1]}`,
+		`not json`,
+	} {
+		if _, err := NewReader(strings.NewReader(trace)).Read(); err == nil || errors.Is(err, io.EOF) {
+			t.Fatalf("expected error for %s, got %v", trace, err)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	arc := "0 3 0 1\n\n7 1 0 2\n"
+	var buf bytes.Buffer
+	count, err := Import(FormatARC, strings.NewReader(arc), NewWriter(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := readAll(t, &buf)
+	if count != 2 || len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d, %d", count, len(records))
+	}
+	if !reflect.DeepEqual(records[0].IDs, []uint64{0, 1, 2}) || !records[0].Time.IsZero() {
+		t.Fatalf("unexpected arc record: %+v", records[0])
+	}
+
+	twitter := strings.Join([]string{
+		"10,a,1,10,1,get,0",
+		"10,b,1,10,1,set,60",
+		"11,a,1,10,2,gets,0",
+		"12,b,1,10,2,delete,0",
+	}, "\n")
+	buf.Reset()
+	if _, err := Import(FormatTwitter, strings.NewReader(twitter), NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	records = readAll(t, &buf)
+	expected := []Record{
+		{Time: time.Unix(10, 0).UTC(), Op: OpGet, IDs: []uint64{0}},
+		{Time: time.Unix(10, 0).UTC(), Op: OpSave, IDs: []uint64{1}},
+		{Time: time.Unix(11, 0).UTC(), Op: OpGet, IDs: []uint64{0}},
+		{Time: time.Unix(12, 0).UTC(), Op: OpDelete, IDs: []uint64{1}},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Fatalf("unexpected twitter records: %+v", records)
+	}
+
+	for format, trace := range map[string]string{
+		FormatTwitter: "10,a,1,10,1,flush_all,0",
+		"lirs":        "",
+	} {
+		if _, err := Import(format, strings.NewReader(trace), NewWriter(io.Discard)); err == nil {
+			t.Fatalf("expected error for %s trace %q", format, trace)
+		}
+	}
+	for _, trace := range []string{
+		"x 1 0 1",
+		"0 0 0 1",
+		// hostile number of blocks isn't allocated
+		"0 18446744073709551615 0 1",
+		// blocks past the last ID
+		"18446744073709551615 2 0 1",
+	} {
+		if _, err := Import(FormatARC, strings.NewReader(trace), NewWriter(io.Discard)); err == nil {
+			t.Fatalf("expected error for arc trace %q", trace)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package accesstrace
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatARC     = "arc"
+	FormatTwitter = "twitter"
+)
+
+// Formats are all supported formats of foreign traces
+var Formats = []string{FormatARC, FormatTwitter}
+
+// maxARCBlocks limits blocks of one ARC request, so malformed line doesn't allocate unbounded batch
+// and the record fits into line of Reader
+const maxARCBlocks = 1 << 16
+
+// Import converts foreign trace of the format and returns the number of records written
+func Import(format string, r io.Reader, w *Writer) (int, error) {
+	switch format {
+	case FormatARC:
+		return ImportARC(r, w)
+	case FormatTwitter:
+		return ImportTwitter(r, w)
+	default:
+		return 0, fmt.Errorf("unknown trace format %q, expected one of %v", format, Formats)
+	}
+}
+
+// ImportARC converts trace of ARC paper format ("start blocks ignored request" per line)
+// to gets of blocks [start, start+blocks), ARC traces have no time, so records are replayed back to back
+func ImportARC(r io.Reader, w *Writer) (int, error) {
+	scanner := bufio.NewScanner(r)
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return count, fmt.Errorf("line %d: expected at least 2 fields, got %d", line, len(fields))
+		}
+
+		start, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return count, fmt.Errorf("line %d: invalid start block: %w", line, err)
+		}
+		blocks, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil || blocks == 0 {
+			return count, fmt.Errorf("line %d: invalid number of blocks %q", line, fields[1])
+		}
+		if blocks > maxARCBlocks {
+			return count, fmt.Errorf("line %d: %d blocks exceed the limit of %d", line, blocks, maxARCBlocks)
+		}
+		if start+blocks < start {
+			return count, fmt.Errorf("line %d: blocks [%d, %d+%d) overflow uint64", line, start, start, blocks)
+		}
+
+		IDs := make([]uint64, 0, blocks)
+		for ID := start; ID < start+blocks; ID++ {
+			IDs = append(IDs, ID)
+		}
+		if err := w.Write(Record{Op: OpGet, IDs: IDs}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, w.Flush()
+}
+
+// twitterOps maps memcached commands of Twitter cache-trace to usecase calls
+var twitterOps = map[string]string{
+	"get":     OpGet,
+	"gets":    OpGet,
+	"set":     OpSave,
+	"add":     OpSave,
+	"replace": OpSave,
+	"cas":     OpSave,
+	"append":  OpSave,
+	"prepend": OpSave,
+	"incr":    OpSave,
+	"decr":    OpSave,
+	"delete":  OpDelete,
+}
+
+// ImportTwitter converts Twitter cache-trace CSV
+// ("timestamp,key,key size,value size,client id,operation,TTL"),
+// anonymized keys get dense IDs in order of first appearance
+func ImportTwitter(r io.Reader, w *Writer) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	IDs := make(map[string]uint64)
+	count := 0
+	for line := 1; ; line++ {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		if len(fields) < 6 {
+			return count, fmt.Errorf("line %d: expected at least 6 fields, got %d", line, len(fields))
+		}
+
+		ts, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return count, fmt.Errorf("line %d: invalid timestamp: %w", line, err)
+		}
+		op, ok := twitterOps[fields[5]]
+		if !ok {
+			return count, fmt.Errorf("line %d: unknown operation %q", line, fields[5])
+		}
+
+		ID, ok := IDs[fields[1]]
+		if !ok {
+			ID = uint64(len(IDs))
+			IDs[fields[1]] = ID
+		}
+
+		if err := w.Write(Record{Time: time.Unix(ts, 0).UTC(), Op: op, IDs: []uint64{ID}}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, w.Flush()
+}
@@ -0,0 +1,108 @@
+package accesstrace
+
+import (
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+	"os"
+	"time"
+)
+
+// flushInterval bounds records lost on crash of the service recording trace to file
+const flushInterval = time.Second
+
+type UsecaseI interface {
+	Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
+	Save(ctx context.Context, order *order.Order) error
+	Delete(ctx context.Context, ID uint64) error
+}
+
+// Recorder is a usecase decorator writing every call to trace,
+// trace errors are logged and don't fail calls.
+// It's a lifecycle.Worker flushing trace every flushInterval and on stop, when trace file is closed.
+type Recorder struct {
+	usecase UsecaseI
+	w       *Writer
+	// file is closed on stop, nil if trace writer is owned by the caller
+	file io.Closer
+}
+
+func NewRecorder(usecase UsecaseI, w *Writer) *Recorder {
+	return &Recorder{usecase: usecase, w: w}
+}
+
+// OpenRecorder creates trace file at path, existing trace is replaced
+func OpenRecorder(usecase UsecaseI, path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("access trace: %w", err)
+	}
+	return &Recorder{usecase: usecase, w: NewWriter(f), file: f}, nil
+}
+
+func (r *Recorder) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
+	r.record(OpGet, IDs)
+	return r.usecase.Get(ctx, IDs)
+}
+
+func (r *Recorder) Save(ctx context.Context, order *order.Order) error {
+	r.record(OpSave, []uint64{order.ID})
+	return r.usecase.Save(ctx, order)
+}
+
+func (r *Recorder) Delete(ctx context.Context, ID uint64) error {
+	r.record(OpDelete, []uint64{ID})
+	return r.usecase.Delete(ctx, ID)
+}
+
+// RemainingTTL forwards to the wrapped usecase, so recording doesn't hide its cache TTL from server,
+// false if the usecase has no cache
+func (r *Recorder) RemainingTTL(ID uint64) (time.Duration, bool) {
+	provider, ok := r.usecase.(interface {
+		RemainingTTL(ID uint64) (time.Duration, bool)
+	})
+	if !ok {
+		return 0, false
+	}
+	return provider.RemainingTTL(ID)
+}
+
+// Run flushes trace every flushInterval until stop
+func (r *Recorder) Run(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.w.Flush(); err != nil {
+				log.Err(err).Msg("trace flushing error")
+			}
+		}
+	}
+}
+
+// Drain flushes trace and closes its file, nothing is queued so nothing is dropped
+func (r *Recorder) Drain(context.Context) (dropped int) {
+	if err := r.w.Flush(); err != nil {
+		log.Err(err).Msg("trace flushing error")
+	}
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			log.Err(err).Msg("trace closing error")
+		}
+	}
+	return 0
+}
+
+func (r *Recorder) record(op string, IDs []uint64) {
+	if err := r.w.Write(Record{Time: time.Now(), Op: op, IDs: IDs}); err != nil {
+		log.Err(err).Str("op", op).Msg("trace writing error")
+	}
+}
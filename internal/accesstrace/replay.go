@@ -0,0 +1,138 @@
+package accesstrace
+
+import (
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type ReplayOptions struct {
+	// Speed scales time between records: 1 is original speed, 2 is twice faster,
+	// 0 replays records back to back
+	Speed float64
+	// Concurrency limits calls in flight, records wait for a free slot and lag behind the trace
+	Concurrency int
+}
+
+type Stats struct {
+	Gets    int `json:"gets"`
+	Saves   int `json:"saves"`
+	Deletes int `json:"deletes"`
+	// Errors are failed calls, e.g. deletes of orders that don't exist
+	Errors int `json:"errors"`
+	// MaxLag is the biggest delay of a call behind its scaled trace time
+	MaxLag  time.Duration `json:"max_lag_ns"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+}
+
+func (s *Stats) add(other Stats) {
+	s.Gets += other.Gets
+	s.Saves += other.Saves
+	s.Deletes += other.Deletes
+	s.Errors += other.Errors
+}
+
+// Replay calls usecase for every record of trace keeping time between records scaled by speed,
+// saves write orders with recorded IDs only
+func Replay(ctx context.Context, r *Reader, uc UsecaseI, opts ReplayOptions) (Stats, error) {
+	if opts.Speed < 0 {
+		return Stats{}, fmt.Errorf("speed must not be negative, got %g", opts.Speed)
+	}
+	if opts.Concurrency <= 0 {
+		return Stats{}, fmt.Errorf("concurrency must be positive, got %d", opts.Concurrency)
+	}
+
+	records := make(chan Record)
+	workerStats := make([]Stats, opts.Concurrency)
+	var wg sync.WaitGroup
+	for i := range workerStats {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for record := range records {
+				call(ctx, uc, record, &workerStats[i])
+			}
+		}(i)
+	}
+
+	start := time.Now()
+	maxLag, err := dispatch(ctx, r, records, opts.Speed, start)
+	close(records)
+	wg.Wait()
+
+	stats := Stats{MaxLag: maxLag, Elapsed: time.Since(start)}
+	for _, s := range workerStats {
+		stats.add(s)
+	}
+	return stats, err
+}
+
+// dispatch sends records to workers when they are due and returns the biggest lag
+func dispatch(ctx context.Context, r *Reader, records chan<- Record, speed float64, start time.Time) (time.Duration, error) {
+	var (
+		base   time.Time
+		maxLag time.Duration
+	)
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return maxLag, nil
+		}
+		if err != nil {
+			return maxLag, err
+		}
+
+		timed := speed > 0 && !record.Time.IsZero()
+		var due time.Time
+		if timed {
+			if base.IsZero() {
+				base = record.Time
+			}
+			due = start.Add(time.Duration(float64(record.Time.Sub(base)) / speed))
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return maxLag, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return maxLag, ctx.Err()
+		case records <- record:
+		}
+
+		if timed {
+			if lag := time.Since(due); lag > maxLag {
+				maxLag = lag
+			}
+		}
+	}
+}
+
+func call(ctx context.Context, uc UsecaseI, record Record, stats *Stats) {
+	var err error
+	switch record.Op {
+	case OpGet:
+		stats.Gets++
+		_, err = uc.Get(ctx, record.IDs)
+	case OpSave:
+		stats.Saves++
+		err = uc.Save(ctx, &order.Order{ID: record.IDs[0]})
+	case OpDelete:
+		stats.Deletes++
+		err = uc.Delete(ctx, record.IDs[0])
+	}
+	if err != nil {
+		stats.Errors++
+	}
+}
@@ -0,0 +1,105 @@
+package accesstrace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	OpGet    = "get"
+	OpSave   = "save"
+	OpDelete = "delete"
+)
+
+// Record is a single usecase call, trace is a file of records as JSON lines in order of calls
+type Record struct {
+	// Time of the call, records without time are replayed back to back
+	Time time.Time `json:"ts"`
+	Op   string    `json:"op"`
+	IDs  []uint64  `json:"ids"`
+}
+
+// Writer writes records as JSON lines, safe for concurrent use
+type Writer struct {
+	mu  sync.Mutex
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewWriter(w io.Writer) *Writer {
+	bw := bufio.NewWriter(w)
+	return &Writer{bw: bw, enc: json.NewEncoder(bw)}
+}
+
+func (w *Writer) Write(record Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.enc.Encode(record)
+}
+
+// Flush writes buffered records to the underlying writer
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.bw.Flush()
+}
+
+// Reader reads records written by Writer
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	// batches may be long
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &Reader{scanner: scanner}
+}
+
+// Read returns the next record, io.EOF when trace is over
+func (r *Reader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return Record{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		if err := record.validate(); err != nil {
+			return Record{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return record, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+func (r Record) validate() error {
+	switch r.Op {
+	case OpGet:
+		if len(r.IDs) == 0 {
+			return fmt.Errorf("get without ids")
+		}
+	case OpSave, OpDelete:
+		if len(r.IDs) != 1 {
+			return fmt.Errorf("%s expects one id, got %d", r.Op, len(r.IDs))
+		}
+	default:
+		return fmt.Errorf("unknown op %q", r.Op)
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package app
+
+import (
+	"caching-strategies/internal/accesstrace"
+	"caching-strategies/internal/cache_implementations/cache_aside"
+	"caching-strategies/internal/cache_implementations/options"
+	"caching-strategies/internal/cache_implementations/page_cache"
+	"caching-strategies/internal/cache_implementations/query_cache"
+	"caching-strategies/internal/cache_implementations/read_write_through"
+	"caching-strategies/internal/cache_implementations/refresh_ahead"
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/config"
+	"caching-strategies/internal/dataloader"
+	"caching-strategies/internal/hedge"
+	"caching-strategies/internal/invalidation"
+	"caching-strategies/internal/lifecycle"
+	"caching-strategies/internal/lru"
+	"caching-strategies/internal/metrics"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/repository/filerepo"
+	"caching-strategies/internal/repository/sqliterepo"
+	"caching-strategies/internal/resilience"
+	"caching-strategies/internal/snapshot"
+	order_usecase "caching-strategies/internal/usecases/0_without_cache"
+	order_usecase_with_cache_aside "caching-strategies/internal/usecases/1_cache_aside"
+	order_usecase_with_cache_through "caching-strategies/internal/usecases/2_read_write_through"
+	order_usecase_with_cache_refresh "caching-strategies/internal/usecases/3_refresh_ahead"
+	order_usecase_with_query_cache "caching-strategies/internal/usecases/4_query_cache"
+	"caching-strategies/internal/warmup"
+	"caching-strategies/internal/watcher"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+)
+
+type UsecaseI interface {
+	Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
+	Save(ctx context.Context, order *order.Order) error
+	Delete(ctx context.Context, ID uint64) error
+}
+
+// App wires repository, cache and usecase of the configured strategy
+type App struct {
+	Usecase UsecaseI
+	Metrics *metrics.Registry
+	Cache   *lru.LRU[uint64, *order.Order]
+	// Invalidation drops cached orders by tag, like all orders of a customer, and cascades to their dependents
+	Invalidation *invalidation.Registry
+	// Repo is the instrumented repository behind the usecase, writes to it bypass cache
+	Repo *metrics.Repo
+	// Breaker guards repository loads of read-through strategies, nil if disabled
+	Breaker *resilience.Breaker
+	// Hedge duplicates slow repository loads of strategies, nil if disabled
+	Hedge *hedge.Repo
+	// Loader merges concurrent repository loads of strategies, nil if disabled
+	Loader *dataloader.Loader
+	// Snapshots saves cache to file and restores it on warm start, nil if disabled or strategy has no cache
+	Snapshots *snapshot.Snapshotter
+	// Warmer preloads the most accessed orders on start, nil if disabled or strategy has no cache
+	Warmer *warmup.Warmer
+
+	// workers are background workers of the strategy, like refresh-ahead watcher
+	workers *lifecycle.Manager
+	// closeRepo closes persistent repository after workers are stopped, nil for in-memory one
+	closeRepo func() error
+}
+
+// Option configures App
+type Option func(*appOptions)
+
+type appOptions struct {
+	repository metrics.OrderRepoI
+}
+
+// WithRepository replaces in-memory repository, e.g. with a chaos decorator around it
+func WithRepository(r metrics.OrderRepoI) Option {
+	return func(o *appOptions) {
+		o.repository = r
+	}
+}
+
+func New(cfg config.Config, opts ...Option) (_ *App, err error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	o := appOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var closeRepo func() error
+	if o.repository == nil {
+		if o.repository, closeRepo, err = openRepository(cfg); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil && closeRepo != nil {
+				_ = closeRepo()
+			}
+		}()
+	}
+
+	registry := metrics.NewRegistry()
+	instrumented := metrics.NewRepo(o.repository, registry)
+	cacheMetrics := registry.CacheMetrics(cfg.Strategy)
+	invalidations := invalidation.New(invalidation.DefaultTags)
+	countEviction := metrics.EvictCallback[uint64, *order.Order](cacheMetrics)
+	cache := lru.NewLRU[uint64, *order.Order](
+		cfg.CacheSize,
+		func(ID uint64, value *order.Order) {
+			countEviction(ID, value)
+			invalidations.Evicted(ID, value)
+		},
+		cfg.CacheTTL,
+	)
+	// loads, including refresh-ahead watcher, don't write back orders deleted or saved while they were loading them,
+	// one registry is shared by strategy layers, so invalidated order cascades to queries and pages
+	cacheOpts := []options.Option{
+		options.WithMetrics(cacheMetrics),
+		options.WithGenerations(invalidation.NewGenerations()),
+		options.WithRegistry(invalidations),
+	}
+
+	a := &App{
+		Metrics:      registry,
+		Cache:        cache,
+		Invalidation: invalidations,
+		Repo:         instrumented,
+		workers:      lifecycle.NewManager(),
+
+		closeRepo: closeRepo,
+	}
+
+	// hedges are sent through instrumented repository, so their extra load is seen in repository metrics
+	var repository metrics.OrderRepoI = instrumented
+	if cfg.HedgeBudget > 0 {
+		policy := hedge.DefaultPolicy()
+		policy.Budget = cfg.HedgeBudget
+		policy.Quantile = cfg.HedgeQuantile
+		policy.BatchSize = cfg.HedgeBatch
+		var err error
+		if a.Hedge, err = hedge.New(instrumented, policy); err != nil {
+			return nil, err
+		}
+		repository = a.Hedge
+	}
+	// loads are merged before hedging, so hedge splits merged batch into its parts
+	if cfg.LoaderBatch > 0 {
+		var err error
+		if a.Loader, err = dataloader.New(repository, dataloader.Policy{
+			MaxBatch:    cfg.LoaderBatch,
+			MaxInFlight: cfg.LoaderInFlight,
+			Window:      cfg.LoaderWindow,
+		}); err != nil {
+			return nil, err
+		}
+		repository = a.Loader
+	}
+
+	resilienceOpts, err := a.resilience(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cacheOpts = append(cacheOpts, resilienceOpts...)
+
+	switch cfg.Strategy {
+	case config.StrategyWithoutCache:
+		a.Usecase = order_usecase.New(repository)
+	case config.StrategyCacheAside:
+		a.Usecase = order_usecase_with_cache_aside.New(repository, cache_aside.New(cache, cacheOpts...))
+	case config.StrategyReadWriteThrough:
+		a.Usecase = order_usecase_with_cache_through.New(read_write_through.New(cache, repository, cacheOpts...))
+	case config.StrategyRefreshAhead:
+		refreshQueue := lifecycle.NewQueue[uint64](cfg.RefreshChSize)
+		a.workers.Add("refresh_watcher", watcher.New(cache, repository, refreshQueue, cfg.CacheTTL, cacheOpts...))
+		a.Usecase = order_usecase_with_cache_refresh.New(
+			refresh_ahead.New(cache, repository, cfg.CacheTTL, refreshQueue, cacheOpts...),
+		)
+	case config.StrategyQueryCache:
+		readWriteThroughCache := read_write_through.New(cache, repository, cacheOpts...)
+		queryCache := query_cache.New(
+			lru.NewLRU[string, []uint64](cfg.CacheSize, nil, cfg.CacheTTL),
+			readWriteThroughCache,
+			repository,
+			cacheOpts...,
+		)
+		pageCache := page_cache.New(
+			lru.NewLRU[string, *page_cache.Page](cfg.CacheSize, nil, cfg.CacheTTL),
+			queryCache,
+			repository,
+			cacheOpts...,
+		)
+		a.Usecase = order_usecase_with_query_cache.New(pageCache, queryCache, pageCache)
+	default:
+		return nil, fmt.Errorf("unknown strategy %q", cfg.Strategy)
+	}
+
+	// added after strategy workers, so the snapshot on stop has their last refreshes
+	if cfg.SnapshotPath != "" && cfg.Strategy != config.StrategyWithoutCache {
+		a.Snapshots = snapshot.New(cfg.SnapshotPath, cache, cfg.SnapshotInterval)
+		a.workers.Add("cache_snapshot", a.Snapshots)
+	}
+
+	if err := a.warmup(cfg, cache, repository); err != nil {
+		return nil, err
+	}
+
+	// the outermost decorator records every call the service gets
+	if cfg.TracePath != "" {
+		recorder, err := accesstrace.OpenRecorder(a.Usecase, cfg.TracePath)
+		if err != nil {
+			return nil, err
+		}
+		a.Usecase = recorder
+		a.workers.Add("access_trace", recorder)
+	}
+
+	return a, nil
+}
+
+// resilience creates breaker, limiter and stale cache enabled by config
+func (a *App) resilience(cfg config.Config) ([]options.Option, error) {
+	var opts []options.Option
+
+	if cfg.BreakerThreshold > 0 {
+		a.Breaker = resilience.NewBreaker(resilience.BreakerConfig{
+			FailureThreshold: cfg.BreakerThreshold,
+			OpenTimeout:      cfg.BreakerOpenTimeout,
+			HalfOpenProbes:   cfg.BreakerProbes,
+		}, clock.Real())
+
+		state := a.Metrics.Gauge("circuit_breaker_state", "Circuit breaker state: 0 closed, 1 open, 2 half-open.", nil)
+		a.Breaker.OnStateChange(func(e resilience.Event) {
+			state.Set(float64(e.To))
+			a.Metrics.Counter("circuit_breaker_transitions_total", "Circuit breaker state transitions.",
+				metrics.Labels{"from": e.From.String(), "to": e.To.String()}).Inc()
+			log.Warn().Str("from", e.From.String()).Str("to", e.To.String()).Msg("circuit breaker state changed")
+		})
+		opts = append(opts, options.WithBreaker(a.Breaker))
+	}
+
+	if cfg.RepoConcurrency > 0 {
+		limiter, err := resilience.NewLimiter(cfg.RepoConcurrency, cfg.RepoQueue)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, options.WithLimiter(limiter))
+	}
+
+	if cfg.StaleTTL > 0 {
+		opts = append(opts, options.WithStale(lru.NewLRU[uint64, *order.Order](cfg.CacheSize, nil, cfg.StaleTTL)))
+	}
+
+	return opts, nil
+}
+
+// openRepository opens configured repository backend and returns its close, nil for in-memory one
+func openRepository(cfg config.Config) (metrics.OrderRepoI, func() error, error) {
+	switch cfg.Repo {
+	case config.RepoFile:
+		fileRepo, err := filerepo.Open(cfg.RepoPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fileRepo, fileRepo.Close, nil
+	case config.RepoSQLite:
+		sqliteRepo, err := sqliterepo.Open(context.Background(), cfg.RepoPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sqliteRepo, sqliteRepo.Close, nil
+	default:
+		return repo.New(), nil, nil
+	}
+}
+
+// warmup creates warmer and access counter enabled by config
+func (a *App) warmup(cfg config.Config, cache warmup.CacheInterface, repository warmup.OrderRepoI) error {
+	if cfg.WarmupCountsPath != "" {
+		counter := warmup.NewCounter(a.Usecase, cfg.WarmupCountsPath)
+		a.Usecase = counter
+		a.workers.Add("access_counts", counter)
+	}
+
+	if cfg.WarmupSource == "" || cfg.Strategy == config.StrategyWithoutCache {
+		return nil
+	}
+	source, err := warmup.ParseSource(cfg.WarmupSource)
+	if err != nil {
+		return err
+	}
+	// orders beyond cache size would only evict preloaded ones
+	top := cfg.WarmupTop
+	if top == 0 || top > cfg.CacheSize {
+		top = cfg.CacheSize
+	}
+	a.Warmer, err = warmup.New(source, cache, repository, cfg.CacheTTL, warmup.Policy{
+		Top:   top,
+		Batch: cfg.WarmupBatch,
+		Rate:  cfg.WarmupRate,
+	})
+	return err
+}
+
+// Start runs background workers of the strategy until Stop
+func (a *App) Start(ctx context.Context) {
+	a.workers.Start(ctx)
+}
+
+// Stop stops background workers: new work is rejected, work in flight and queued work is finished
+// until ctx is done, the rest is dropped and reported
+func (a *App) Stop(ctx context.Context) (lifecycle.Report, error) {
+	report, err := a.workers.Stop(ctx)
+	for name, dropped := range report.Dropped {
+		if dropped > 0 {
+			log.Warn().Str("worker", name).Int("dropped", dropped).Msg("background work dropped on stop")
+		}
+	}
+
+	// workers don't write to repository once stopped
+	if a.closeRepo != nil {
+		if closeErr := a.closeRepo(); closeErr != nil {
+			log.Err(closeErr).Msg("repository closing error")
+		}
+	}
+	return report, err
+}
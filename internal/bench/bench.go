@@ -0,0 +1,327 @@
+package bench
+
+import (
+	"caching-strategies/internal/app"
+	"caching-strategies/internal/chaos"
+	"caching-strategies/internal/config"
+	"caching-strategies/internal/metrics"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/workload"
+	"context"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const itemsNumber = 10
+
+type Config struct {
+	// App is the template of application config, strategy is replaced by each of Strategies
+	App        config.Config
+	Strategies []string
+
+	Keys int
+	// Workload is a distribution of keys, its key count is replaced by Keys
+	Workload    workload.Spec
+	BatchSize   int
+	Concurrency int
+	// ReadRatio is a share of reads in range [0, 1], the rest are writes
+	ReadRatio float64
+	Duration  time.Duration
+	Seed      int64
+
+	// Trace is a path of access trace replayed instead of synthetic workload,
+	// orders of all trace IDs are seeded
+	Trace string
+	// ReplaySpeed scales time between trace records, 0 replays them back to back
+	ReplaySpeed float64
+
+	// Faults are injected into repository after seeding, Outages are counted from the start of the run
+	Faults  chaos.Faults
+	Outages []chaos.Window
+}
+
+func DefaultConfig() Config {
+	return Config{
+		App:         config.Default(),
+		Strategies:  config.Strategies,
+		Keys:        1000,
+		Workload:    workload.DefaultSpec(1000),
+		BatchSize:   1,
+		Concurrency: 8,
+		ReadRatio:   0.9,
+		Duration:    5 * time.Second,
+		Seed:        1,
+		ReplaySpeed: 1,
+	}
+}
+
+func (c Config) Validate() error {
+	if len(c.Strategies) == 0 {
+		return fmt.Errorf("no strategies to run")
+	}
+	for _, strategy := range c.Strategies {
+		cfg := c.App
+		cfg.Strategy = strategy
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive, got %d", c.Concurrency)
+	}
+	if c.Trace != "" {
+		if c.ReplaySpeed < 0 {
+			return fmt.Errorf("replay speed must not be negative, got %g", c.ReplaySpeed)
+		}
+	} else {
+		if c.Keys <= 0 {
+			return fmt.Errorf("keys must be positive, got %d", c.Keys)
+		}
+		if err := c.workload().Validate(); err != nil {
+			return err
+		}
+		if c.BatchSize <= 0 || c.BatchSize > c.Keys {
+			return fmt.Errorf("batch size must be in range [1, %d], got %d", c.Keys, c.BatchSize)
+		}
+		if c.ReadRatio < 0 || c.ReadRatio > 1 {
+			return fmt.Errorf("read ratio must be in range [0, 1], got %g", c.ReadRatio)
+		}
+	}
+	if c.Duration <= 0 {
+		return fmt.Errorf("duration must be positive, got %s", c.Duration)
+	}
+	return c.Faults.Validate()
+}
+
+func (c Config) workload() workload.Spec {
+	spec := c.Workload
+	spec.Keys = uint64(c.Keys)
+	return spec
+}
+
+// keys are IDs of orders to seed
+func (c Config) keys() ([]uint64, error) {
+	if c.Trace != "" {
+		return traceKeys(c.Trace)
+	}
+
+	IDs := make([]uint64, c.Keys)
+	for i := range IDs {
+		IDs[i] = uint64(i)
+	}
+	return IDs, nil
+}
+
+// Result is a measurement of one strategy, latencies are per operation
+type Result struct {
+	Strategy   string        `json:"strategy"`
+	Ops        int           `json:"ops"`
+	Reads      int           `json:"reads"`
+	Writes     int           `json:"writes"`
+	Errors     int           `json:"errors"`
+	Elapsed    time.Duration `json:"elapsed_ns"`
+	Throughput float64       `json:"throughput"`
+	P50        time.Duration `json:"p50_ns"`
+	P99        time.Duration `json:"p99_ns"`
+	P999       time.Duration `json:"p999_ns"`
+	// HitRatio is a share of keys found in cache, 0 for strategy without cache
+	HitRatio float64 `json:"hit_ratio"`
+	// DBCalls are repository calls made during the run, seeding is not counted
+	DBCalls int `json:"db_calls"`
+}
+
+// Run runs strategies one by one, each on a fresh application with the same seeded data
+func Run(ctx context.Context, cfg Config) ([]Result, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(cfg.Strategies))
+	for _, strategy := range cfg.Strategies {
+		result, err := runStrategy(ctx, cfg, strategy)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s: %w", strategy, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+type workerStats struct {
+	reads, writes, errors int
+	latencies             []time.Duration
+}
+
+func runStrategy(ctx context.Context, cfg Config, strategy string) (Result, error) {
+	appCfg := cfg.App
+	appCfg.Strategy = strategy
+
+	sick := chaos.New(repo.New(), chaos.WithSeed(cfg.Seed))
+	application, err := app.New(appCfg, app.WithRepository(sick))
+	if err != nil {
+		return Result{}, err
+	}
+	IDs, err := cfg.keys()
+	if err != nil {
+		return Result{}, err
+	}
+	if err := seed(ctx, application.Repo, IDs, cfg.Concurrency); err != nil {
+		return Result{}, err
+	}
+	dbCallsBefore := dbCalls(application.Metrics)
+	sick.Set(cfg.Faults)
+	sick.Schedule(cfg.Outages...)
+
+	application.Start(ctx)
+	defer application.Stop(context.Background())
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	var (
+		stats   []workerStats
+		elapsed time.Duration
+	)
+	if cfg.Trace != "" {
+		stats, elapsed, err = replay(runCtx, application.Usecase, cfg)
+	} else {
+		stats, elapsed, err = generate(runCtx, application.Usecase, cfg)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Strategy: strategy,
+		Elapsed:  elapsed,
+		HitRatio: hitRatio(application.Metrics, strategy),
+		DBCalls:  int(dbCalls(application.Metrics) - dbCallsBefore),
+	}
+	var latencies []time.Duration
+	for _, s := range stats {
+		result.Reads += s.reads
+		result.Writes += s.writes
+		result.Errors += s.errors
+		latencies = append(latencies, s.latencies...)
+	}
+	result.Ops = result.Reads + result.Writes
+	result.Throughput = float64(result.Ops) / elapsed.Seconds()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.P50 = percentile(latencies, 0.5)
+	result.P99 = percentile(latencies, 0.99)
+	result.P999 = percentile(latencies, 0.999)
+
+	return result, nil
+}
+
+// generate runs clients of synthetic workload until ctx is done
+func generate(ctx context.Context, uc app.UsecaseI, cfg Config) ([]workerStats, time.Duration, error) {
+	// every client has its own generator, so clients of all strategies get the same keys
+	mixes := make([]*workload.Mix, cfg.Concurrency)
+	for i := range mixes {
+		rnd := rand.New(rand.NewSource(cfg.Seed + int64(i)))
+		keys, err := cfg.workload().New(rnd)
+		if err != nil {
+			return nil, 0, err
+		}
+		mixes[i] = workload.NewMix(keys, rnd, cfg.ReadRatio, cfg.BatchSize)
+	}
+
+	stats := make([]workerStats, cfg.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range stats {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stats[i] = work(ctx, uc, mixes[i])
+		}(i)
+	}
+	wg.Wait()
+
+	return stats, time.Since(start), nil
+}
+
+// seed saves orders directly to repository, so every strategy starts with cold cache
+func seed(ctx context.Context, repository *metrics.Repo, IDs []uint64, concurrency int) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	for _, ID := range IDs {
+		g.Go(func() error {
+			_, err := repository.Save(ctx, newOrder(ID))
+			return err
+		})
+	}
+	return g.Wait()
+}
+
+func work(ctx context.Context, uc app.UsecaseI, mix *workload.Mix) workerStats {
+	var s workerStats
+
+	for ctx.Err() == nil {
+		var err error
+		op := mix.Next()
+		start := time.Now()
+		if op.Write {
+			err = uc.Save(ctx, newOrder(op.Keys[0]))
+			s.writes++
+		} else {
+			_, err = uc.Get(ctx, op.Keys)
+			s.reads++
+		}
+		s.latencies = append(s.latencies, time.Since(start))
+		// operations interrupted by the end of the run are not errors
+		if err != nil && ctx.Err() == nil {
+			s.errors++
+		}
+	}
+
+	return s
+}
+
+func newOrder(ID uint64) *order.Order {
+	return &order.Order{
+		ID:         ID,
+		CustomerID: ID,
+		Item:       fmt.Sprintf("item-%d", ID%itemsNumber),
+	}
+}
+
+// percentile uses nearest-rank method on sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+func hitRatio(registry *metrics.Registry, strategy string) float64 {
+	labels := metrics.Labels{"strategy": strategy}
+	hits := registry.Value("cache_hits_total", labels)
+	misses := registry.Value("cache_misses_total", labels)
+	if hits+misses == 0 {
+		return 0
+	}
+	return hits / (hits + misses)
+}
+
+func dbCalls(registry *metrics.Registry) float64 {
+	var calls float64
+	for _, sample := range registry.Snapshot() {
+		if sample.Name == "repository_calls_total" {
+			calls += sample.Value
+		}
+	}
+	return calls
+}
@@ -0,0 +1,158 @@
+package bench
+
+import (
+	"bytes"
+	"caching-strategies/internal/accesstrace"
+	"caching-strategies/internal/config"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Strategies = []string{config.StrategyWithoutCache, config.StrategyCacheAside}
+	cfg.Keys = 100
+	cfg.BatchSize = 2
+	cfg.Concurrency = 4
+	cfg.Duration = 200 * time.Millisecond
+
+	results, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	for _, r := range results {
+		if r.Ops == 0 || r.Ops != r.Reads+r.Writes {
+			t.Fatalf("%s: unexpected ops %d, reads %d, writes %d", r.Strategy, r.Ops, r.Reads, r.Writes)
+		}
+		if r.Errors != 0 {
+			t.Fatalf("%s: unexpected errors %d", r.Strategy, r.Errors)
+		}
+		if r.P50 <= 0 || r.P50 > r.P99 || r.P99 > r.P999 {
+			t.Fatalf("%s: unexpected percentiles %s %s %s", r.Strategy, r.P50, r.P99, r.P999)
+		}
+	}
+
+	withoutCache, cacheAside := results[0], results[1]
+	if withoutCache.HitRatio != 0 || withoutCache.DBCalls != withoutCache.Ops {
+		t.Fatalf("expected every operation to hit db without cache, got %+v", withoutCache)
+	}
+	// all keys fit in cache, so most reads are hits
+	if cacheAside.HitRatio < 0.5 || cacheAside.DBCalls >= cacheAside.Ops {
+		t.Fatalf("expected cache to save db calls, got %+v", cacheAside)
+	}
+}
+
+func TestRunTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := accesstrace.NewWriter(f)
+	for i := 0; i < 20; i++ {
+		record := accesstrace.Record{Op: accesstrace.OpGet, IDs: []uint64{uint64(i % 5), 100}}
+		if i%10 == 9 {
+			record = accesstrace.Record{Op: accesstrace.OpSave, IDs: []uint64{uint64(i)}}
+		}
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	cfg := DefaultConfig()
+	cfg.Strategies = []string{config.StrategyReadWriteThrough}
+	cfg.Trace = path
+	cfg.Concurrency = 1
+
+	results, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	if r.Reads != 18 || r.Writes != 2 || r.Errors != 0 {
+		t.Fatalf("expected every trace record to be replayed, got %+v", r)
+	}
+	// seeded orders of the trace are found, repeated keys hit cache
+	if r.HitRatio < 0.5 {
+		t.Fatalf("expected repeated keys to hit cache, got %+v", r)
+	}
+}
+
+func TestRunValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.BatchSize = cfg.Keys + 1
+	if _, err := Run(context.Background(), cfg); err == nil {
+		t.Fatal("expected error for batch larger than keys")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Strategies = []string{"unknown"}
+	if _, err := Run(context.Background(), cfg); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+
+	// breaker would be ignored by cache-aside loads
+	cfg = DefaultConfig()
+	cfg.Strategies = []string{config.StrategyCacheAside}
+	cfg.App.BreakerThreshold = 5
+	if _, err := Run(context.Background(), cfg); err == nil {
+		t.Fatal("expected error for breaker with cache_aside")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	results := []Result{
+		{Strategy: "a", Ops: 10, Reads: 9, Writes: 1, P50: time.Millisecond, HitRatio: 0.5, DBCalls: 5},
+		{Strategy: "b", Ops: 20, Reads: 20},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCSV, results); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][0] != "a" || records[1][6] != "1000000" {
+		t.Fatalf("unexpected csv: %v", records)
+	}
+
+	buf.Reset()
+	if err := Write(&buf, FormatJSON, results); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Result
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0] != results[0] {
+		t.Fatalf("unexpected json: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := Write(&buf, FormatTable, results); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+
+	if err := Write(&buf, "xml", results); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
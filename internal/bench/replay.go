@@ -0,0 +1,117 @@
+package bench
+
+import (
+	"caching-strategies/internal/accesstrace"
+	"caching-strategies/internal/app"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// replay drives usecase with trace until it's over or ctx is done
+func replay(ctx context.Context, uc app.UsecaseI, cfg Config) ([]workerStats, time.Duration, error) {
+	f, err := os.Open(cfg.Trace)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	timed := &timedUsecase{usecase: uc}
+	stats, err := accesstrace.Replay(ctx, accesstrace.NewReader(f), timed, accesstrace.ReplayOptions{
+		Speed:       cfg.ReplaySpeed,
+		Concurrency: cfg.Concurrency,
+	})
+	// run duration is over before trace
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+		err = nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return []workerStats{timed.stats}, stats.Elapsed, nil
+}
+
+// traceKeys returns sorted distinct IDs of trace
+func traceKeys(path string) ([]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[uint64]struct{})
+	r := accesstrace.NewReader(f)
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, ID := range record.IDs {
+			seen[ID] = struct{}{}
+		}
+	}
+
+	IDs := make([]uint64, 0, len(seen))
+	for ID := range seen {
+		IDs = append(IDs, ID)
+	}
+	sort.Slice(IDs, func(i, j int) bool { return IDs[i] < IDs[j] })
+	return IDs, nil
+}
+
+// timedUsecase measures calls made by replayer, deletes are counted as writes
+type timedUsecase struct {
+	usecase app.UsecaseI
+
+	mu    sync.Mutex
+	stats workerStats
+}
+
+func (t *timedUsecase) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
+	start := time.Now()
+	orders, err := t.usecase.Get(ctx, IDs)
+	t.observe(ctx, start, false, err)
+	return orders, err
+}
+
+// Save writes order of the same shape as seeded ones, trace records only IDs
+func (t *timedUsecase) Save(ctx context.Context, order *order.Order) error {
+	start := time.Now()
+	err := t.usecase.Save(ctx, newOrder(order.ID))
+	t.observe(ctx, start, true, err)
+	return err
+}
+
+func (t *timedUsecase) Delete(ctx context.Context, ID uint64) error {
+	start := time.Now()
+	err := t.usecase.Delete(ctx, ID)
+	t.observe(ctx, start, true, err)
+	return err
+}
+
+func (t *timedUsecase) observe(ctx context.Context, start time.Time, write bool, err error) {
+	elapsed := time.Since(start)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats.latencies = append(t.stats.latencies, elapsed)
+	if write {
+		t.stats.writes++
+	} else {
+		t.stats.reads++
+	}
+	// calls interrupted by the end of the run are not errors
+	if err != nil && ctx.Err() == nil {
+		t.stats.errors++
+	}
+}
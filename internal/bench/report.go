@@ -0,0 +1,93 @@
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	FormatTable = "table"
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+)
+
+// Formats are all supported report formats
+var Formats = []string{FormatTable, FormatCSV, FormatJSON}
+
+var header = []string{
+	"strategy", "ops", "reads", "writes", "errors", "throughput",
+	"p50_ns", "p99_ns", "p999_ns", "hit_ratio", "db_calls",
+}
+
+// Write reports results in given format
+func Write(w io.Writer, format string, results []Result) error {
+	switch format {
+	case FormatTable:
+		return writeTable(w, results)
+	case FormatCSV:
+		return writeCSV(w, results)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	default:
+		return fmt.Errorf("unknown format %q, expected one of %v", format, Formats)
+	}
+}
+
+func writeTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(tw, "strategy\tops\terrors\tops/s\tp50\tp99\tp999\thit ratio\tdb calls\t")
+	for _, r := range results {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f\t%s\t%s\t%s\t%.1f%%\t%d\t\n",
+			r.Strategy, r.Ops, r.Errors, r.Throughput,
+			round(r.P50), round(r.P99), round(r.P999),
+			r.HitRatio*100, r.DBCalls,
+		)
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range results {
+		record := []string{
+			r.Strategy,
+			strconv.Itoa(r.Ops),
+			strconv.Itoa(r.Reads),
+			strconv.Itoa(r.Writes),
+			strconv.Itoa(r.Errors),
+			strconv.FormatFloat(r.Throughput, 'f', 2, 64),
+			strconv.FormatInt(int64(r.P50), 10),
+			strconv.FormatInt(int64(r.P99), 10),
+			strconv.FormatInt(int64(r.P999), 10),
+			strconv.FormatFloat(r.HitRatio, 'f', 4, 64),
+			strconv.Itoa(r.DBCalls),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// round keeps latency readable in table
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
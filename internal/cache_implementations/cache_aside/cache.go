@@ -1,26 +1,106 @@
 package cache_aside
 
-import "caching-strategies/internal/repository/entity/order"
+import (
+	"caching-strategies/internal/cache_implementations/options"
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/metrics"
+	"caching-strategies/internal/repository/entity/order"
+	"time"
+)
 
 type CacheInterface[K uint64, V *order.Order] interface {
 	Get(key K) (value *order.Order, ok bool)
 	Add(key K, value *order.Order) (evicted bool)
+	AddWithTTL(key K, value *order.Order, ttl time.Duration) (evicted bool)
+	Remove(key K) (present bool)
+	TTL(key K) (ttl time.Duration, ok bool)
 }
 
 type CacheAside struct {
 	cache CacheInterface[uint64, *order.Order]
+	opts  options.Options
 }
 
-func New(cache CacheInterface[uint64, *order.Order]) *CacheAside {
-	return &CacheAside{
+func New(cache CacheInterface[uint64, *order.Order], opts ...options.Option) *CacheAside {
+	c := &CacheAside{
 		cache: cache,
+		opts:  options.New(opts...),
 	}
+	c.opts.Registry.OnInvalidate(func(ID uint64) {
+		c.opts.Generations.Invalidate(ID)
+		_ = c.cache.Remove(ID)
+	})
+
+	return c
 }
 
 func (c *CacheAside) Get(key uint64) (value *order.Order, ok bool) {
-	return c.cache.Get(key)
+	value, ok = c.cache.Get(key)
+	if !ok || value == nil {
+		c.opts.Metrics.Miss()
+		return value, ok
+	}
+	c.opts.Metrics.Hit()
+
+	return value, ok
 }
 
+// Add caches saved value, loads in flight have the previous version, they don't overwrite it
 func (c *CacheAside) Add(key uint64, value *order.Order) (evicted bool) {
-	return c.cache.Add(key, value)
+	c.opts.Generations.Invalidate(key)
+	evicted = c.opts.TTLPolicy.Add(c.cache, key, value, 0)
+	c.opts.Registry.Tag(value)
+
+	return evicted
+}
+
+// BeginLoad is called by the caller before loading from db, returned generation is passed to AddLoaded and EndLoad
+func (c *CacheAside) BeginLoad() uint64 {
+	return c.opts.Generations.Begin()
+}
+
+// EndLoad is called when loaded values are cached
+func (c *CacheAside) EndLoad() {
+	c.opts.Generations.End()
+}
+
+// AddLoaded adds value loaded from db unless it was deleted or saved since load began,
+// load cost is used by TTL policy, returns whether value was cached
+func (c *CacheAside) AddLoaded(key uint64, value *order.Order, loadCost time.Duration, generation uint64) (added bool) {
+	added = c.opts.Generations.AddIfValid(key, generation, func() {
+		_ = c.opts.TTLPolicy.Add(c.cache, key, value, loadCost)
+	})
+	if added {
+		c.opts.Registry.Tag(value)
+	}
+
+	return added
+}
+
+// Metrics are used by the caller to record repository loads
+func (c *CacheAside) Metrics() *metrics.CacheMetrics {
+	return c.opts.Metrics
+}
+
+// Clock measures load cost of orders loaded by usecase
+func (c *CacheAside) Clock() clock.Clock {
+	return c.opts.Clock
+}
+
+// RemainingTTL returns time left until the cached order expires, false if it isn't cached
+func (c *CacheAside) RemainingTTL(ID uint64) (time.Duration, bool) {
+	return c.cache.TTL(ID)
+}
+
+// Invalidate removes order from cache and cascades to dependent entries,
+// loads in flight don't bring it back
+func (c *CacheAside) Invalidate(key uint64) {
+	c.opts.Generations.Invalidate(key)
+	_ = c.cache.Remove(key)
+	c.opts.Registry.Invalidate(key)
+}
+
+// InvalidateTag removes all orders with the tag, returns their count
+func (c *CacheAside) InvalidateTag(tag string) int {
+	return c.opts.Registry.InvalidateTag(tag)
 }
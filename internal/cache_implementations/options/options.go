@@ -0,0 +1,101 @@
+package options
+
+import (
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/invalidation"
+	"caching-strategies/internal/metrics"
+	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/resilience"
+	"caching-strategies/internal/ttl"
+)
+
+// Options are optional dependencies shared by cache strategies
+type Options struct {
+	Registry *invalidation.Registry
+	// Generations keep loads from caching orders invalidated while loading,
+	// strategy and its background refresh must share them
+	Generations *invalidation.Generations
+	TTLPolicy   *ttl.Policy
+	Metrics     *metrics.CacheMetrics
+	// Clock is never nil, real clock by default
+	Clock clock.Clock
+
+	// Breaker and Limiter guard repository loads, nil ones let every load through
+	Breaker *resilience.Breaker
+	Limiter *resilience.Limiter
+	// Stale keeps orders longer than the main cache, they are served when repository load fails
+	Stale StaleCache
+}
+
+type StaleCache interface {
+	Get(key uint64) (value *order.Order, ok bool)
+	Add(key uint64, value *order.Order) (evicted bool)
+	Remove(key uint64) (present bool)
+}
+
+type Option func(*Options)
+
+func New(opts ...Option) Options {
+	o := Options{Clock: clock.Real()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithRegistry enables tag and dependency invalidation
+func WithRegistry(registry *invalidation.Registry) Option {
+	return func(o *Options) {
+		o.Registry = registry
+	}
+}
+
+// WithGenerations drops load results of orders deleted or saved while loading
+func WithGenerations(generations *invalidation.Generations) Option {
+	return func(o *Options) {
+		o.Generations = generations
+	}
+}
+
+// WithTTLPolicy sets per-entry TTL instead of the cache default one
+func WithTTLPolicy(policy *ttl.Policy) Option {
+	return func(o *Options) {
+		o.TTLPolicy = policy
+	}
+}
+
+// WithMetrics instruments hits, misses, loads and refreshes
+func WithMetrics(m *metrics.CacheMetrics) Option {
+	return func(o *Options) {
+		o.Metrics = m
+	}
+}
+
+// WithClock replaces real clock used for load cost and expiration, e.g. with clock.Fake in tests
+func WithClock(c clock.Clock) Option {
+	return func(o *Options) {
+		o.Clock = c
+	}
+}
+
+// WithBreaker fails repository loads fast while repository is sick
+func WithBreaker(b *resilience.Breaker) Option {
+	return func(o *Options) {
+		o.Breaker = b
+	}
+}
+
+// WithLimiter bounds concurrent repository loads and sheds the excess
+func WithLimiter(l *resilience.Limiter) Option {
+	return func(o *Options) {
+		o.Limiter = l
+	}
+}
+
+// WithStale serves orders of the stale cache when repository load fails or is rejected,
+// stale cache should keep orders longer than the main one
+func WithStale(stale StaleCache) Option {
+	return func(o *Options) {
+		o.Stale = stale
+	}
+}
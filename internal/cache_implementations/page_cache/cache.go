@@ -0,0 +1,257 @@
+package page_cache
+
+import (
+	"caching-strategies/internal/cache_implementations/options"
+	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const pageKeyPrefix = "page:"
+
+type Page struct {
+	IDs  []uint64
+	Next uint64
+}
+
+type CacheInterface[K string, V *Page] interface {
+	Get(key K) (value *Page, ok bool)
+	Add(key K, value *Page) (evicted bool)
+	Remove(key K) (present bool)
+}
+
+// HotStorageI is a per-ID entry cache used to hydrate pages
+type HotStorageI interface {
+	Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
+	Add(ctx context.Context, order *order.Order) error
+	Delete(ctx context.Context, ID uint64) error
+	RemainingTTL(ID uint64) (time.Duration, bool)
+}
+
+type OrderRepoI interface {
+	List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error)
+}
+
+// pageRange is the ID range [from, to] covered by a cached page,
+// the last page covers all IDs from its cursor
+type pageRange struct {
+	from uint64
+	to   uint64
+	last bool
+}
+
+func (r pageRange) covers(ID uint64) bool {
+	return ID >= r.from && (r.last || ID <= r.to)
+}
+
+// PageCache caches pages of ID lists by cursor, values are hydrated from the entry cache.
+// Pages are keyset based: insert changes only the page covering inserted ID,
+// pages starting from other cursors stay valid.
+type PageCache struct {
+	cache           CacheInterface[string, *Page]
+	entries         HotStorageI
+	orderRepository OrderRepoI
+	opts            options.Options
+
+	mu     sync.Mutex
+	ranges map[string]pageRange
+	// incremented on every invalidation, protects from caching pages loaded before it
+	gen uint64
+}
+
+func New(
+	cache CacheInterface[string, *Page],
+	entries HotStorageI,
+	orderRepository OrderRepoI,
+	opts ...options.Option,
+) *PageCache {
+	c := &PageCache{
+		cache:           cache,
+		entries:         entries,
+		orderRepository: orderRepository,
+		opts:            options.New(opts...),
+		ranges:          make(map[string]pageRange),
+	}
+	c.opts.Registry.OnInvalidateDependent(func(key string) {
+		if !strings.HasPrefix(key, pageKeyPrefix) {
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.gen++
+		delete(c.ranges, key)
+		_ = c.cache.Remove(key)
+	})
+
+	return c
+}
+
+// List returns orders of the page starting from cursor and the cursor of the next page
+func (c *PageCache) List(ctx context.Context, cursor uint64, limit int) ([]order.Order, uint64, error) {
+	ctx, span := tracing.Start(ctx, "page_cache.List")
+	defer span.End()
+
+	key := pageKey(cursor, limit)
+
+	page, ok := c.cache.Get(key)
+	span.SetAttribute("hit", ok)
+	if ok {
+		c.opts.Metrics.Hit()
+	} else {
+		c.opts.Metrics.Miss()
+
+		c.mu.Lock()
+		gen := c.gen
+		c.mu.Unlock()
+
+		start := c.opts.Clock.Now()
+		IDs, next, err := c.orderRepository.List(ctx, cursor, limit)
+		c.opts.Metrics.Load(len(IDs), c.opts.Clock.Since(start), err)
+		if err != nil {
+			return nil, 0, fmt.Errorf("err from repository: %w", err)
+		}
+
+		// request was cancelled while loading, its result isn't cached
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		page = &Page{IDs: IDs, Next: next}
+		c.store(key, cursor, page, gen)
+
+		log.Debug().Str("key", key).Int("count", len(IDs)).Msg("page loaded from db")
+	}
+
+	if len(page.IDs) == 0 {
+		return []order.Order{}, page.Next, nil
+	}
+
+	result, err := c.entries.Get(ctx, page.IDs)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "entries.Get")
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, page.Next, nil
+}
+
+func (c *PageCache) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
+	return c.entries.Get(ctx, IDs)
+}
+
+func (c *PageCache) Add(ctx context.Context, order *order.Order) error {
+	if err := c.entries.Add(ctx, order); err != nil {
+		return errors.Wrap(err, "entries.Add")
+	}
+
+	c.invalidate(order.ID)
+
+	return nil
+}
+
+func (c *PageCache) Delete(ctx context.Context, ID uint64) error {
+	err := c.entries.Delete(ctx, ID)
+	c.invalidateDeleted(ID)
+	if err != nil {
+		return errors.Wrap(err, "entries.Delete")
+	}
+
+	return nil
+}
+
+func (c *PageCache) RemainingTTL(ID uint64) (time.Duration, bool) {
+	return c.entries.RemainingTTL(ID)
+}
+
+// store caches page if nothing was invalidated since gen
+func (c *PageCache) store(key string, cursor uint64, page *Page, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	r := pageRange{from: cursor, last: page.Next == 0}
+	if !r.last {
+		r.to = page.Next - 1
+	}
+	c.ranges[key] = r
+	_ = c.cache.Add(key, page)
+	c.opts.Registry.DependOn(key, page.IDs)
+}
+
+// invalidate drops pages covering ID unless ID is already listed in them
+func (c *PageCache) invalidate(ID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	updated := false
+	for key, r := range c.ranges {
+		if !r.covers(ID) {
+			continue
+		}
+
+		page, ok := c.cache.Get(key)
+		if !ok {
+			// evicted from cache
+			delete(c.ranges, key)
+			c.opts.Registry.Forget(key)
+			continue
+		}
+		if contains(page.IDs, ID) {
+			// order was updated, values are hydrated from the entry cache
+			updated = true
+			continue
+		}
+
+		delete(c.ranges, key)
+		_ = c.cache.Remove(key)
+		c.opts.Registry.Forget(key)
+	}
+
+	// insert may shift pages being loaded right now
+	if !updated {
+		c.gen++
+	}
+}
+
+// invalidateDeleted drops pages listing deleted ID
+func (c *PageCache) invalidateDeleted(ID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for key, r := range c.ranges {
+		if !r.covers(ID) {
+			continue
+		}
+
+		page, ok := c.cache.Get(key)
+		if ok && !contains(page.IDs, ID) {
+			continue
+		}
+
+		delete(c.ranges, key)
+		_ = c.cache.Remove(key)
+		c.opts.Registry.Forget(key)
+	}
+}
+
+func contains(IDs []uint64, ID uint64) bool {
+	i := sort.Search(len(IDs), func(i int) bool { return IDs[i] >= ID })
+	return i < len(IDs) && IDs[i] == ID
+}
+
+func pageKey(cursor uint64, limit int) string {
+	return fmt.Sprintf("%s%d:%d", pageKeyPrefix, cursor, limit)
+}
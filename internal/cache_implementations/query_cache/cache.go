@@ -0,0 +1,229 @@
+package query_cache
+
+import (
+	"caching-strategies/internal/cache_implementations/options"
+	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const itemKeyPrefix = "item:"
+
+type CacheInterface[K string, V []uint64] interface {
+	Get(key K) (value []uint64, ok bool)
+	Add(key K, value []uint64) (evicted bool)
+	Remove(key K) (present bool)
+}
+
+// HotStorageI is a per-ID entry cache used to hydrate query results
+type HotStorageI interface {
+	Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
+	Add(ctx context.Context, order *order.Order) error
+	Delete(ctx context.Context, ID uint64) error
+	RemainingTTL(ID uint64) (time.Duration, bool)
+}
+
+type OrderRepoI interface {
+	ListByItem(ctx context.Context, item string) ([]uint64, error)
+}
+
+// QueryCache caches ID lists per query key, values are hydrated from the entry cache
+type QueryCache struct {
+	cache           CacheInterface[string, []uint64]
+	entries         HotStorageI
+	orderRepository OrderRepoI
+	opts            options.Options
+
+	mu sync.Mutex
+	// order ID -> cached query keys containing it
+	queries map[uint64]map[string]struct{}
+	// query key -> cached order IDs
+	members map[string][]uint64
+	// incremented on every invalidation, protects from caching lists loaded before it
+	gen uint64
+}
+
+func New(
+	cache CacheInterface[string, []uint64],
+	entries HotStorageI,
+	orderRepository OrderRepoI,
+	opts ...options.Option,
+) *QueryCache {
+	c := &QueryCache{
+		cache:           cache,
+		entries:         entries,
+		orderRepository: orderRepository,
+		opts:            options.New(opts...),
+		queries:         make(map[uint64]map[string]struct{}),
+		members:         make(map[string][]uint64),
+	}
+	c.opts.Registry.OnInvalidateDependent(func(key string) {
+		if !strings.HasPrefix(key, itemKeyPrefix) {
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.gen++
+		c.removeLocked(key)
+	})
+
+	return c
+}
+
+func (c *QueryCache) ListByItem(ctx context.Context, item string) ([]order.Order, error) {
+	ctx, span := tracing.Start(ctx, "query_cache.ListByItem")
+	defer span.End()
+
+	key := itemKey(item)
+
+	IDs, ok := c.cache.Get(key)
+	span.SetAttribute("hit", ok)
+	if ok {
+		c.opts.Metrics.Hit()
+	} else {
+		c.opts.Metrics.Miss()
+
+		c.mu.Lock()
+		gen := c.gen
+		c.mu.Unlock()
+
+		var err error
+		start := c.opts.Clock.Now()
+		IDs, err = c.orderRepository.ListByItem(ctx, item)
+		c.opts.Metrics.Load(len(IDs), c.opts.Clock.Since(start), err)
+		if err != nil {
+			return nil, fmt.Errorf("err from repository: %w", err)
+		}
+
+		// request was cancelled while loading, its result isn't cached
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		c.store(key, IDs, gen)
+
+		log.Debug().Str("key", key).Int("count", len(IDs)).Msg("query loaded from db")
+	}
+
+	if len(IDs) == 0 {
+		return []order.Order{}, nil
+	}
+
+	result, err := c.entries.Get(ctx, IDs)
+	if err != nil {
+		return nil, errors.Wrap(err, "entries.Get")
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
+
+func (c *QueryCache) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
+	return c.entries.Get(ctx, IDs)
+}
+
+func (c *QueryCache) Add(ctx context.Context, order *order.Order) error {
+	if err := c.entries.Add(ctx, order); err != nil {
+		return errors.Wrap(err, "entries.Add")
+	}
+
+	c.invalidate(order)
+
+	return nil
+}
+
+func (c *QueryCache) Delete(ctx context.Context, ID uint64) error {
+	err := c.entries.Delete(ctx, ID)
+	c.invalidateID(ID)
+	if err != nil {
+		return errors.Wrap(err, "entries.Delete")
+	}
+
+	return nil
+}
+
+func (c *QueryCache) RemainingTTL(ID uint64) (time.Duration, bool) {
+	return c.entries.RemainingTTL(ID)
+}
+
+// store caches query result if nothing was invalidated since gen
+func (c *QueryCache) store(key string, IDs []uint64, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	c.forgetLocked(key)
+	for _, ID := range IDs {
+		if c.queries[ID] == nil {
+			c.queries[ID] = make(map[string]struct{})
+		}
+		c.queries[ID][key] = struct{}{}
+	}
+	c.members[key] = IDs
+	_ = c.cache.Add(key, IDs)
+	c.opts.Registry.DependOn(key, IDs)
+}
+
+// invalidate drops cached lists whose membership is changed by the saved order
+func (c *QueryCache) invalidate(order *order.Order) {
+	key := itemKey(order.Item)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := c.queries[order.ID]
+	if _, ok := keys[key]; ok {
+		// item wasn't changed, values are hydrated from the entry cache
+		return
+	}
+
+	c.gen++
+	c.removeLocked(key)
+	for k := range keys {
+		c.removeLocked(k)
+	}
+}
+
+// invalidateID drops cached lists containing deleted order
+func (c *QueryCache) invalidateID(ID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for key := range c.queries[ID] {
+		c.removeLocked(key)
+	}
+}
+
+func (c *QueryCache) removeLocked(key string) {
+	c.forgetLocked(key)
+	_ = c.cache.Remove(key)
+	c.opts.Registry.Forget(key)
+}
+
+// forgetLocked drops reverse index of the query key, must be called under c.mu
+func (c *QueryCache) forgetLocked(key string) {
+	for _, ID := range c.members[key] {
+		delete(c.queries[ID], key)
+		if len(c.queries[ID]) == 0 {
+			delete(c.queries, ID)
+		}
+	}
+	delete(c.members, key)
+}
+
+func itemKey(item string) string {
+	return itemKeyPrefix + item
+}
@@ -1,56 +1,89 @@
 package read_write_through
 
 import (
+	"caching-strategies/internal/cache_implementations/options"
 	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/resilience"
+	"caching-strategies/internal/tracing"
 	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
+	"time"
 )
 
 type CacheInterface[K uint64, V *order.Order] interface {
 	Get(key K) (value *order.Order, ok bool)
 	Add(key K, value *order.Order) (evicted bool)
+	AddWithTTL(key K, value *order.Order, ttl time.Duration) (evicted bool)
+	Remove(key K) (present bool)
+	TTL(key K) (ttl time.Duration, ok bool)
 }
 
 type OrderRepoI interface {
 	Save(ctx context.Context, order *order.Order) (uint64, error)
 	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+	Delete(ctx context.Context, ID uint64) error
 }
 
 type ReadWriteThroughCache struct {
 	cache           CacheInterface[uint64, *order.Order]
 	orderRepository OrderRepoI
+	opts            options.Options
 }
 
-func New(cache CacheInterface[uint64, *order.Order], orderRepository OrderRepoI) *ReadWriteThroughCache {
-	return &ReadWriteThroughCache{
+func New(
+	cache CacheInterface[uint64, *order.Order],
+	orderRepository OrderRepoI,
+	opts ...options.Option,
+) *ReadWriteThroughCache {
+	c := &ReadWriteThroughCache{
 		cache:           cache,
 		orderRepository: orderRepository,
+		opts:            options.New(opts...),
 	}
+	c.opts.Registry.OnInvalidate(func(ID uint64) {
+		c.opts.Generations.Invalidate(ID)
+		_ = c.cache.Remove(ID)
+		c.forgetStale(ID)
+	})
+
+	return c
 }
 
 func (c *ReadWriteThroughCache) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
+	ctx, span := tracing.Start(ctx, "read_write_through.Get")
+	defer span.End()
+	span.SetAttribute("ids", len(IDs))
+
 	notInCacheCh := make(chan uint64, len(IDs))
 	notInCache := make([]uint64, 0, len(IDs))
 
 	inCacheCh := make(chan order.Order, len(IDs))
 
-	g := errgroup.Group{}
+	_, lookupSpan := tracing.Start(ctx, "cache.lookup")
+
+	g, gctx := errgroup.WithContext(ctx)
 	g.SetLimit(100)
 
 	// split requests to DB
 	for _, ID := range IDs {
 		ID := ID
 		g.Go(func() error {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+
 			value, ok := c.cache.Get(ID)
 			if !ok || value == nil {
 				// нет в кэше, будем искать в бд
+				c.opts.Metrics.Miss()
 				notInCacheCh <- ID
 
 				return nil
 			}
+			c.opts.Metrics.Hit()
 
 			// получили значение из кэша
 			inCacheCh <- *value
@@ -59,11 +92,20 @@ func (c *ReadWriteThroughCache) Get(ctx context.Context, IDs []uint64) ([]order.
 		})
 	}
 
-	// never returns err
-	_ = g.Wait()
+	// fails only when ctx is done
+	err := g.Wait()
 	close(notInCacheCh)
 	close(inCacheCh)
 
+	lookupSpan.SetAttribute("hits", len(inCacheCh))
+	lookupSpan.SetAttribute("misses", len(notInCacheCh))
+	lookupSpan.End()
+
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
 	result := make([]order.Order, 0, len(IDs))
 	// append cache to result
 	for ord := range inCacheCh {
@@ -75,40 +117,159 @@ func (c *ReadWriteThroughCache) Get(ctx context.Context, IDs []uint64) ([]order.
 		notInCache = append(notInCache, ID)
 	}
 
-	log.Debug().Msgf("get items from cache", "count", len(IDs))
+	log.Debug().Int("count", len(IDs)).Msg("get items from cache")
 
 	// обновляем данные в кэше
 	if len(notInCache) > 0 {
-		ordersMap, err := c.orderRepository.Get(ctx, notInCache)
+		// orders deleted or saved while loading aren't cached
+		generation := c.opts.Generations.Begin()
+		defer c.opts.Generations.End()
+
+		start := c.opts.Clock.Now()
+		var ordersMap map[uint64]order.Order
+		err := resilience.Do(ctx, c.opts.Breaker, c.opts.Limiter, func(ctx context.Context) error {
+			var err error
+			ordersMap, err = c.orderRepository.Get(ctx, notInCache)
+			return err
+		})
+		if resilience.Rejected(err) {
+			c.opts.Metrics.Rejected()
+		} else {
+			c.opts.Metrics.Load(len(ordersMap), c.opts.Clock.Since(start), err)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("err from repository: %s", err.Error())
+			// repository is sick, old orders are better than none, but nobody waits for cancelled request
+			if stale, ok := c.serveStale(notInCache); ok && ctx.Err() == nil {
+				span.SetAttribute("stale", len(stale))
+				log.Debug().Err(err).Int("count", len(stale)).Msg("serve stale orders")
+				return append(result, stale...), nil
+			}
+			span.RecordError(err)
+			return nil, fmt.Errorf("err from repository: %w", err)
+		}
+		loadCost := c.opts.Clock.Since(start) / time.Duration(len(notInCache))
+
+		// request was cancelled while loading, its orders aren't cached
+		if err := ctx.Err(); err != nil {
+			span.RecordError(err)
+			return nil, err
 		}
 
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(100)
+
 		for _, ord := range ordersMap {
 			ord := ord
 			result = append(result, ord)
 
 			g.Go(func() error {
-				_ = c.cache.Add(ord.ID, &ord)
+				if err := gctx.Err(); err != nil {
+					return err
+				}
+
+				if c.opts.Generations.AddIfValid(ord.ID, generation, func() {
+					_ = c.opts.TTLPolicy.Add(c.cache, ord.ID, &ord, loadCost)
+					c.rememberStale(&ord)
+				}) {
+					c.opts.Registry.Tag(&ord)
+				}
 
 				return nil
 			})
 		}
-		_ = g.Wait()
+		if err := g.Wait(); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
 
-		log.Debug().Msgf("get from db", "count", len(ordersMap))
+		log.Debug().Int("count", len(ordersMap)).Msg("get from db")
 	}
 
 	return result, nil
 }
 
 func (c *ReadWriteThroughCache) Add(ctx context.Context, order *order.Order) error {
+	ctx, span := tracing.Start(ctx, "read_write_through.Add")
+	defer span.End()
+
 	orderID, err := c.orderRepository.Save(ctx, order)
 	if err != nil {
+		span.RecordError(err)
 		return errors.Wrap(err, "orderRepository.Save")
 	}
 
-	_ = c.cache.Add(orderID, order)
+	// loads in flight have the previous version, they don't overwrite the saved one
+	c.opts.Generations.Invalidate(orderID)
+	_ = c.opts.TTLPolicy.Add(c.cache, orderID, order, 0)
+	c.rememberStale(order)
+	c.opts.Registry.Tag(order)
+
+	return nil
+}
+
+// Delete removes order from db and cache, cache is cleaned even if order wasn't found in db
+func (c *ReadWriteThroughCache) Delete(ctx context.Context, ID uint64) error {
+	ctx, span := tracing.Start(ctx, "read_write_through.Delete")
+	defer span.End()
+
+	err := c.orderRepository.Delete(ctx, ID)
+	c.Invalidate(ID)
+	if err != nil {
+		span.RecordError(err)
+		return errors.Wrap(err, "orderRepository.Delete")
+	}
 
 	return nil
 }
+
+// RemainingTTL returns time left until the cached order expires, false if it isn't cached
+func (c *ReadWriteThroughCache) RemainingTTL(ID uint64) (time.Duration, bool) {
+	return c.cache.TTL(ID)
+}
+
+// Invalidate removes order from cache and cascades to dependent entries,
+// loads in flight don't bring it back
+func (c *ReadWriteThroughCache) Invalidate(ID uint64) {
+	c.opts.Generations.Invalidate(ID)
+	_ = c.cache.Remove(ID)
+	c.forgetStale(ID)
+	c.opts.Registry.Invalidate(ID)
+}
+
+// InvalidateTag removes all orders with the tag, returns their count
+func (c *ReadWriteThroughCache) InvalidateTag(tag string) int {
+	return c.opts.Registry.InvalidateTag(tag)
+}
+
+// serveStale returns stale orders of all IDs, false if any of them isn't in stale cache
+func (c *ReadWriteThroughCache) serveStale(IDs []uint64) ([]order.Order, bool) {
+	if c.opts.Stale == nil {
+		return nil, false
+	}
+
+	orders := make([]order.Order, 0, len(IDs))
+	for _, ID := range IDs {
+		value, ok := c.opts.Stale.Get(ID)
+		if !ok || value == nil {
+			return nil, false
+		}
+		orders = append(orders, *value)
+	}
+	c.opts.Metrics.Stale(len(orders))
+
+	return orders, true
+}
+
+func (c *ReadWriteThroughCache) rememberStale(order *order.Order) {
+	if c.opts.Stale == nil {
+		return
+	}
+	_ = c.opts.Stale.Add(order.ID, order)
+}
+
+func (c *ReadWriteThroughCache) forgetStale(ID uint64) {
+	if c.opts.Stale == nil {
+		return
+	}
+	_ = c.opts.Stale.Remove(ID)
+}
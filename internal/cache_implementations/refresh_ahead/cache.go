@@ -1,7 +1,10 @@
 package refresh_ahead
 
 import (
+	"caching-strategies/internal/cache_implementations/options"
+	"caching-strategies/internal/lifecycle"
 	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
 	"context"
 	"fmt"
 	"github.com/pkg/errors"
@@ -15,62 +18,88 @@ const refreshFactor = 2
 type CacheInterface[K uint64, V *order.Order] interface {
 	Get(key K) (value *order.Order, ok bool)
 	Add(key K, value *order.Order) (evicted bool)
+	AddWithTTL(key K, value *order.Order, ttl time.Duration) (evicted bool)
+	Remove(key K) (present bool)
+	TTL(key K) (ttl time.Duration, ok bool)
 }
 
 type OrderRepoI interface {
 	Save(ctx context.Context, order *order.Order) (uint64, error)
 	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+	Delete(ctx context.Context, ID uint64) error
 }
 
 type RefreshAheadCache struct {
 	cache           CacheInterface[uint64, *order.Order]
 	orderRepository OrderRepoI
 	TTL             time.Duration
-	refreshCh       chan<- uint64
+	refreshQueue    *lifecycle.Queue[uint64]
+	opts            options.Options
 }
 
 func New(
 	cache CacheInterface[uint64, *order.Order],
 	orderRepository OrderRepoI,
 	ttl time.Duration,
-	refreshCh chan<- uint64,
+	refreshQueue *lifecycle.Queue[uint64],
+	opts ...options.Option,
 ) *RefreshAheadCache {
-	return &RefreshAheadCache{
+	c := &RefreshAheadCache{
 		cache:           cache,
 		orderRepository: orderRepository,
 		TTL:             ttl,
-		refreshCh:       refreshCh,
+		refreshQueue:    refreshQueue,
+		opts:            options.New(opts...),
 	}
+	c.opts.Registry.OnInvalidate(func(ID uint64) {
+		c.opts.Generations.Invalidate(ID)
+		_ = c.cache.Remove(ID)
+	})
+
+	return c
 }
 
 func (c *RefreshAheadCache) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
+	ctx, span := tracing.Start(ctx, "refresh_ahead.Get")
+	defer span.End()
+	span.SetAttribute("ids", len(IDs))
+
 	notInCacheCh := make(chan uint64, len(IDs))
 	notInCache := make([]uint64, 0, len(IDs))
 
 	inCacheCh := make(chan order.Order, len(IDs))
 
-	g := errgroup.Group{}
+	_, lookupSpan := tracing.Start(ctx, "cache.lookup")
+
+	g, gctx := errgroup.WithContext(ctx)
 	g.SetLimit(100)
 
 	// split requests to DB
 	for _, ID := range IDs {
 		ID := ID
 		g.Go(func() error {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+
 			value, ok := c.cache.Get(ID)
 			if !ok || value == nil {
 				// нет в кэше, будем искать в бд
+				c.opts.Metrics.Miss()
 				notInCacheCh <- ID
 
 				return nil
 			}
-
-			// если ttl кэша уменьшился в refreshFactor раз - пишем в канал обновления
-			if value.ExpiredAt.Sub(time.Now()) <= c.TTL/refreshFactor {
-				// если канал полный - не пишем, чтобы не заблокироваться
-				if len(c.refreshCh) < cap(c.refreshCh) {
-					c.refreshCh <- ID
+			c.opts.Metrics.Hit()
+
+			// если ttl кэша уменьшился в refreshFactor раз - пишем в очередь обновления
+			if value.ExpiredAt.Sub(c.opts.Clock.Now()) <= c.TTL/refreshFactor {
+				// очередь не блокирует: если она полная или watcher остановлен - обновление пропускаем
+				if err := c.refreshQueue.Offer(ID); err != nil {
+					c.opts.Metrics.RefreshQueued(true)
+					log.Warn().Err(err).Msg("refresh is not queued")
 				} else {
-					log.Warn().Msg("refreshCh is full")
+					c.opts.Metrics.RefreshQueued(false)
 				}
 			}
 
@@ -81,11 +110,20 @@ func (c *RefreshAheadCache) Get(ctx context.Context, IDs []uint64) ([]order.Orde
 		})
 	}
 
-	// never returns err
-	_ = g.Wait()
+	// fails only when ctx is done
+	err := g.Wait()
 	close(notInCacheCh)
 	close(inCacheCh)
 
+	lookupSpan.SetAttribute("hits", len(inCacheCh))
+	lookupSpan.SetAttribute("misses", len(notInCacheCh))
+	lookupSpan.End()
+
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
 	result := make([]order.Order, 0, len(IDs))
 	// append cache to result
 	for ord := range inCacheCh {
@@ -97,40 +135,108 @@ func (c *RefreshAheadCache) Get(ctx context.Context, IDs []uint64) ([]order.Orde
 		notInCache = append(notInCache, ID)
 	}
 
-	log.Debug().Msgf("get items from cache", "count", len(IDs))
+	log.Debug().Int("count", len(IDs)).Msg("get items from cache")
 
 	// обновляем данные в кэше
 	if len(notInCache) > 0 {
+		// orders deleted or saved while loading aren't cached
+		generation := c.opts.Generations.Begin()
+		defer c.opts.Generations.End()
+
+		start := c.opts.Clock.Now()
 		ordersMap, err := c.orderRepository.Get(ctx, notInCache)
+		c.opts.Metrics.Load(len(ordersMap), c.opts.Clock.Since(start), err)
 		if err != nil {
-			return nil, fmt.Errorf("err from repository: %s", err.Error())
+			span.RecordError(err)
+			return nil, fmt.Errorf("err from repository: %w", err)
+		}
+		loadCost := c.opts.Clock.Since(start) / time.Duration(len(notInCache))
+
+		// request was cancelled while loading, its orders aren't cached
+		if err := ctx.Err(); err != nil {
+			span.RecordError(err)
+			return nil, err
 		}
 
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(100)
+
 		for _, ord := range ordersMap {
 			ord := ord
 			result = append(result, ord)
 
 			g.Go(func() error {
-				_ = c.cache.Add(ord.ID, &ord)
+				if err := gctx.Err(); err != nil {
+					return err
+				}
+
+				if c.opts.Generations.AddIfValid(ord.ID, generation, func() {
+					_ = c.opts.TTLPolicy.Add(c.cache, ord.ID, &ord, loadCost)
+				}) {
+					c.opts.Registry.Tag(&ord)
+				}
 
 				return nil
 			})
 		}
-		_ = g.Wait()
+		if err := g.Wait(); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
 
-		log.Debug().Msgf("get from db", "count", len(ordersMap))
+		log.Debug().Int("count", len(ordersMap)).Msg("get from db")
 	}
 
 	return result, nil
 }
 
 func (c *RefreshAheadCache) Add(ctx context.Context, order *order.Order) error {
+	ctx, span := tracing.Start(ctx, "refresh_ahead.Add")
+	defer span.End()
+
 	orderID, err := c.orderRepository.Save(ctx, order)
 	if err != nil {
+		span.RecordError(err)
 		return errors.Wrap(err, "orderRepository.Save")
 	}
 
-	_ = c.cache.Add(orderID, order)
+	// loads in flight have the previous version, they don't overwrite the saved one
+	c.opts.Generations.Invalidate(orderID)
+	_ = c.opts.TTLPolicy.Add(c.cache, orderID, order, 0)
+	c.opts.Registry.Tag(order)
+
+	return nil
+}
+
+// Delete removes order from db and cache, cache is cleaned even if order wasn't found in db
+func (c *RefreshAheadCache) Delete(ctx context.Context, ID uint64) error {
+	ctx, span := tracing.Start(ctx, "refresh_ahead.Delete")
+	defer span.End()
+
+	err := c.orderRepository.Delete(ctx, ID)
+	c.Invalidate(ID)
+	if err != nil {
+		span.RecordError(err)
+		return errors.Wrap(err, "orderRepository.Delete")
+	}
 
 	return nil
 }
+
+// RemainingTTL returns time left until the cached order expires, false if it isn't cached
+func (c *RefreshAheadCache) RemainingTTL(ID uint64) (time.Duration, bool) {
+	return c.cache.TTL(ID)
+}
+
+// Invalidate removes order from cache and cascades to dependent entries,
+// loads in flight, including watcher refresh, don't bring it back
+func (c *RefreshAheadCache) Invalidate(ID uint64) {
+	c.opts.Generations.Invalidate(ID)
+	_ = c.cache.Remove(ID)
+	c.opts.Registry.Invalidate(ID)
+}
+
+// InvalidateTag removes all orders with the tag, returns their count
+func (c *RefreshAheadCache) InvalidateTag(tag string) int {
+	return c.opts.Registry.InvalidateTag(tag)
+}
@@ -0,0 +1,382 @@
+package chaos
+
+import (
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OpGet        = "get"
+	OpSave       = "save"
+	OpListByItem = "list_by_item"
+	OpList       = "list"
+	OpDelete     = "delete"
+)
+
+// Ops are all repository operations
+var Ops = []string{OpGet, OpSave, OpListByItem, OpList, OpDelete}
+
+var (
+	// ErrInjected is a random failure of a call
+	ErrInjected = errors.New("injected repository error")
+	// ErrTimeout is returned by a call which hung for Faults.Timeout
+	ErrTimeout = errors.New("injected repository timeout")
+	// ErrOutage is returned by every call during outage window
+	ErrOutage = errors.New("repository outage")
+)
+
+// PartialError is returned by Get when some IDs of the batch failed, orders of the rest are returned with it
+type PartialError struct {
+	Failed []uint64
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("injected failure of %d ids of batch", len(e.Failed))
+}
+
+func (e *PartialError) Unwrap() error {
+	return ErrInjected
+}
+
+type OrderRepoI interface {
+	Save(ctx context.Context, order *order.Order) (uint64, error)
+	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+	ListByItem(ctx context.Context, item string) ([]uint64, error)
+	List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error)
+	Delete(ctx context.Context, ID uint64) error
+}
+
+// Faults of an operation, zero value injects nothing
+type Faults struct {
+	// Latency is added to every call, nil adds nothing
+	Latency Latency
+	// ErrorRate is a share of calls failing with ErrInjected
+	ErrorRate float64
+	// TimeoutRate is a share of calls hanging for Timeout and failing with ErrTimeout
+	TimeoutRate float64
+	Timeout     time.Duration
+	// PartialRate is a share of IDs of Get batch failing with PartialError, the rest are loaded
+	PartialRate float64
+}
+
+func (f Faults) Validate() error {
+	for name, rate := range map[string]float64{
+		"error rate":   f.ErrorRate,
+		"timeout rate": f.TimeoutRate,
+		"partial rate": f.PartialRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s must be in range [0, 1], got %g", name, rate)
+		}
+	}
+	if f.TimeoutRate > 0 && f.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %s", f.Timeout)
+	}
+	return nil
+}
+
+// RegisterFlags binds faults to command line flags
+func (f *Faults) RegisterFlags(fs *flag.FlagSet) {
+	fs.Func("latency", "extra repository latency: fixed:1ms, uniform:1ms,5ms, normal:5ms,1ms, exp:2ms, pareto:1ms,1.5, bimodal:1ms,50ms,0.05",
+		func(s string) (err error) {
+			f.Latency, err = ParseLatency(s)
+			return err
+		})
+	fs.Float64Var(&f.ErrorRate, "error-rate", f.ErrorRate, "share of failing repository calls")
+	fs.Float64Var(&f.TimeoutRate, "timeout-rate", f.TimeoutRate, "share of hanging repository calls")
+	fs.DurationVar(&f.Timeout, "timeout", f.Timeout, "how long hanging repository call takes")
+	fs.Float64Var(&f.PartialRate, "partial-rate", f.PartialRate, "share of failing IDs of repository batch")
+}
+
+// Window is an outage from From to To since Schedule
+type Window struct {
+	From, To time.Duration
+}
+
+// ParseWindows parses comma separated outages like 1s-2s,5s-7s
+func ParseWindows(s string) ([]Window, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var windows []Window
+	for _, w := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(w, "-")
+		if !ok {
+			return nil, fmt.Errorf("outage %q: expected from-to", w)
+		}
+		fromD, err := time.ParseDuration(from)
+		if err != nil {
+			return nil, fmt.Errorf("outage %q: %w", w, err)
+		}
+		toD, err := time.ParseDuration(to)
+		if err != nil {
+			return nil, fmt.Errorf("outage %q: %w", w, err)
+		}
+		if fromD < 0 || toD <= fromD {
+			return nil, fmt.Errorf("outage %q: expected 0 <= from < to", w)
+		}
+		windows = append(windows, Window{From: fromD, To: toD})
+	}
+	return windows, nil
+}
+
+// Stats are counters of injected faults
+type Stats struct {
+	Calls      int
+	Errors     int
+	Timeouts   int
+	Outages    int
+	PartialIDs int
+}
+
+// Repo is a repository decorator injecting latency, errors, timeouts, partial failures and outages.
+// Faults can be changed at any moment, so tests script how sick the DB is at every phase.
+type Repo struct {
+	orderRepository OrderRepoI
+	clock           clock.Clock
+
+	mu      sync.Mutex
+	rnd     *rand.Rand
+	faults  map[string]Faults
+	origin  time.Time
+	windows []Window
+	stats   Stats
+}
+
+// Option configures Repo
+type Option func(*Repo)
+
+// WithClock replaces real clock of latency, timeouts and outage windows
+func WithClock(c clock.Clock) Option {
+	return func(r *Repo) {
+		r.clock = c
+	}
+}
+
+// WithSeed makes injected faults reproducible
+func WithSeed(seed int64) Option {
+	return func(r *Repo) {
+		r.rnd = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithFaults injects faults into every operation
+func WithFaults(f Faults) Option {
+	return func(r *Repo) {
+		for _, op := range Ops {
+			r.faults[op] = f
+		}
+	}
+}
+
+// WithOpFaults injects faults into a single operation
+func WithOpFaults(op string, f Faults) Option {
+	return func(r *Repo) {
+		r.faults[op] = f
+	}
+}
+
+func New(orderRepository OrderRepoI, opts ...Option) *Repo {
+	r := &Repo{
+		orderRepository: orderRepository,
+		clock:           clock.Real(),
+		rnd:             rand.New(rand.NewSource(time.Now().UnixNano())),
+		faults:          make(map[string]Faults, len(Ops)),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Set replaces faults of every operation
+func (r *Repo) Set(f Faults) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, op := range Ops {
+		r.faults[op] = f
+	}
+}
+
+// SetOp replaces faults of a single operation
+func (r *Repo) SetOp(op string, f Faults) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.faults[op] = f
+}
+
+// Schedule replaces outage windows, their offsets are counted from now
+func (r *Repo) Schedule(windows ...Window) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.origin = r.clock.Now()
+	r.windows = windows
+}
+
+// Outage fails every call for d starting now
+func (r *Repo) Outage(d time.Duration) {
+	r.Schedule(Window{From: 0, To: d})
+}
+
+// Heal removes all faults and outages
+func (r *Repo) Heal() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.faults = make(map[string]Faults, len(Ops))
+	r.windows = nil
+}
+
+func (r *Repo) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stats
+}
+
+func (r *Repo) Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error) {
+	if err := r.inject(ctx, OpGet); err != nil {
+		return nil, err
+	}
+
+	failed := r.partial(IDs)
+	if len(failed) == 0 {
+		return r.orderRepository.Get(ctx, IDs)
+	}
+
+	skip := make(map[uint64]struct{}, len(failed))
+	for _, ID := range failed {
+		skip[ID] = struct{}{}
+	}
+	rest := make([]uint64, 0, len(IDs)-len(failed))
+	for _, ID := range IDs {
+		if _, ok := skip[ID]; !ok {
+			rest = append(rest, ID)
+		}
+	}
+
+	ordersMap := make(map[uint64]order.Order)
+	if len(rest) > 0 {
+		var err error
+		if ordersMap, err = r.orderRepository.Get(ctx, rest); err != nil {
+			return nil, err
+		}
+	}
+	return ordersMap, &PartialError{Failed: failed}
+}
+
+func (r *Repo) Save(ctx context.Context, order *order.Order) (uint64, error) {
+	if err := r.inject(ctx, OpSave); err != nil {
+		return 0, err
+	}
+	return r.orderRepository.Save(ctx, order)
+}
+
+func (r *Repo) ListByItem(ctx context.Context, item string) ([]uint64, error) {
+	if err := r.inject(ctx, OpListByItem); err != nil {
+		return nil, err
+	}
+	return r.orderRepository.ListByItem(ctx, item)
+}
+
+func (r *Repo) List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error) {
+	if err := r.inject(ctx, OpList); err != nil {
+		return nil, 0, err
+	}
+	return r.orderRepository.List(ctx, cursor, limit)
+}
+
+func (r *Repo) Delete(ctx context.Context, ID uint64) error {
+	if err := r.inject(ctx, OpDelete); err != nil {
+		return err
+	}
+	return r.orderRepository.Delete(ctx, ID)
+}
+
+// inject sleeps for latency of the call and decides whether it fails,
+// outages fail fast like refused connections, sleeping ends with ctx
+func (r *Repo) inject(ctx context.Context, op string) error {
+	r.mu.Lock()
+	r.stats.Calls++
+	if r.down() {
+		r.stats.Outages++
+		r.mu.Unlock()
+		return ErrOutage
+	}
+
+	f := r.faults[op]
+	var latency time.Duration
+	if f.Latency != nil {
+		latency = f.Latency.Sample(r.rnd)
+	}
+	timeout := f.TimeoutRate > 0 && r.rnd.Float64() < f.TimeoutRate
+	fail := !timeout && f.ErrorRate > 0 && r.rnd.Float64() < f.ErrorRate
+	if timeout {
+		r.stats.Timeouts++
+	}
+	if fail {
+		r.stats.Errors++
+	}
+	r.mu.Unlock()
+
+	if timeout {
+		if err := r.clock.SleepContext(ctx, f.Timeout); err != nil {
+			return err
+		}
+		return ErrTimeout
+	}
+	if err := r.clock.SleepContext(ctx, latency); err != nil {
+		return err
+	}
+	if fail {
+		return ErrInjected
+	}
+	return nil
+}
+
+// partial picks failing IDs of Get batch
+func (r *Repo) partial(IDs []uint64) []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rate := r.faults[OpGet].PartialRate
+	if rate == 0 {
+		return nil
+	}
+
+	var failed []uint64
+	for _, ID := range IDs {
+		if r.rnd.Float64() < rate {
+			failed = append(failed, ID)
+		}
+	}
+	r.stats.PartialIDs += len(failed)
+	return failed
+}
+
+// down reports whether now is inside an outage window, must be called under r.mu
+func (r *Repo) down() bool {
+	if len(r.windows) == 0 {
+		return false
+	}
+
+	elapsed := r.clock.Since(r.origin)
+	for _, w := range r.windows {
+		if elapsed >= w.From && elapsed < w.To {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package chaos
+
+import (
+	"caching-strategies/internal/clock"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+const ordersNumber = 100
+
+func setup(t *testing.T, opts ...Option) (*Repo, *clock.Fake) {
+	t.Helper()
+
+	fake := clock.NewFake(time.Unix(0, 0))
+	repository := repo.New(repo.WithClock(fake))
+	for i := 0; i < ordersNumber; i++ {
+		if _, err := repository.Save(context.Background(), &order.Order{ID: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(repository, append([]Option{WithClock(fake), WithSeed(1)}, opts...)...), fake
+}
+
+func TestErrorsAndTimeouts(t *testing.T) {
+	ctx := context.Background()
+	r, fake := setup(t, WithOpFaults(OpSave, Faults{ErrorRate: 0.3, TimeoutRate: 0.1, Timeout: time.Second}))
+
+	const calls = 10000
+	var failed, timedOut int
+	for i := 0; i < calls; i++ {
+		start := fake.Now()
+		_, err := r.Save(ctx, &order.Order{ID: uint64(i % ordersNumber)})
+		switch {
+		case errors.Is(err, ErrTimeout):
+			timedOut++
+			if elapsed := fake.Since(start); elapsed != time.Second {
+				t.Fatalf("expected timed out call to hang for 1s, took %s", elapsed)
+			}
+		case errors.Is(err, ErrInjected):
+			failed++
+		case err != nil:
+			t.Fatal(err)
+		}
+	}
+
+	// error rate applies to calls which didn't time out
+	if share := float64(timedOut) / calls; math.Abs(share-0.1) > 0.02 {
+		t.Fatalf("expected 10%% timeouts, got %.3f", share)
+	}
+	if share := float64(failed) / calls; math.Abs(share-0.27) > 0.02 {
+		t.Fatalf("expected 27%% errors, got %.3f", share)
+	}
+	if stats := r.Stats(); stats.Calls != calls || stats.Errors != failed || stats.Timeouts != timedOut {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// other operations are healthy
+	if _, err := r.Get(ctx, []uint64{1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	ctx := context.Background()
+	r, fake := setup(t, WithFaults(Faults{Latency: Uniform(10*time.Millisecond, 20*time.Millisecond)}))
+
+	for i := 0; i < 100; i++ {
+		start := fake.Now()
+		if _, _, err := r.List(ctx, 0, 10); err != nil {
+			t.Fatal(err)
+		}
+		// repository itself sleeps 1ms
+		if elapsed := fake.Since(start) - time.Millisecond; elapsed < 10*time.Millisecond || elapsed >= 20*time.Millisecond {
+			t.Fatalf("latency %s is out of range", elapsed)
+		}
+	}
+}
+
+// hanging call ends with caller deadline instead of the injected timeout
+func TestTimeoutCancellation(t *testing.T) {
+	r := New(repo.New(), WithFaults(Faults{TimeoutRate: 1, Timeout: time.Hour}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := r.Get(ctx, []uint64{1}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected call to end with ctx, took %s", elapsed)
+	}
+}
+
+func TestPartialFailure(t *testing.T) {
+	ctx := context.Background()
+	r, _ := setup(t, WithFaults(Faults{PartialRate: 0.5}))
+
+	IDs := make([]uint64, ordersNumber)
+	for i := range IDs {
+		IDs[i] = uint64(i)
+	}
+
+	ordersMap, err := r.Get(ctx, IDs)
+	var partial *PartialError
+	if !errors.As(err, &partial) || !errors.Is(err, ErrInjected) {
+		t.Fatalf("expected partial error, got %v", err)
+	}
+	if len(partial.Failed) == 0 || len(partial.Failed)+len(ordersMap) != ordersNumber {
+		t.Fatalf("expected every ID to be either failed or loaded, got %d and %d", len(partial.Failed), len(ordersMap))
+	}
+	for _, ID := range partial.Failed {
+		if _, ok := ordersMap[ID]; ok {
+			t.Fatalf("failed ID %d was loaded", ID)
+		}
+	}
+}
+
+// scripted phases: healthy, outage window, healed
+func TestOutage(t *testing.T) {
+	ctx := context.Background()
+	r, fake := setup(t)
+	r.Schedule(Window{From: time.Second, To: 2 * time.Second})
+
+	get := func() error {
+		_, err := r.Get(ctx, []uint64{1})
+		return err
+	}
+
+	if err := get(); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(time.Second)
+	if err := get(); !errors.Is(err, ErrOutage) {
+		t.Fatalf("expected outage, got %v", err)
+	}
+	fake.Advance(time.Second)
+	if err := get(); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Outage(time.Minute)
+	if err := get(); !errors.Is(err, ErrOutage) {
+		t.Fatalf("expected outage, got %v", err)
+	}
+	r.Heal()
+	if err := get(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := r.Stats(); stats.Outages != 2 {
+		t.Fatalf("expected 2 calls during outages, got %+v", stats)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"fixed:1ms", "uniform:1ms,5ms", "normal:5ms,1ms", "exp:2ms", "pareto:1ms,1.5", "bimodal:1ms,50ms,0.05"} {
+		l, err := ParseLatency(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.String() != s {
+			t.Fatalf("expected %s, got %s", s, l)
+		}
+	}
+	for _, s := range []string{"", "fixed", "fixed:1", "uniform:5ms,1ms", "pareto:1ms,0", "bimodal:1ms,50ms,2", "weibull:1ms"} {
+		if _, err := ParseLatency(s); err == nil {
+			t.Fatalf("expected error for %q", s)
+		}
+	}
+
+	windows, err := ParseWindows("1s-2s,5s-7s")
+	if err != nil || len(windows) != 2 || windows[1] != (Window{From: 5 * time.Second, To: 7 * time.Second}) {
+		t.Fatalf("unexpected windows %v: %v", windows, err)
+	}
+	for _, s := range []string{"1s", "2s-1s", "x-1s"} {
+		if _, err := ParseWindows(s); err == nil {
+			t.Fatalf("expected error for %q", s)
+		}
+	}
+
+	if err := (Faults{ErrorRate: 2}).Validate(); err == nil {
+		t.Fatal("expected error for rate above 1")
+	}
+	if err := (Faults{TimeoutRate: 0.1}).Validate(); err == nil {
+		t.Fatal("expected error for timeouts without timeout")
+	}
+}
@@ -0,0 +1,205 @@
+package chaos
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Latency is a distribution of extra delay of a repository call
+type Latency interface {
+	Sample(rnd *rand.Rand) time.Duration
+	String() string
+}
+
+type fixed time.Duration
+
+// Fixed delays every call by d
+func Fixed(d time.Duration) Latency {
+	return fixed(d)
+}
+
+func (l fixed) Sample(*rand.Rand) time.Duration {
+	return time.Duration(l)
+}
+
+func (l fixed) String() string {
+	return "fixed:" + time.Duration(l).String()
+}
+
+type uniform struct {
+	min, max time.Duration
+}
+
+// Uniform delays calls by a duration in range [min, max)
+func Uniform(min, max time.Duration) Latency {
+	return uniform{min: min, max: max}
+}
+
+func (l uniform) Sample(rnd *rand.Rand) time.Duration {
+	if l.max <= l.min {
+		return l.min
+	}
+	return l.min + time.Duration(rnd.Int63n(int64(l.max-l.min)))
+}
+
+func (l uniform) String() string {
+	return fmt.Sprintf("uniform:%s,%s", l.min, l.max)
+}
+
+type normal struct {
+	mean, stddev time.Duration
+}
+
+// Normal delays calls around mean, negative samples are cut to 0
+func Normal(mean, stddev time.Duration) Latency {
+	return normal{mean: mean, stddev: stddev}
+}
+
+func (l normal) Sample(rnd *rand.Rand) time.Duration {
+	return max(0, l.mean+time.Duration(rnd.NormFloat64()*float64(l.stddev)))
+}
+
+func (l normal) String() string {
+	return fmt.Sprintf("normal:%s,%s", l.mean, l.stddev)
+}
+
+type exponential time.Duration
+
+// Exponential delays calls by mean on average, most calls are fast and few are several times slower
+func Exponential(mean time.Duration) Latency {
+	return exponential(mean)
+}
+
+func (l exponential) Sample(rnd *rand.Rand) time.Duration {
+	return time.Duration(rnd.ExpFloat64() * float64(l))
+}
+
+func (l exponential) String() string {
+	return "exp:" + time.Duration(l).String()
+}
+
+type pareto struct {
+	min   time.Duration
+	alpha float64
+}
+
+// Pareto delays calls by at least min with a heavy tail, smaller alpha makes the tail longer
+func Pareto(min time.Duration, alpha float64) Latency {
+	return pareto{min: min, alpha: alpha}
+}
+
+func (l pareto) Sample(rnd *rand.Rand) time.Duration {
+	// inverse transform of 1 - (min/x)^alpha, 1 - Float64 is in (0, 1]
+	return time.Duration(float64(l.min) / math.Pow(1-rnd.Float64(), 1/l.alpha))
+}
+
+func (l pareto) String() string {
+	return fmt.Sprintf("pareto:%s,%g", l.min, l.alpha)
+}
+
+type bimodal struct {
+	fast, slow time.Duration
+	slowRate   float64
+}
+
+// Bimodal delays slowRate share of calls by slow and the rest by fast, like a replica with a long GC pause
+func Bimodal(fast, slow time.Duration, slowRate float64) Latency {
+	return bimodal{fast: fast, slow: slow, slowRate: slowRate}
+}
+
+func (l bimodal) Sample(rnd *rand.Rand) time.Duration {
+	if rnd.Float64() < l.slowRate {
+		return l.slow
+	}
+	return l.fast
+}
+
+func (l bimodal) String() string {
+	return fmt.Sprintf("bimodal:%s,%s,%g", l.fast, l.slow, l.slowRate)
+}
+
+// ParseLatency parses distribution in form of its String:
+// fixed:1ms, uniform:1ms,5ms, normal:5ms,1ms, exp:2ms, pareto:1ms,1.5, bimodal:1ms,50ms,0.05
+func ParseLatency(s string) (Latency, error) {
+	kind, params, _ := strings.Cut(s, ":")
+	args := strings.Split(params, ",")
+
+	durations := func(n int) ([]time.Duration, error) {
+		if len(args) != n {
+			return nil, fmt.Errorf("latency %q: expected %d parameters, got %d", s, n, len(args))
+		}
+		result := make([]time.Duration, n)
+		for i, arg := range args {
+			d, err := time.ParseDuration(arg)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("latency %q: invalid duration %q", s, arg)
+			}
+			result[i] = d
+		}
+		return result, nil
+	}
+
+	switch kind {
+	case "fixed":
+		d, err := durations(1)
+		if err != nil {
+			return nil, err
+		}
+		return Fixed(d[0]), nil
+	case "uniform":
+		d, err := durations(2)
+		if err != nil {
+			return nil, err
+		}
+		if d[1] < d[0] {
+			return nil, fmt.Errorf("latency %q: max is less than min", s)
+		}
+		return Uniform(d[0], d[1]), nil
+	case "normal":
+		d, err := durations(2)
+		if err != nil {
+			return nil, err
+		}
+		return Normal(d[0], d[1]), nil
+	case "exp":
+		d, err := durations(1)
+		if err != nil {
+			return nil, err
+		}
+		return Exponential(d[0]), nil
+	case "pareto":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("latency %q: expected 2 parameters, got %d", s, len(args))
+		}
+		alpha, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || alpha <= 0 {
+			return nil, fmt.Errorf("latency %q: alpha must be positive", s)
+		}
+		args = args[:1]
+		d, err := durations(1)
+		if err != nil {
+			return nil, err
+		}
+		return Pareto(d[0], alpha), nil
+	case "bimodal":
+		if len(args) != 3 {
+			return nil, fmt.Errorf("latency %q: expected 3 parameters, got %d", s, len(args))
+		}
+		rate, err := strconv.ParseFloat(args[2], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("latency %q: slow rate must be in range [0, 1]", s)
+		}
+		args = args[:2]
+		d, err := durations(2)
+		if err != nil {
+			return nil, err
+		}
+		return Bimodal(d[0], d[1], rate), nil
+	default:
+		return nil, fmt.Errorf("unknown latency distribution %q, expected fixed, uniform, normal, exp, pareto or bimodal", kind)
+	}
+}
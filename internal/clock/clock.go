@@ -0,0 +1,69 @@
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is a source of time for caches, strategies and repository,
+// tests replace the real one with Fake to control expiration and refresh
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	// SleepContext sleeps like Sleep but returns ctx error as soon as ctx is done
+	SleepContext(ctx context.Context, d time.Duration) error
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+// Real returns clock of time package
+func Real() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (realClock) SleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
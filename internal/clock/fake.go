@@ -0,0 +1,117 @@
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Fake is a simulated clock, time moves only by Advance and Sleep.
+// Sleep moves time forward instead of blocking, so latency mocks take no real time.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Sleep advances the clock by d and returns immediately
+func (f *Fake) Sleep(d time.Duration) {
+	f.Advance(d)
+}
+
+// SleepContext advances the clock by d unless ctx is already done
+func (f *Fake) SleepContext(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.Advance(d)
+	return nil
+}
+
+// Advance moves time forward and fires due tickers,
+// like time.Ticker a ticker keeps one tick and drops the rest if nobody reads them
+func (f *Fake) Advance(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	for _, t := range f.tickers {
+		t.fire(f.now)
+	}
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for clock.Fake.NewTicker")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTicker{
+		clock:  f,
+		c:      make(chan time.Time, 1),
+		period: d,
+		next:   f.now.Add(d),
+	}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+type fakeTicker struct {
+	clock  *Fake
+	c      chan time.Time
+	period time.Duration
+	// next tick time, guarded by clock.mu
+	next time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, ticker := range t.clock.tickers {
+		if ticker == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+// fire must be called under clock.mu
+func (t *fakeTicker) fire(now time.Time) {
+	if t.next.After(now) {
+		return
+	}
+
+	select {
+	case t.c <- now:
+	default:
+	}
+	for !t.next.After(now) {
+		t.next = t.next.Add(t.period)
+	}
+}
@@ -0,0 +1,84 @@
+package clock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Unix(0, 0)
+	fake := NewFake(start)
+
+	fake.Sleep(time.Second)
+	fake.Advance(time.Second)
+	if since := fake.Since(start); since != 2*time.Second {
+		t.Fatalf("expected 2s to pass, got %s", since)
+	}
+}
+
+// sleeping ends with ctx on both clocks, fake one doesn't move after cancellation
+func TestSleepContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := Real().SleepContext(ctx, time.Hour); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected sleep to end with ctx, took %s", elapsed)
+	}
+
+	fake := NewFake(time.Unix(0, 0))
+	if err := fake.SleepContext(context.Background(), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.SleepContext(ctx, time.Hour); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+	if now := fake.Now(); !now.Equal(time.Unix(0, 0).Add(time.Hour)) {
+		t.Fatalf("expected only the first sleep to advance clock, got %s", now)
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	start := time.Unix(0, 0)
+	fake := NewFake(start)
+	ticker := fake.NewTicker(10 * time.Millisecond)
+
+	fake.Advance(9 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("unexpected tick before period")
+	default:
+	}
+
+	// missed ticks are dropped like in time.Ticker
+	fake.Advance(25 * time.Millisecond)
+	if tick := <-ticker.C(); !tick.Equal(start.Add(34 * time.Millisecond)) {
+		t.Fatalf("unexpected tick time %s", tick)
+	}
+	select {
+	case <-ticker.C():
+		t.Fatal("expected missed ticks to be dropped")
+	default:
+	}
+
+	// next tick keeps the period grid
+	fake.Advance(6 * time.Millisecond)
+	select {
+	case <-ticker.C():
+	default:
+		t.Fatal("expected tick at 40ms")
+	}
+
+	ticker.Stop()
+	fake.Advance(time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("unexpected tick after stop")
+	default:
+	}
+}
@@ -0,0 +1,325 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	StrategyWithoutCache     = "without_cache"
+	StrategyCacheAside       = "cache_aside"
+	StrategyReadWriteThrough = "read_write_through"
+	StrategyRefreshAhead     = "refresh_ahead"
+	StrategyQueryCache       = "query_cache"
+)
+
+const (
+	RepoMemory = "memory"
+	RepoFile   = "file"
+	RepoSQLite = "sqlite"
+)
+
+// Repos are all supported repository backends
+var Repos = []string{RepoMemory, RepoFile, RepoSQLite}
+
+// Strategies are all supported strategies in order of usecases
+var Strategies = []string{
+	StrategyWithoutCache,
+	StrategyCacheAside,
+	StrategyReadWriteThrough,
+	StrategyRefreshAhead,
+	StrategyQueryCache,
+}
+
+// ReadThroughStrategies load cache misses through cache itself, so they honour breaker, limiter and stale serving
+var ReadThroughStrategies = []string{StrategyReadWriteThrough, StrategyQueryCache}
+
+type Config struct {
+	HTTPAddr        string
+	GRPCAddr        string
+	Strategy        string
+	CacheSize       int
+	CacheTTL        time.Duration
+	RefreshChSize   int
+	ShutdownTimeout time.Duration
+
+	// BreakerThreshold consecutive failed repository loads open circuit breaker, 0 disables it
+	BreakerThreshold   int
+	BreakerOpenTimeout time.Duration
+	BreakerProbes      int
+	// RepoConcurrency bounds concurrent repository loads, 0 is unlimited,
+	// RepoQueue loads wait for a slot and the rest are shed
+	RepoConcurrency int
+	RepoQueue       int
+	// StaleTTL keeps orders for serving when repository load fails, 0 disables stale serving
+	StaleTTL time.Duration
+	// HedgeBudget caps duplicate repository loads as a share of loads, 0 disables hedging,
+	// a load is hedged when it takes longer than HedgeQuantile of recent ones
+	HedgeBudget   float64
+	HedgeQuantile float64
+	HedgeBatch    int
+	// LoaderBatch merges concurrent repository loads into batches of up to LoaderBatch IDs, 0 disables merging,
+	// LoaderInFlight loads run at once and collected IDs wait for a free one at most LoaderWindow
+	LoaderBatch    int
+	LoaderInFlight int
+	LoaderWindow   time.Duration
+	// SnapshotPath is a file cache is saved to every SnapshotInterval and on stop,
+	// and restored from on start, empty disables snapshots
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+	// WarmupSource preloads the most accessed orders on start: static:1,2,3, trace:<file> or counts:<file>,
+	// empty disables warm-up. WarmupTop orders are loaded in batches of WarmupBatch, WarmupRate batches per second,
+	// serving waits for warm-up at most WarmupWait
+	WarmupSource string
+	WarmupTop    int
+	WarmupBatch  int
+	WarmupRate   float64
+	WarmupWait   time.Duration
+	// WarmupCountsPath is a file access counts are written to on stop, for counts:<file> source of the next start
+	WarmupCountsPath string
+	// Repo is repository backend: memory, file (append-only log) or sqlite,
+	// RepoPath is the log or database file of persistent ones, orders survive restarts
+	Repo     string
+	RepoPath string
+	// TracePath is a file every usecase call is recorded to, the trace is replayed by cmd/bench and cmd/mrc
+	// to evaluate strategies against production access, empty disables recording
+	TracePath string
+}
+
+func Default() Config {
+	return Config{
+		HTTPAddr:        ":8080",
+		GRPCAddr:        ":9000",
+		Strategy:        StrategyRefreshAhead,
+		Repo:            RepoMemory,
+		CacheSize:       1000,
+		CacheTTL:        3 * time.Second,
+		RefreshChSize:   1000,
+		ShutdownTimeout: 10 * time.Second,
+
+		BreakerOpenTimeout: time.Second,
+		BreakerProbes:      1,
+		HedgeQuantile:      0.95,
+		HedgeBatch:         10,
+		LoaderInFlight:     4,
+		LoaderWindow:       time.Millisecond,
+		SnapshotInterval:   time.Minute,
+		WarmupTop:          1000,
+		WarmupBatch:        100,
+		WarmupRate:         10,
+		WarmupWait:         10 * time.Second,
+	}
+}
+
+// Load reads config from env, unset variables keep default values
+func Load() (Config, error) {
+	cfg := Default()
+
+	cfg.HTTPAddr = env("HTTP_ADDR", cfg.HTTPAddr)
+	cfg.GRPCAddr = env("GRPC_ADDR", cfg.GRPCAddr)
+	cfg.Strategy = env("STRATEGY", cfg.Strategy)
+	cfg.SnapshotPath = env("SNAPSHOT_PATH", cfg.SnapshotPath)
+	cfg.WarmupSource = env("WARMUP_SOURCE", cfg.WarmupSource)
+	cfg.WarmupCountsPath = env("WARMUP_COUNTS_PATH", cfg.WarmupCountsPath)
+	cfg.Repo = env("REPO", cfg.Repo)
+	cfg.RepoPath = env("REPO_PATH", cfg.RepoPath)
+	cfg.TracePath = env("TRACE_PATH", cfg.TracePath)
+
+	var err error
+	if cfg.CacheSize, err = envInt("CACHE_SIZE", cfg.CacheSize); err != nil {
+		return Config{}, err
+	}
+	if cfg.CacheTTL, err = envDuration("CACHE_TTL", cfg.CacheTTL); err != nil {
+		return Config{}, err
+	}
+	if cfg.RefreshChSize, err = envInt("REFRESH_CH_SIZE", cfg.RefreshChSize); err != nil {
+		return Config{}, err
+	}
+	if cfg.ShutdownTimeout, err = envDuration("SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout); err != nil {
+		return Config{}, err
+	}
+	if cfg.BreakerThreshold, err = envInt("BREAKER_THRESHOLD", cfg.BreakerThreshold); err != nil {
+		return Config{}, err
+	}
+	if cfg.BreakerOpenTimeout, err = envDuration("BREAKER_OPEN_TIMEOUT", cfg.BreakerOpenTimeout); err != nil {
+		return Config{}, err
+	}
+	if cfg.BreakerProbes, err = envInt("BREAKER_PROBES", cfg.BreakerProbes); err != nil {
+		return Config{}, err
+	}
+	if cfg.RepoConcurrency, err = envInt("REPO_CONCURRENCY", cfg.RepoConcurrency); err != nil {
+		return Config{}, err
+	}
+	if cfg.RepoQueue, err = envInt("REPO_QUEUE", cfg.RepoQueue); err != nil {
+		return Config{}, err
+	}
+	if cfg.StaleTTL, err = envDuration("STALE_TTL", cfg.StaleTTL); err != nil {
+		return Config{}, err
+	}
+	if cfg.HedgeBudget, err = envFloat("HEDGE_BUDGET", cfg.HedgeBudget); err != nil {
+		return Config{}, err
+	}
+	if cfg.HedgeQuantile, err = envFloat("HEDGE_QUANTILE", cfg.HedgeQuantile); err != nil {
+		return Config{}, err
+	}
+	if cfg.HedgeBatch, err = envInt("HEDGE_BATCH", cfg.HedgeBatch); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoaderBatch, err = envInt("LOADER_BATCH", cfg.LoaderBatch); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoaderInFlight, err = envInt("LOADER_IN_FLIGHT", cfg.LoaderInFlight); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoaderWindow, err = envDuration("LOADER_WINDOW", cfg.LoaderWindow); err != nil {
+		return Config{}, err
+	}
+	if cfg.SnapshotInterval, err = envDuration("SNAPSHOT_INTERVAL", cfg.SnapshotInterval); err != nil {
+		return Config{}, err
+	}
+	if cfg.WarmupTop, err = envInt("WARMUP_TOP", cfg.WarmupTop); err != nil {
+		return Config{}, err
+	}
+	if cfg.WarmupBatch, err = envInt("WARMUP_BATCH", cfg.WarmupBatch); err != nil {
+		return Config{}, err
+	}
+	if cfg.WarmupRate, err = envFloat("WARMUP_RATE", cfg.WarmupRate); err != nil {
+		return Config{}, err
+	}
+	if cfg.WarmupWait, err = envDuration("WARMUP_WAIT", cfg.WarmupWait); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func (c Config) Validate() error {
+	found := false
+	for _, strategy := range Strategies {
+		if c.Strategy == strategy {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown strategy %q, expected one of %v", c.Strategy, Strategies)
+	}
+	if !slices.Contains(Repos, c.Repo) {
+		return fmt.Errorf("unknown repository %q, expected one of %v", c.Repo, Repos)
+	}
+	if c.Repo != RepoMemory && c.RepoPath == "" {
+		return fmt.Errorf("%s repository needs a path", c.Repo)
+	}
+	if c.CacheSize <= 0 {
+		return fmt.Errorf("cache size must be positive, got %d", c.CacheSize)
+	}
+	if c.CacheTTL <= 0 {
+		return fmt.Errorf("cache ttl must be positive, got %s", c.CacheTTL)
+	}
+	if c.RefreshChSize <= 0 {
+		return fmt.Errorf("refresh channel size must be positive, got %d", c.RefreshChSize)
+	}
+	if c.BreakerThreshold < 0 {
+		return fmt.Errorf("breaker threshold must not be negative, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerThreshold > 0 {
+		if c.BreakerOpenTimeout <= 0 {
+			return fmt.Errorf("breaker open timeout must be positive, got %s", c.BreakerOpenTimeout)
+		}
+		if c.BreakerProbes <= 0 {
+			return fmt.Errorf("breaker probes must be positive, got %d", c.BreakerProbes)
+		}
+	}
+	if c.RepoConcurrency < 0 || c.RepoQueue < 0 {
+		return fmt.Errorf("repository concurrency and queue must not be negative, got %d and %d", c.RepoConcurrency, c.RepoQueue)
+	}
+	if c.StaleTTL < 0 {
+		return fmt.Errorf("stale ttl must not be negative, got %s", c.StaleTTL)
+	}
+	// only read-through loads are guarded, other strategies would silently ignore them
+	if (c.BreakerThreshold > 0 || c.RepoConcurrency > 0 || c.StaleTTL > 0) && !slices.Contains(ReadThroughStrategies, c.Strategy) {
+		return fmt.Errorf("breaker, repository concurrency and stale ttl are supported only by %v strategies, got %s",
+			ReadThroughStrategies, c.Strategy)
+	}
+	if c.HedgeBudget < 0 {
+		return fmt.Errorf("hedge budget must not be negative, got %g", c.HedgeBudget)
+	}
+	if c.HedgeBudget > 0 {
+		if c.HedgeQuantile <= 0 || c.HedgeQuantile >= 1 {
+			return fmt.Errorf("hedge quantile must be in range (0, 1), got %g", c.HedgeQuantile)
+		}
+		if c.HedgeBatch <= 0 {
+			return fmt.Errorf("hedge batch must be positive, got %d", c.HedgeBatch)
+		}
+	}
+	if c.LoaderBatch < 0 {
+		return fmt.Errorf("loader batch must not be negative, got %d", c.LoaderBatch)
+	}
+	if c.LoaderBatch > 0 {
+		if c.LoaderInFlight <= 0 {
+			return fmt.Errorf("loader in flight must be positive, got %d", c.LoaderInFlight)
+		}
+		if c.LoaderWindow < 0 {
+			return fmt.Errorf("loader window must not be negative, got %s", c.LoaderWindow)
+		}
+	}
+	if c.SnapshotPath != "" && c.SnapshotInterval <= 0 {
+		return fmt.Errorf("snapshot interval must be positive, got %s", c.SnapshotInterval)
+	}
+	if c.WarmupSource != "" {
+		if c.WarmupTop < 0 || c.WarmupBatch <= 0 || c.WarmupRate < 0 {
+			return fmt.Errorf("warmup top and rate must not be negative and batch must be positive, got %d, %g and %d",
+				c.WarmupTop, c.WarmupRate, c.WarmupBatch)
+		}
+		if c.WarmupWait < 0 {
+			return fmt.Errorf("warmup wait must not be negative, got %s", c.WarmupWait)
+		}
+	}
+	return nil
+}
+
+func env(name, def string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return def
+}
+
+func envInt(name string, def int) (int, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return def, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return v, nil
+}
+
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return def, nil
+	}
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return v, nil
+}
+
+func envFloat(name string, def float64) (float64, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return v, nil
+}
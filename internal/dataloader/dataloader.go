@@ -0,0 +1,231 @@
+package dataloader
+
+import (
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type OrderRepoI interface {
+	Save(ctx context.Context, order *order.Order) (uint64, error)
+	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+	ListByItem(ctx context.Context, item string) ([]uint64, error)
+	List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error)
+	Delete(ctx context.Context, ID uint64) error
+}
+
+// Policy decides when collected IDs are loaded
+type Policy struct {
+	// MaxBatch IDs are loaded at once without waiting
+	MaxBatch int
+	// MaxInFlight loads run concurrently, while all of them are busy IDs are collected into the next batch
+	MaxInFlight int
+	// Window is the longest time IDs wait for a busy repository, 0 waits until a load finishes
+	Window time.Duration
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxBatch:    100,
+		MaxInFlight: 4,
+		Window:      time.Millisecond,
+	}
+}
+
+func (p Policy) Validate() error {
+	if p.MaxBatch <= 0 {
+		return fmt.Errorf("dataloader max batch must be positive, got %d", p.MaxBatch)
+	}
+	if p.MaxInFlight <= 0 {
+		return fmt.Errorf("dataloader max in flight must be positive, got %d", p.MaxInFlight)
+	}
+	if p.Window < 0 {
+		return fmt.Errorf("dataloader window must not be negative, got %s", p.Window)
+	}
+	return nil
+}
+
+// Stats are counters of Get calls, repository loads made for them and IDs loaded
+type Stats struct {
+	Calls int
+	Loads int
+	IDs   int
+}
+
+// Loader is a repository decorator merging concurrent Get calls into a single repository load.
+// Batching is adaptive: while repository has a free slot a call is loaded at once, so a lone caller
+// waits for nothing, and batches grow only when loads queue up. Other methods are passed through.
+type Loader struct {
+	OrderRepoI
+	policy Policy
+	clock  clock.Clock
+
+	mu       sync.Mutex
+	pending  *batch
+	inFlight int
+	stats    Stats
+}
+
+// batch is IDs of callers loaded together, result is written before done is closed
+type batch struct {
+	IDs  []uint64
+	seen map[uint64]struct{}
+	// refs are callers still waiting for the batch, the load is cancelled when all of them leave
+	refs int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	ordersMap map[uint64]order.Order
+	err       error
+}
+
+// Option configures Loader
+type Option func(*Loader)
+
+// WithClock replaces real clock of batch window
+func WithClock(c clock.Clock) Option {
+	return func(l *Loader) {
+		l.clock = c
+	}
+}
+
+func New(orderRepository OrderRepoI, policy Policy, opts ...Option) (*Loader, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	l := &Loader{
+		OrderRepoI: orderRepository,
+		policy:     policy,
+		clock:      clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l, nil
+}
+
+func (l *Loader) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stats
+}
+
+func (l *Loader) Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error) {
+	if len(IDs) == 0 {
+		return make(map[uint64]order.Order), nil
+	}
+
+	b := l.join(ctx, IDs)
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		l.leave(b)
+		return nil, ctx.Err()
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	ordersMap := make(map[uint64]order.Order, len(IDs))
+	for _, ID := range IDs {
+		if ord, ok := b.ordersMap[ID]; ok {
+			ordersMap[ID] = ord
+		}
+	}
+	return ordersMap, nil
+}
+
+// join adds IDs to the pending batch and dispatches it if it's full or repository has a free slot
+func (l *Loader) join(ctx context.Context, IDs []uint64) *batch {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.Calls++
+	b := l.pending
+	if b == nil {
+		b = &batch{
+			seen: make(map[uint64]struct{}),
+			done: make(chan struct{}),
+		}
+		// the load outlives the first caller if others wait for it, trace values are kept
+		b.ctx, b.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		l.pending = b
+	}
+	b.refs++
+	for _, ID := range IDs {
+		if _, ok := b.seen[ID]; !ok {
+			b.seen[ID] = struct{}{}
+			b.IDs = append(b.IDs, ID)
+		}
+	}
+
+	switch {
+	case len(b.IDs) >= l.policy.MaxBatch || l.inFlight < l.policy.MaxInFlight:
+		l.dispatchLocked()
+	case b.refs == 1 && l.policy.Window > 0:
+		go l.expire(b)
+	}
+	return b
+}
+
+// expire dispatches the batch after window even if repository is busy
+func (l *Loader) expire(b *batch) {
+	if l.clock.SleepContext(b.ctx, l.policy.Window) != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pending == b {
+		l.dispatchLocked()
+	}
+}
+
+// leave is called by a caller which gave up, batch nobody waits for is dropped or cancelled
+func (l *Loader) leave(b *batch) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b.refs--
+	if b.refs > 0 {
+		return
+	}
+	if l.pending == b {
+		l.pending = nil
+	}
+	b.cancel()
+}
+
+// dispatchLocked must be called under l.mu
+func (l *Loader) dispatchLocked() {
+	b := l.pending
+	l.pending = nil
+	l.inFlight++
+	l.stats.Loads++
+	l.stats.IDs += len(b.IDs)
+
+	go l.load(b)
+}
+
+func (l *Loader) load(b *batch) {
+	b.ordersMap, b.err = l.OrderRepoI.Get(b.ctx, b.IDs)
+	close(b.done)
+	b.cancel()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	// IDs collected while repository was busy go right into the freed slot
+	if l.pending != nil {
+		l.dispatchLocked()
+	}
+}
@@ -0,0 +1,148 @@
+package dataloader
+
+import (
+	"caching-strategies/internal/chaos"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const ordersNumber = 100
+
+// setup returns loader over repository where every call takes 5ms regardless of batch size
+func setup(t *testing.T, policy Policy) (*Loader, *chaos.Repo) {
+	t.Helper()
+
+	repository := repo.New()
+	for i := 0; i < ordersNumber; i++ {
+		if _, err := repository.Save(context.Background(), &order.Order{ID: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sick := chaos.New(repository, chaos.WithSeed(1),
+		chaos.WithOpFaults(chaos.OpGet, chaos.Faults{Latency: chaos.Fixed(5 * time.Millisecond)}))
+	l, err := New(sick, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, sick
+}
+
+func TestBatching(t *testing.T) {
+	l, sick := setup(t, DefaultPolicy())
+
+	const callers = 1000
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// every ID is requested by 10 callers, one is missing
+			ID := uint64(i % (ordersNumber + 1))
+			ordersMap, err := l.Get(context.Background(), []uint64{ID})
+			switch {
+			case err != nil:
+				errs <- err
+			case ID == ordersNumber && len(ordersMap) != 0:
+				errs <- errors.New("expected missing order to be skipped")
+			case ID < ordersNumber && (len(ordersMap) != 1 || ordersMap[ID].ID != ID):
+				errs <- errors.New("expected caller to get only its own order")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	stats := l.Stats()
+	if stats.Calls != callers {
+		t.Fatalf("expected %d calls, got %+v", callers, stats)
+	}
+	if calls := sick.Stats().Calls; calls != stats.Loads || calls > callers/10 {
+		t.Fatalf("expected concurrent calls to be merged into few loads, got %d repository calls, %+v", calls, stats)
+	}
+	if stats.IDs > stats.Loads*(ordersNumber+1) {
+		t.Fatalf("expected IDs to be deduplicated in batch, got %+v", stats)
+	}
+}
+
+func TestLoneCaller(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.Window = time.Second
+	l, _ := setup(t, policy)
+
+	// repository is idle, the call isn't held for the window
+	start := time.Now()
+	if _, err := l.Get(context.Background(), []uint64{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected lone call to be loaded at once, took %s", elapsed)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	policy := Policy{MaxBatch: 100, MaxInFlight: 1, Window: 10 * time.Millisecond}
+	l, sick := setup(t, policy)
+	// the first load holds the only slot for 100ms
+	sick.SetOp(chaos.OpGet, chaos.Faults{Latency: chaos.Fixed(100 * time.Millisecond)})
+
+	go l.Get(context.Background(), []uint64{1})
+	time.Sleep(5 * time.Millisecond)
+
+	start := time.Now()
+	sick.SetOp(chaos.OpGet, chaos.Faults{})
+	if _, err := l.Get(context.Background(), []uint64{2}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= 80*time.Millisecond {
+		t.Fatalf("expected batch to be loaded after window, took %s", elapsed)
+	}
+}
+
+func TestErrorsAndCancellation(t *testing.T) {
+	policy := Policy{MaxBatch: 100, MaxInFlight: 1}
+	l, sick := setup(t, policy)
+	sick.SetOp(chaos.OpGet, chaos.Faults{ErrorRate: 1, Latency: chaos.Fixed(50 * time.Millisecond)})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := l.Get(context.Background(), []uint64{uint64(i)}); !errors.Is(err, chaos.ErrInjected) {
+				t.Errorf("expected load error to be returned to every caller, got %v", err)
+			}
+		}()
+	}
+
+	// the caller gives up waiting for the busy repository
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	time.Sleep(5 * time.Millisecond)
+	if _, err := l.Get(ctx, []uint64{10}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	wg.Wait()
+}
+
+func TestPolicyValidate(t *testing.T) {
+	for name, policy := range map[string]Policy{
+		"batch":     {MaxBatch: 0, MaxInFlight: 1},
+		"in flight": {MaxBatch: 1, MaxInFlight: 0},
+		"window":    {MaxBatch: 1, MaxInFlight: 1, Window: -time.Millisecond},
+	} {
+		if _, err := New(repo.New(), policy); err == nil {
+			t.Fatalf("%s: expected invalid policy to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+//go:generate protoc -I ../../api --go_out=module=caching-strategies:../.. --go-grpc_out=module=caching-strategies:../.. ../../api/order/v1/order.proto
+
+package grpcapi
+
+import (
+	"caching-strategies/internal/grpcapi/orderpb"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+// events buffered per watcher, slow watcher is disconnected when buffer is full
+const watchBufferSize = 100
+
+type UsecaseI interface {
+	Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
+	Save(ctx context.Context, order *order.Order) error
+	Delete(ctx context.Context, ID uint64) error
+}
+
+// Server implements OrderService over the order usecase
+type Server struct {
+	orderpb.UnimplementedOrderServiceServer
+
+	usecase UsecaseI
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+type subscriber struct {
+	// empty filter means all orders
+	IDs    map[uint64]struct{}
+	events chan *orderpb.OrderEvent
+	// closed when subscriber is dropped by server
+	done chan struct{}
+	err  error
+}
+
+func New(usecase UsecaseI) *Server {
+	return &Server{
+		usecase:     usecase,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func (s *Server) BatchGet(ctx context.Context, req *orderpb.BatchGetRequest) (*orderpb.BatchGetResponse, error) {
+	if len(req.GetIds()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "ids are required")
+	}
+
+	orders, err := s.usecase.Get(ctx, req.GetIds())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	found := make(map[uint64]struct{}, len(orders))
+	resp := &orderpb.BatchGetResponse{Orders: make([]*orderpb.Order, 0, len(orders))}
+	for i := range orders {
+		found[orders[i].ID] = struct{}{}
+		resp.Orders = append(resp.Orders, toProto(&orders[i]))
+	}
+	for _, ID := range req.GetIds() {
+		if _, ok := found[ID]; !ok {
+			resp.Missing = append(resp.Missing, ID)
+		}
+	}
+
+	return resp, nil
+}
+
+func (s *Server) Save(ctx context.Context, req *orderpb.SaveRequest) (*orderpb.SaveResponse, error) {
+	if req.GetOrder() == nil {
+		return nil, status.Error(codes.InvalidArgument, "order is required")
+	}
+
+	ord := fromProto(req.GetOrder())
+	if err := s.usecase.Save(ctx, ord); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	pbOrder := toProto(ord)
+	s.publish(&orderpb.OrderEvent{Type: orderpb.OrderEvent_TYPE_SAVED, Order: pbOrder})
+
+	return &orderpb.SaveResponse{Order: pbOrder}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *orderpb.DeleteRequest) (*orderpb.DeleteResponse, error) {
+	err := s.usecase.Delete(ctx, req.GetId())
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	s.publish(&orderpb.OrderEvent{
+		Type:  orderpb.OrderEvent_TYPE_DELETED,
+		Order: &orderpb.Order{Id: req.GetId()},
+	})
+
+	return &orderpb.DeleteResponse{}, nil
+}
+
+func (s *Server) Watch(req *orderpb.WatchRequest, stream orderpb.OrderService_WatchServer) error {
+	sub, err := s.subscribe(req.GetIds())
+	if err != nil {
+		return err
+	}
+	defer s.unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-sub.done:
+			return sub.err
+		case event := <-sub.events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close disconnects watchers, so grpc.Server.GracefulStop doesn't wait for endless streams
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for sub := range s.subscribers {
+		s.dropLocked(sub, status.Error(codes.Unavailable, "server is shutting down"))
+	}
+}
+
+func (s *Server) subscribe(IDs []uint64) (*subscriber, error) {
+	sub := &subscriber{
+		IDs:    make(map[uint64]struct{}, len(IDs)),
+		events: make(chan *orderpb.OrderEvent, watchBufferSize),
+		done:   make(chan struct{}),
+	}
+	for _, ID := range IDs {
+		sub.IDs[ID] = struct{}{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	s.subscribers[sub] = struct{}{}
+
+	return sub, nil
+}
+
+func (s *Server) unsubscribe(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscribers, sub)
+}
+
+func (s *Server) publish(event *orderpb.OrderEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subscribers {
+		if len(sub.IDs) > 0 {
+			if _, ok := sub.IDs[event.GetOrder().GetId()]; !ok {
+				continue
+			}
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			log.Warn().Msg("order watcher is too slow, disconnecting")
+			s.dropLocked(sub, status.Error(codes.ResourceExhausted, "watcher is too slow"))
+		}
+	}
+}
+
+// dropLocked must be called under s.mu
+func (s *Server) dropLocked(sub *subscriber, err error) {
+	delete(s.subscribers, sub)
+	sub.err = err
+	close(sub.done)
+}
+
+func toProto(ord *order.Order) *orderpb.Order {
+	return &orderpb.Order{
+		Id:         ord.ID,
+		CustomerId: ord.CustomerID,
+		Item:       ord.Item,
+	}
+}
+
+func fromProto(ord *orderpb.Order) *order.Order {
+	return &order.Order{
+		ID:         ord.GetId(),
+		CustomerID: ord.GetCustomerId(),
+		Item:       ord.GetItem(),
+	}
+}
@@ -0,0 +1,138 @@
+package grpcapi_test
+
+import (
+	"caching-strategies/internal/app"
+	"caching-strategies/internal/config"
+	"caching-strategies/internal/grpcapi"
+	"caching-strategies/internal/grpcapi/orderpb"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+	"time"
+)
+
+const bufSize = 1 << 20
+
+func TestOrderService(t *testing.T) {
+	for _, strategy := range config.Strategies {
+		strategy := strategy
+		t.Run(strategy, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			client := setup(t, strategy)
+
+			watch, err := client.Watch(ctx, &orderpb.WatchRequest{Ids: []uint64{1}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, ID := range []uint64{1, 2} {
+				_, err := client.Save(ctx, &orderpb.SaveRequest{Order: &orderpb.Order{Id: ID, CustomerId: 7, Item: "book"}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// cold and warm reads return the same orders
+			for i := 0; i < 2; i++ {
+				resp, err := client.BatchGet(ctx, &orderpb.BatchGetRequest{Ids: []uint64{1, 2, 3}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(resp.GetOrders()) != 2 || resp.GetOrders()[0].GetItem() != "book" {
+					t.Fatalf("unexpected orders: %v", resp.GetOrders())
+				}
+				if len(resp.GetMissing()) != 1 || resp.GetMissing()[0] != 3 {
+					t.Fatalf("expected order 3 to be missing, got %v", resp.GetMissing())
+				}
+			}
+
+			_, err = client.BatchGet(ctx, &orderpb.BatchGetRequest{})
+			expectCode(t, err, codes.InvalidArgument)
+
+			if _, err := client.Delete(ctx, &orderpb.DeleteRequest{Id: 1}); err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.Delete(ctx, &orderpb.DeleteRequest{Id: 1})
+			expectCode(t, err, codes.NotFound)
+
+			resp, err := client.BatchGet(ctx, &orderpb.BatchGetRequest{Ids: []uint64{1}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.GetOrders()) != 0 {
+				t.Fatalf("expected deleted order not to be served from cache, got %v", resp.GetOrders())
+			}
+
+			// watcher receives only changes of order 1
+			for _, expected := range []orderpb.OrderEvent_Type{
+				orderpb.OrderEvent_TYPE_SAVED,
+				orderpb.OrderEvent_TYPE_DELETED,
+			} {
+				event, err := watch.Recv()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if event.GetType() != expected || event.GetOrder().GetId() != 1 {
+					t.Fatalf("expected %s of order 1, got %v", expected, event)
+				}
+			}
+		})
+	}
+}
+
+func setup(t *testing.T, strategy string) orderpb.OrderServiceClient {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Strategy = strategy
+
+	application, err := app.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	application.Start(context.Background())
+
+	service := grpcapi.New(application.Usecase)
+	server := grpc.NewServer()
+	orderpb.RegisterOrderServiceServer(server, service)
+
+	lis := bufconn.Listen(bufSize)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		service.Close()
+		server.GracefulStop()
+		_, _ = application.Stop(context.Background())
+	})
+
+	return orderpb.NewOrderServiceClient(conn)
+}
+
+func expectCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+
+	if status.Code(err) != code {
+		t.Fatalf("expected %s, got %v", code, err)
+	}
+}
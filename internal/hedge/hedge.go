@@ -0,0 +1,276 @@
+package hedge
+
+import (
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// minSamples latencies are observed before the first hedge, delay estimate is noise before that
+const minSamples = 20
+
+type OrderRepoI interface {
+	Save(ctx context.Context, order *order.Order) (uint64, error)
+	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+	ListByItem(ctx context.Context, item string) ([]uint64, error)
+	List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error)
+	Delete(ctx context.Context, ID uint64) error
+}
+
+// Policy decides when a duplicate load is sent
+type Policy struct {
+	// Quantile of observed load latency after which the load is hedged, 0.95 hedges the slowest 5%
+	Quantile float64
+	// MinDelay keeps hedges from firing on noise of very fast loads
+	MinDelay time.Duration
+	// Budget caps hedged loads as a share of primary ones, 0.1 is at most 10% extra DB load
+	Budget float64
+	// BatchSize splits Get into loads of at most BatchSize IDs, so only slow parts of the batch are hedged
+	BatchSize int
+	// Window is the number of recent latencies the quantile is estimated from
+	Window int
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		Quantile:  0.95,
+		MinDelay:  time.Millisecond,
+		Budget:    0.1,
+		BatchSize: 10,
+		Window:    1000,
+	}
+}
+
+func (p Policy) Validate() error {
+	if p.Quantile <= 0 || p.Quantile >= 1 {
+		return fmt.Errorf("hedge quantile must be in range (0, 1), got %g", p.Quantile)
+	}
+	if p.MinDelay < 0 {
+		return fmt.Errorf("hedge min delay must not be negative, got %s", p.MinDelay)
+	}
+	if p.Budget < 0 {
+		return fmt.Errorf("hedge budget must not be negative, got %g", p.Budget)
+	}
+	if p.BatchSize <= 0 {
+		return fmt.Errorf("hedge batch size must be positive, got %d", p.BatchSize)
+	}
+	if p.Window < minSamples {
+		return fmt.Errorf("hedge window must be at least %d, got %d", minSamples, p.Window)
+	}
+	return nil
+}
+
+// Stats are counters of loads, Hedges are extra loads sent and Wins are hedges answered first
+type Stats struct {
+	Loads  int
+	Hedges int
+	Wins   int
+}
+
+// Repo is a repository decorator hedging Get: if a load of the batch part isn't answered
+// within the quantile of recent latencies, the same load is sent again and the first answer is taken,
+// the other one is cancelled. Other methods are passed through.
+type Repo struct {
+	OrderRepoI
+	policy Policy
+	clock  clock.Clock
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+	stats     Stats
+	// delay is recomputed after every window/20 new latencies, not on every load
+	delay      time.Duration
+	unobserved int
+}
+
+// Option configures Repo
+type Option func(*Repo)
+
+// WithClock replaces real clock measuring latency and hedge delay
+func WithClock(c clock.Clock) Option {
+	return func(r *Repo) {
+		r.clock = c
+	}
+}
+
+func New(orderRepository OrderRepoI, policy Policy, opts ...Option) (*Repo, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := &Repo{
+		OrderRepoI: orderRepository,
+		policy:     policy,
+		clock:      clock.Real(),
+		latencies:  make([]time.Duration, 0, policy.Window),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+func (r *Repo) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stats
+}
+
+// Delay is the current hedge delay, 0 until enough latencies are observed
+func (r *Repo) Delay() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.delayLocked()
+}
+
+func (r *Repo) Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error) {
+	if len(IDs) <= r.policy.BatchSize {
+		return r.load(ctx, IDs)
+	}
+
+	parts := make([]map[uint64]order.Order, 0, (len(IDs)+r.policy.BatchSize-1)/r.policy.BatchSize)
+	for start := 0; start < len(IDs); start += r.policy.BatchSize {
+		parts = append(parts, nil)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for i := range parts {
+		batch := IDs[i*r.policy.BatchSize : min((i+1)*r.policy.BatchSize, len(IDs))]
+		g.Go(func() error {
+			var err error
+			parts[i], err = r.load(gctx, batch)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	ordersMap := make(map[uint64]order.Order, len(IDs))
+	for _, part := range parts {
+		for ID, ord := range part {
+			ordersMap[ID] = ord
+		}
+	}
+	return ordersMap, nil
+}
+
+type response struct {
+	ordersMap map[uint64]order.Order
+	err       error
+	hedge     bool
+}
+
+// load sends primary load and a hedge after delay, errors aren't hedged:
+// hedging cuts latency, retries are a different policy
+func (r *Repo) load(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	// cancels the load which lost
+	defer cancel()
+
+	// the load which lost may still read IDs after return, caller is free to reuse its slice
+	IDs = slices.Clone(IDs)
+
+	start := r.clock.Now()
+	responses := make(chan response, 2)
+	send := func(hedge bool) {
+		ordersMap, err := r.OrderRepoI.Get(ctx, IDs)
+		responses <- response{ordersMap: ordersMap, err: err, hedge: hedge}
+	}
+
+	r.mu.Lock()
+	r.stats.Loads++
+	delay := r.delayLocked()
+	r.mu.Unlock()
+
+	go send(false)
+	inFlight := 1
+
+	var timer chan struct{}
+	if delay > 0 {
+		timer = make(chan struct{})
+		go func() {
+			if r.clock.SleepContext(ctx, delay) == nil {
+				close(timer)
+			}
+		}()
+	}
+
+	for {
+		select {
+		case <-timer:
+			timer = nil
+			if r.allowHedge() {
+				go send(true)
+				inFlight++
+			}
+		case resp := <-responses:
+			inFlight--
+			if resp.err == nil {
+				r.observe(r.clock.Since(start), resp.hedge)
+				return resp.ordersMap, nil
+			}
+			if inFlight == 0 {
+				return nil, resp.err
+			}
+			// the other load may still succeed, but no hedge is sent after a failure
+			timer = nil
+		}
+	}
+}
+
+// allowHedge takes a hedge out of the budget
+func (r *Repo) allowHedge() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if float64(r.stats.Hedges+1) > r.policy.Budget*float64(r.stats.Loads) {
+		return false
+	}
+	r.stats.Hedges++
+	return true
+}
+
+// observe records latency of the first answer
+func (r *Repo) observe(latency time.Duration, hedge bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if hedge {
+		r.stats.Wins++
+	}
+	r.unobserved++
+	if len(r.latencies) < r.policy.Window {
+		r.latencies = append(r.latencies, latency)
+		return
+	}
+	r.latencies[r.next] = latency
+	r.next = (r.next + 1) % r.policy.Window
+}
+
+// delayLocked must be called under r.mu
+func (r *Repo) delayLocked() time.Duration {
+	if len(r.latencies) < minSamples {
+		return 0
+	}
+	if r.delay > 0 && r.unobserved < max(1, len(r.latencies)/20) {
+		return r.delay
+	}
+
+	sorted := make([]time.Duration, len(r.latencies))
+	copy(sorted, r.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	r.delay = max(sorted[int(r.policy.Quantile*float64(len(sorted)-1))], r.policy.MinDelay)
+	r.unobserved = 0
+	return r.delay
+}
@@ -0,0 +1,138 @@
+package hedge
+
+import (
+	"caching-strategies/internal/chaos"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+const (
+	ordersNumber = 100
+	batch        = 20
+	slow         = 30 * time.Millisecond
+)
+
+// setup returns repository where 5% of calls take slow on top of 1ms of the latency mock
+func setup(t *testing.T, policy Policy) *Repo {
+	t.Helper()
+
+	repository := repo.New()
+	for i := 0; i < ordersNumber; i++ {
+		if _, err := repository.Save(context.Background(), &order.Order{ID: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sick := chaos.New(repository, chaos.WithSeed(1), chaos.WithOpFaults(chaos.OpGet, chaos.Faults{
+		Latency: chaos.Bimodal(0, slow, 0.05),
+	}))
+	r, err := New(sick, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// p90 returns 90th percentile of latency of batch Gets, first Gets warm up the delay estimate
+func p90(t *testing.T, r *Repo) time.Duration {
+	t.Helper()
+
+	const warmUp, gets = 5, 50
+	IDs := make([]uint64, batch)
+	latencies := make([]time.Duration, 0, gets)
+	for i := 0; i < warmUp+gets; i++ {
+		for j := range IDs {
+			IDs[j] = uint64((i*batch + j) % ordersNumber)
+		}
+
+		start := time.Now()
+		ordersMap, err := r.Get(context.Background(), IDs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ordersMap) != batch {
+			t.Fatalf("expected %d orders, got %d", batch, len(ordersMap))
+		}
+		if i >= warmUp {
+			latencies = append(latencies, time.Since(start))
+		}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[len(latencies)*9/10]
+}
+
+func TestHedging(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.Quantile = 0.9
+	policy.Budget = 0.2
+	policy.BatchSize = 1
+
+	// without hedges 64% of batches wait for at least one slow part
+	policy.Budget = 0
+	plain := setup(t, policy)
+	if latency := p90(t, plain); latency < slow {
+		t.Fatalf("expected p90 without hedging to be at least %s, got %s", slow, latency)
+	}
+	if stats := plain.Stats(); stats.Hedges != 0 {
+		t.Fatalf("expected no hedges with zero budget, got %+v", stats)
+	}
+
+	policy.Budget = 0.2
+	hedged := setup(t, policy)
+	if latency := p90(t, hedged); latency >= slow/2 {
+		t.Fatalf("expected p90 with hedging to be well below %s, got %s", slow, latency)
+	}
+	stats := hedged.Stats()
+	if stats.Wins == 0 || stats.Wins > stats.Hedges {
+		t.Fatalf("expected hedges to win slow loads, got %+v", stats)
+	}
+	if float64(stats.Hedges) > policy.Budget*float64(stats.Loads) {
+		t.Fatalf("expected hedges within %g of loads, got %+v", policy.Budget, stats)
+	}
+	if delay := hedged.Delay(); delay < policy.MinDelay || delay >= slow {
+		t.Fatalf("expected delay between %s and %s, got %s", policy.MinDelay, slow, delay)
+	}
+}
+
+func TestPassThrough(t *testing.T) {
+	ctx := context.Background()
+	r, err := New(repo.New(), DefaultPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ID, err := r.Save(ctx, &order.Order{ID: 7, Item: "book"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IDs, err := r.ListByItem(ctx, "book"); err != nil || len(IDs) != 1 || IDs[0] != ID {
+		t.Fatalf("expected [%d], got %v, %v", ID, IDs, err)
+	}
+	if err := r.Delete(ctx, ID); err != nil {
+		t.Fatal(err)
+	}
+	if ordersMap, err := r.Get(ctx, []uint64{ID}); err != nil || len(ordersMap) != 0 {
+		t.Fatalf("expected deleted order to be missing, got %v, %v", ordersMap, err)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	for name, modify := range map[string]func(*Policy){
+		"quantile":  func(p *Policy) { p.Quantile = 1 },
+		"min delay": func(p *Policy) { p.MinDelay = -time.Millisecond },
+		"budget":    func(p *Policy) { p.Budget = -0.1 },
+		"batch":     func(p *Policy) { p.BatchSize = 0 },
+		"window":    func(p *Policy) { p.Window = minSamples - 1 },
+	} {
+		policy := DefaultPolicy()
+		modify(&policy)
+		if _, err := New(repo.New(), policy); err == nil {
+			t.Fatalf("%s: expected invalid policy to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package invalidation
+
+import "sync"
+
+// Generations tell loads whether orders they loaded were invalidated while loading,
+// so a slow load, like refresh-ahead watcher, doesn't bring a deleted or overwritten order back into cache.
+// Methods are no-op on nil generations, every load result is cached then.
+type Generations struct {
+	mu  sync.Mutex
+	seq uint64
+	// order ID -> seq of its last invalidation, kept only while loads are in flight
+	invalidated map[uint64]uint64
+	inFlight    int
+}
+
+func NewGenerations() *Generations {
+	return &Generations{invalidated: make(map[uint64]uint64)}
+}
+
+// Begin is called before load, returned generation is passed to AddIfValid and End
+func (g *Generations) Begin() uint64 {
+	if g == nil {
+		return 0
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.inFlight++
+	return g.seq
+}
+
+// End is called when load results are cached, invalidations are forgotten when no load is in flight
+func (g *Generations) End() {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.inFlight--
+	if g.inFlight == 0 {
+		clear(g.invalidated)
+	}
+}
+
+// Invalidate must be called after order is changed in repository and before it's removed from cache
+func (g *Generations) Invalidate(IDs ...uint64) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.seq++
+	if g.inFlight == 0 {
+		return
+	}
+	for _, ID := range IDs {
+		g.invalidated[ID] = g.seq
+	}
+}
+
+// AddIfValid calls add unless order was invalidated after load began, returns whether it was called.
+// add is called under lock, so invalidation can't slip in between the check and the write
+func (g *Generations) AddIfValid(ID, generation uint64, add func()) bool {
+	if g == nil {
+		add()
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.invalidated[ID] > generation {
+		return false
+	}
+	add()
+	return true
+}
@@ -0,0 +1,229 @@
+package invalidation
+
+import (
+	"caching-strategies/internal/repository/entity/order"
+	"fmt"
+	"sync"
+)
+
+// TagFunc returns tags of the cached order
+type TagFunc func(order *order.Order) []string
+
+// DefaultTags tags orders by customer and item
+func DefaultTags(order *order.Order) []string {
+	return []string{CustomerTag(order.CustomerID), ItemTag(order.Item)}
+}
+
+func CustomerTag(customerID uint64) string {
+	return fmt.Sprintf("customer:%d", customerID)
+}
+
+func ItemTag(item string) string {
+	return "item:" + item
+}
+
+// Registry tracks tags of cached orders and cache entries depending on them (query results, pages).
+// Invalidating an order removes it from every subscribed cache and cascades to its dependents.
+// Methods are no-op on nil registry, so strategies can hold it optionally.
+type Registry struct {
+	tagFunc TagFunc
+
+	mu sync.Mutex
+	// tag -> order IDs
+	tagged map[string]map[uint64]struct{}
+	// order ID -> tags
+	tags map[uint64][]string
+	// order ID -> tagged value, eviction of an older value doesn't untag the current one
+	values map[uint64]*order.Order
+	// order ID -> dependent keys
+	dependents map[uint64]map[string]struct{}
+	// dependent key -> order IDs
+	dependencies map[string][]uint64
+
+	onOrder     []func(ID uint64)
+	onDependent []func(key string)
+}
+
+func New(tagFunc TagFunc) *Registry {
+	if tagFunc == nil {
+		tagFunc = DefaultTags
+	}
+
+	return &Registry{
+		tagFunc:      tagFunc,
+		tagged:       make(map[string]map[uint64]struct{}),
+		tags:         make(map[uint64][]string),
+		values:       make(map[uint64]*order.Order),
+		dependents:   make(map[uint64]map[string]struct{}),
+		dependencies: make(map[string][]uint64),
+	}
+}
+
+// OnInvalidate subscribes order cache to invalidations
+func (r *Registry) OnInvalidate(fn func(ID uint64)) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onOrder = append(r.onOrder, fn)
+}
+
+// OnInvalidateDependent subscribes query cache to invalidations of its keys
+func (r *Registry) OnInvalidateDependent(fn func(key string)) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onDependent = append(r.onDependent, fn)
+}
+
+// Tag replaces tags of the cached order
+func (r *Registry) Tag(order *order.Order) {
+	if r == nil || order == nil {
+		return
+	}
+
+	tags := r.tagFunc(order)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.untagLocked(order.ID)
+	for _, tag := range tags {
+		if r.tagged[tag] == nil {
+			r.tagged[tag] = make(map[uint64]struct{})
+		}
+		r.tagged[tag][order.ID] = struct{}{}
+	}
+	r.tags[order.ID] = tags
+	r.values[order.ID] = order
+}
+
+// Evicted drops tags of the order evicted from cache, so registry doesn't outgrow the cache,
+// it's called by eviction callback of the order cache with the evicted value
+func (r *Registry) Evicted(ID uint64, value *order.Order) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the order was cached and tagged again after the value was evicted
+	if r.values[ID] != value {
+		return
+	}
+	r.untagLocked(ID)
+}
+
+// DependOn replaces order IDs the dependent key is built from
+func (r *Registry) DependOn(key string, IDs []uint64) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.forgetLocked(key)
+	for _, ID := range IDs {
+		if r.dependents[ID] == nil {
+			r.dependents[ID] = make(map[string]struct{})
+		}
+		r.dependents[ID][key] = struct{}{}
+	}
+	r.dependencies[key] = IDs
+}
+
+// Forget drops dependency edges of the key removed from its cache
+func (r *Registry) Forget(key string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.forgetLocked(key)
+}
+
+// InvalidateTag invalidates all orders with the tag and returns their count
+func (r *Registry) InvalidateTag(tag string) int {
+	if r == nil {
+		return 0
+	}
+
+	r.mu.Lock()
+	IDs := make([]uint64, 0, len(r.tagged[tag]))
+	for ID := range r.tagged[tag] {
+		IDs = append(IDs, ID)
+	}
+	r.mu.Unlock()
+
+	r.Invalidate(IDs...)
+
+	return len(IDs)
+}
+
+// Invalidate removes orders from subscribed caches and cascades to dependent keys
+func (r *Registry) Invalidate(IDs ...uint64) {
+	if r == nil || len(IDs) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	keys := make(map[string]struct{})
+	for _, ID := range IDs {
+		for key := range r.dependents[ID] {
+			keys[key] = struct{}{}
+		}
+		r.untagLocked(ID)
+	}
+	for key := range keys {
+		r.forgetLocked(key)
+	}
+	onOrder := r.onOrder
+	onDependent := r.onDependent
+	r.mu.Unlock()
+
+	// callbacks are called without lock, so caches may call registry back
+	for _, ID := range IDs {
+		for _, fn := range onOrder {
+			fn(ID)
+		}
+	}
+	for key := range keys {
+		for _, fn := range onDependent {
+			fn(key)
+		}
+	}
+}
+
+// untagLocked must be called under r.mu
+func (r *Registry) untagLocked(ID uint64) {
+	for _, tag := range r.tags[ID] {
+		delete(r.tagged[tag], ID)
+		if len(r.tagged[tag]) == 0 {
+			delete(r.tagged, tag)
+		}
+	}
+	delete(r.tags, ID)
+	delete(r.values, ID)
+}
+
+// forgetLocked must be called under r.mu
+func (r *Registry) forgetLocked(key string) {
+	for _, ID := range r.dependencies[key] {
+		delete(r.dependents[ID], key)
+		if len(r.dependents[ID]) == 0 {
+			delete(r.dependents, ID)
+		}
+	}
+	delete(r.dependencies, key)
+}
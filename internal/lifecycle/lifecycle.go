@@ -0,0 +1,110 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+)
+
+// Worker is a background worker of the service, like cache refresh watcher or async writer
+type Worker interface {
+	// Run works until stop is closed, ctx is cancelled only when Stop gives up waiting,
+	// so work in flight is finished rather than abandoned
+	Run(ctx context.Context, stop <-chan struct{})
+	// Drain is called after Run returned: it rejects new work, finishes queued one until ctx is done
+	// and returns the number of items left undone
+	Drain(ctx context.Context) (dropped int)
+}
+
+// Report is what workers left undone on Stop
+type Report struct {
+	// Dropped items per worker, zero for workers which finished everything
+	Dropped map[string]int
+}
+
+// Total is the number of items dropped by all workers
+func (r Report) Total() int {
+	total := 0
+	for _, dropped := range r.Dropped {
+		total += dropped
+	}
+	return total
+}
+
+type namedWorker struct {
+	name   string
+	worker Worker
+}
+
+// Manager starts workers together and stops them in order of Add
+type Manager struct {
+	mu      sync.Mutex
+	workers []namedWorker
+	started bool
+	stopped bool
+
+	stop       chan struct{}
+	cancelWork context.CancelFunc
+	running    sync.WaitGroup
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		stop: make(chan struct{}),
+	}
+}
+
+// Add registers worker, it must be called before Start
+func (m *Manager) Add(name string, w Worker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.workers = append(m.workers, namedWorker{name: name, worker: w})
+}
+
+// Start runs workers in background. They run until Stop, cancellation of ctx doesn't stop them:
+// a service stops accepting requests first and only then its workers, ctx only carries values.
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started || m.stopped {
+		return
+	}
+	m.started = true
+
+	var workCtx context.Context
+	workCtx, m.cancelWork = context.WithCancel(context.WithoutCancel(ctx))
+	for _, w := range m.workers {
+		m.running.Add(1)
+		go func() {
+			defer m.running.Done()
+			w.worker.Run(workCtx, m.stop)
+		}()
+	}
+}
+
+// Stop signals workers to stop, waits for work in flight and drains queues until ctx is done.
+// Error is ctx error if Stop gave up waiting, the report tells what was dropped.
+func (m *Manager) Stop(ctx context.Context) (Report, error) {
+	m.mu.Lock()
+	if !m.started || m.stopped {
+		m.stopped = true
+		m.mu.Unlock()
+		return Report{}, nil
+	}
+	m.stopped = true
+	m.mu.Unlock()
+
+	close(m.stop)
+	// work in flight is cancelled only when ctx is done
+	release := context.AfterFunc(ctx, m.cancelWork)
+	defer release()
+	defer m.cancelWork()
+	m.running.Wait()
+
+	report := Report{Dropped: make(map[string]int, len(m.workers))}
+	for _, w := range m.workers {
+		report.Dropped[w.name] = w.worker.Drain(ctx)
+	}
+	return report, ctx.Err()
+}
@@ -0,0 +1,139 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// worker takes all queued items at once, every item takes delay
+type worker struct {
+	queue *Queue[int]
+	delay time.Duration
+	done  []int
+	// dropped are items taken by Run and interrupted by ctx
+	dropped int
+}
+
+func (w *worker) Run(ctx context.Context, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		default:
+		}
+		items := w.queue.Batch()
+		if len(items) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		w.dropped += len(items) - w.work(ctx, items)
+	}
+}
+
+func (w *worker) Drain(ctx context.Context) int {
+	w.queue.Close()
+	items := w.queue.Batch()
+	return w.dropped + len(items) - w.work(ctx, items)
+}
+
+func (w *worker) work(ctx context.Context, items []int) int {
+	for i, item := range items {
+		select {
+		case <-time.After(w.delay):
+			w.done = append(w.done, item)
+		case <-ctx.Done():
+			return i
+		}
+	}
+	return len(items)
+}
+
+func TestQueue(t *testing.T) {
+	q := NewQueue[int](2)
+	for i := 0; i < 2; i++ {
+		if err := q.Offer(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Offer(2); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected full queue to drop item, got %v", err)
+	}
+	if items := q.Batch(); len(items) != 2 || q.Len() != 0 {
+		t.Fatalf("expected batch of all queued items, got %v and %d left", items, q.Len())
+	}
+
+	if err := q.Offer(3); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+	if err := q.Offer(4); !errors.Is(err, ErrStopped) || q.Rejected() != 1 {
+		t.Fatalf("expected closed queue to reject item, got %v", err)
+	}
+	if items := q.Batch(); len(items) != 1 || items[0] != 3 {
+		t.Fatalf("expected item queued before close to stay, got %v", items)
+	}
+}
+
+func TestStop(t *testing.T) {
+	w := &worker{queue: NewQueue[int](100), delay: time.Millisecond}
+	m := NewManager()
+	m.Add("worker", w)
+
+	// workers aren't stopped by cancellation of start ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Start(ctx)
+	cancel()
+
+	for i := 0; i < 20; i++ {
+		if err := w.queue.Offer(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := m.Stop(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total() != 0 || len(w.done) != 20 {
+		t.Fatalf("expected all queued items to be done, got %d done, %+v", len(w.done), report)
+	}
+	if err := w.queue.Offer(20); !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected stopped worker to reject new work, got %v", err)
+	}
+
+	// second stop does nothing
+	if report, err := m.Stop(context.Background()); err != nil || report.Total() != 0 {
+		t.Fatalf("expected repeated stop to do nothing, got %+v, %v", report, err)
+	}
+}
+
+func TestStopDeadline(t *testing.T) {
+	w := &worker{queue: NewQueue[int](100), delay: 10 * time.Millisecond}
+	m := NewManager()
+	m.Add("worker", w)
+
+	for i := 0; i < 100; i++ {
+		if err := w.queue.Offer(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Start(context.Background())
+	time.Sleep(5 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	report, err := m.Stop(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected stop to give up at deadline, took %s", elapsed)
+	}
+	if dropped := report.Dropped["worker"]; dropped == 0 || dropped+len(w.done) != 100 {
+		t.Fatalf("expected undone items to be reported, got %d dropped and %d done", dropped, len(w.done))
+	}
+}
@@ -0,0 +1,82 @@
+package lifecycle
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrFull is returned by Offer when the queue is full, the item is dropped
+	ErrFull = errors.New("queue is full")
+	// ErrStopped is returned by Offer after Close, the item is rejected
+	ErrStopped = errors.New("queue is stopped")
+)
+
+// Queue is a bounded queue of background work. Offer never blocks and is safe after Close,
+// unlike a send on closed channel, so producers don't have to know when the worker stops.
+type Queue[T any] struct {
+	mu       sync.Mutex
+	items    []T
+	size     int
+	closed   bool
+	rejected int
+}
+
+func NewQueue[T any](size int) *Queue[T] {
+	return &Queue[T]{
+		items: make([]T, 0, size),
+		size:  size,
+	}
+}
+
+// Offer queues item or returns ErrFull or ErrStopped
+func (q *Queue[T]) Offer(item T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		q.rejected++
+		return ErrStopped
+	}
+	if len(q.items) >= q.size {
+		return ErrFull
+	}
+	q.items = append(q.items, item)
+	return nil
+}
+
+// Batch takes all queued items
+func (q *Queue[T]) Batch() []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return nil
+	}
+	items := q.items
+	q.items = make([]T, 0, q.size)
+	return items
+}
+
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.items)
+}
+
+// Close makes Offer reject new items, queued ones can still be taken
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+}
+
+// Rejected is the number of items offered after Close
+func (q *Queue[T]) Rejected() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.rejected
+}
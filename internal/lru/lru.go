@@ -0,0 +1,361 @@
+package lru
+
+import (
+	"caching-strategies/internal/clock"
+	"container/heap"
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Option configures LRU
+type Option func(*config)
+
+type config struct {
+	sliding     bool
+	maxLifetime time.Duration
+	clock       clock.Clock
+}
+
+// WithSliding extends entry TTL on every Get up to maxLifetime since Add (0 - unlimited),
+// so actively read entries stay cached and idle ones expire after their TTL
+func WithSliding(maxLifetime time.Duration) Option {
+	return func(c *config) {
+		c.sliding = true
+		c.maxLifetime = maxLifetime
+	}
+}
+
+// WithClock replaces real clock used for expiration, e.g. with clock.Fake in tests
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
+// EvictCallback is called for entries evicted by size, expired or removed
+type EvictCallback[K comparable, V any] func(key K, value V)
+
+// LRU is a thread-safe LRU cache with per-entry TTL.
+// API follows expirable.LRU, expired entries are purged on every cache operation.
+type LRU[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	onEvict EvictCallback[K, V]
+	config  config
+
+	items map[K]*entry[K, V]
+	// front is the most recently used entry
+	recency *list.List
+	// entries with TTL ordered by expiration
+	expiry expiryHeap[K, V]
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+	// TTL is kept for sliding expiration
+	ttl time.Duration
+	// max absolute lifetime, zero if unlimited
+	deadline time.Time
+	element  *list.Element
+	// index in expiry heap, -1 if entry never expires
+	index int
+}
+
+// NewLRU returns cache with max size (0 - unlimited) and default TTL (0 - no expiration)
+func NewLRU[K comparable, V any](
+	size int,
+	onEvict EvictCallback[K, V],
+	ttl time.Duration,
+	opts ...Option,
+) *LRU[K, V] {
+	if size < 0 {
+		size = 0
+	}
+
+	cfg := config{clock: clock.Real()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &LRU[K, V]{
+		size:    size,
+		ttl:     ttl,
+		onEvict: onEvict,
+		config:  cfg,
+		items:   make(map[K]*entry[K, V]),
+		recency: list.New(),
+	}
+}
+
+// Add adds value with default TTL, returns true if an entry was evicted
+func (c *LRU[K, V]) Add(key K, value V) (evicted bool) {
+	return c.AddWithTTL(key, value, c.ttl)
+}
+
+// AddWithTTL adds value with its own TTL (0 - no expiration), returns true if an entry was evicted
+func (c *LRU[K, V]) AddWithTTL(key K, value V, ttl time.Duration) (evicted bool) {
+	var removed []*entry[K, V]
+
+	c.mu.Lock()
+	now := c.config.clock.Now()
+	removed = c.removeExpiredLocked(now, removed)
+
+	var deadline time.Time
+	if c.config.sliding && c.config.maxLifetime > 0 {
+		deadline = now.Add(c.config.maxLifetime)
+	}
+
+	if e, ok := c.items[key]; ok {
+		e.value = value
+		e.ttl = ttl
+		e.deadline = deadline
+		c.recency.MoveToFront(e.element)
+		c.setExpiryLocked(e, e.expiration(now))
+		c.mu.Unlock()
+		c.evict(removed)
+
+		return false
+	}
+
+	e := &entry[K, V]{key: key, value: value, ttl: ttl, deadline: deadline, index: -1}
+	e.element = c.recency.PushFront(e)
+	c.items[key] = e
+	c.setExpiryLocked(e, e.expiration(now))
+
+	if c.size > 0 && c.recency.Len() > c.size {
+		oldest := c.recency.Back().Value.(*entry[K, V])
+		c.removeLocked(oldest)
+		removed = append(removed, oldest)
+		evicted = true
+	}
+	c.mu.Unlock()
+	c.evict(removed)
+
+	return evicted
+}
+
+// Get returns value and marks it as recently used, extends its TTL in sliding mode
+func (c *LRU[K, V]) Get(key K) (value V, ok bool) {
+	c.mu.Lock()
+	now := c.config.clock.Now()
+	removed := c.removeExpiredLocked(now, nil)
+
+	e, ok := c.items[key]
+	if ok {
+		c.recency.MoveToFront(e.element)
+		if c.config.sliding && e.ttl > 0 {
+			c.setExpiryLocked(e, e.expiration(now))
+		}
+		value = e.value
+	}
+	c.mu.Unlock()
+	c.evict(removed)
+
+	return value, ok
+}
+
+// Peek returns value without updating recency
+func (c *LRU[K, V]) Peek(key K) (value V, ok bool) {
+	c.mu.Lock()
+	removed := c.removeExpiredLocked(c.config.clock.Now(), nil)
+
+	e, ok := c.items[key]
+	if ok {
+		value = e.value
+	}
+	c.mu.Unlock()
+	c.evict(removed)
+
+	return value, ok
+}
+
+// TTL returns remaining TTL of the entry without updating recency, 0 if entry never expires
+func (c *LRU[K, V]) TTL(key K) (ttl time.Duration, ok bool) {
+	c.mu.Lock()
+	now := c.config.clock.Now()
+	removed := c.removeExpiredLocked(now, nil)
+
+	e, ok := c.items[key]
+	if ok && !e.expiresAt.IsZero() {
+		ttl = e.expiresAt.Sub(now)
+	}
+	c.mu.Unlock()
+	c.evict(removed)
+
+	return ttl, ok
+}
+
+func (c *LRU[K, V]) Contains(key K) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// Remove removes the key, returns true if it was present
+func (c *LRU[K, V]) Remove(key K) (present bool) {
+	c.mu.Lock()
+	removed := c.removeExpiredLocked(c.config.clock.Now(), nil)
+
+	e, ok := c.items[key]
+	if ok {
+		c.removeLocked(e)
+		removed = append(removed, e)
+	}
+	c.mu.Unlock()
+	c.evict(removed)
+
+	return ok
+}
+
+// Keys returns keys from the oldest to the newest
+func (c *LRU[K, V]) Keys() []K {
+	c.mu.Lock()
+	removed := c.removeExpiredLocked(c.config.clock.Now(), nil)
+
+	keys := make([]K, 0, len(c.items))
+	for el := c.recency.Back(); el != nil; el = el.Prev() {
+		keys = append(keys, el.Value.(*entry[K, V]).key)
+	}
+	c.mu.Unlock()
+	c.evict(removed)
+
+	return keys
+}
+
+// Entry is a cached value with its remaining TTL, 0 if it never expires
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+	TTL   time.Duration
+}
+
+// Entries returns consistent copy of entries from the oldest to the newest without updating recency
+func (c *LRU[K, V]) Entries() []Entry[K, V] {
+	c.mu.Lock()
+	now := c.config.clock.Now()
+	removed := c.removeExpiredLocked(now, nil)
+
+	entries := make([]Entry[K, V], 0, len(c.items))
+	for el := c.recency.Back(); el != nil; el = el.Prev() {
+		e := el.Value.(*entry[K, V])
+		var ttl time.Duration
+		if !e.expiresAt.IsZero() {
+			ttl = e.expiresAt.Sub(now)
+		}
+		entries = append(entries, Entry[K, V]{Key: e.key, Value: e.value, TTL: ttl})
+	}
+	c.mu.Unlock()
+	c.evict(removed)
+
+	return entries
+}
+
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	removed := c.removeExpiredLocked(c.config.clock.Now(), nil)
+	l := len(c.items)
+	c.mu.Unlock()
+	c.evict(removed)
+
+	return l
+}
+
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	removed := make([]*entry[K, V], 0, len(c.items))
+	for _, e := range c.items {
+		removed = append(removed, e)
+	}
+	c.items = make(map[K]*entry[K, V])
+	c.recency.Init()
+	c.expiry = c.expiry[:0]
+	c.mu.Unlock()
+	c.evict(removed)
+}
+
+// setExpiryLocked must be called under c.mu
+func (c *LRU[K, V]) setExpiryLocked(e *entry[K, V], expiresAt time.Time) {
+	e.expiresAt = expiresAt
+
+	switch {
+	case expiresAt.IsZero() && e.index >= 0:
+		heap.Remove(&c.expiry, e.index)
+	case expiresAt.IsZero():
+	case e.index >= 0:
+		heap.Fix(&c.expiry, e.index)
+	default:
+		heap.Push(&c.expiry, e)
+	}
+}
+
+// removeLocked must be called under c.mu
+func (c *LRU[K, V]) removeLocked(e *entry[K, V]) {
+	delete(c.items, e.key)
+	c.recency.Remove(e.element)
+	if e.index >= 0 {
+		heap.Remove(&c.expiry, e.index)
+	}
+}
+
+// removeExpiredLocked must be called under c.mu
+func (c *LRU[K, V]) removeExpiredLocked(now time.Time, removed []*entry[K, V]) []*entry[K, V] {
+	for len(c.expiry) > 0 && !c.expiry[0].expiresAt.After(now) {
+		e := c.expiry[0]
+		c.removeLocked(e)
+		removed = append(removed, e)
+	}
+	return removed
+}
+
+// evict calls callback without lock, so it may use the cache
+func (c *LRU[K, V]) evict(removed []*entry[K, V]) {
+	if c.onEvict == nil {
+		return
+	}
+	for _, e := range removed {
+		c.onEvict(e.key, e.value)
+	}
+}
+
+// expiration returns TTL since now capped by deadline, zero if entry never expires
+func (e *entry[K, V]) expiration(now time.Time) time.Time {
+	if e.ttl <= 0 {
+		return time.Time{}
+	}
+
+	expiresAt := now.Add(e.ttl)
+	if !e.deadline.IsZero() && e.deadline.Before(expiresAt) {
+		return e.deadline
+	}
+	return expiresAt
+}
+
+type expiryHeap[K comparable, V any] []*entry[K, V]
+
+func (h expiryHeap[K, V]) Len() int { return len(h) }
+
+func (h expiryHeap[K, V]) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+
+func (h expiryHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap[K, V]) Push(x any) {
+	e := x.(*entry[K, V])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap[K, V]) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*h = old[:len(old)-1]
+	return e
+}
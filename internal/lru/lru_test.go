@@ -0,0 +1,126 @@
+package lru
+
+import (
+	"caching-strategies/internal/clock"
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	var evicted []int
+	cache := NewLRU[int, string](2, func(key int, _ string) {
+		evicted = append(evicted, key)
+	}, 0)
+
+	cache.Add(1, "1")
+	cache.Add(2, "2")
+	// 1 becomes the most recently used
+	if _, ok := cache.Get(1); !ok {
+		t.Fatal("expected 1 in cache")
+	}
+	if !cache.Add(3, "3") {
+		t.Fatal("expected eviction")
+	}
+
+	if cache.Contains(2) || !cache.Contains(1) || !cache.Contains(3) {
+		t.Fatalf("expected 2 to be evicted, keys: %v", cache.Keys())
+	}
+	if len(evicted) != 1 || evicted[0] != 2 {
+		t.Fatalf("expected evict callback for 2, got %v", evicted)
+	}
+}
+
+func TestLRUPerEntryTTL(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	cache := NewLRU[int, string](0, nil, time.Hour, WithClock(fake))
+
+	cache.AddWithTTL(1, "1", 10*time.Millisecond)
+	cache.Add(2, "2")
+	cache.AddWithTTL(3, "3", 0)
+
+	fake.Advance(20 * time.Millisecond)
+
+	if cache.Contains(1) {
+		t.Fatal("expected 1 to expire")
+	}
+	if !cache.Contains(2) || !cache.Contains(3) {
+		t.Fatal("expected 2 and 3 to stay in cache")
+	}
+	if cache.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", cache.Len())
+	}
+	if ttl, ok := cache.TTL(2); !ok || ttl != time.Hour-20*time.Millisecond {
+		t.Fatalf("expected remaining default TTL, got %v", ttl)
+	}
+	if ttl, ok := cache.TTL(3); !ok || ttl != 0 {
+		t.Fatalf("expected 3 to never expire, got %v", ttl)
+	}
+	if _, ok := cache.TTL(1); ok {
+		t.Fatal("expected no TTL for expired key")
+	}
+
+	// re-adding resets TTL, entry expires exactly at TTL
+	cache.AddWithTTL(2, "2", 10*time.Millisecond)
+	fake.Advance(10*time.Millisecond - 1)
+	if !cache.Contains(2) {
+		t.Fatal("expected 2 to stay in cache until TTL")
+	}
+	fake.Advance(1)
+	if cache.Contains(2) {
+		t.Fatal("expected 2 to expire after TTL reset")
+	}
+}
+
+func TestLRUSliding(t *testing.T) {
+	const ttl = 30 * time.Millisecond
+
+	fake := clock.NewFake(time.Unix(0, 0))
+	cache := NewLRU[int, string](0, nil, ttl, WithSliding(4*ttl), WithClock(fake))
+	cache.Add(1, "active")
+	cache.Add(2, "idle")
+
+	// active key is read more often than TTL and survives beyond it
+	for i := 0; i < 6; i++ {
+		fake.Advance(ttl / 2)
+		if _, ok := cache.Get(1); !ok {
+			t.Fatalf("expected active key to be cached after %s", time.Duration(i+1)*ttl/2)
+		}
+	}
+	if cache.Contains(2) {
+		t.Fatal("expected idle key to expire")
+	}
+
+	// max lifetime limits sliding
+	for i := 0; i < 6; i++ {
+		fake.Advance(ttl / 2)
+		cache.Get(1)
+	}
+	if cache.Contains(1) {
+		t.Fatal("expected active key to expire after max lifetime")
+	}
+}
+
+func TestLRUEntries(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	cache := NewLRU[int, string](0, nil, time.Hour, WithClock(fake))
+
+	cache.Add(1, "1")
+	cache.AddWithTTL(2, "2", 0)
+	cache.AddWithTTL(3, "3", time.Millisecond)
+	cache.Get(1)
+	fake.Advance(time.Minute)
+
+	entries := cache.Entries()
+	expected := []Entry[int, string]{
+		{Key: 2, Value: "2", TTL: 0},
+		{Key: 1, Value: "1", TTL: time.Hour - time.Minute},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, entries)
+		}
+	}
+}
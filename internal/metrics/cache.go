@@ -0,0 +1,138 @@
+package metrics
+
+import "time"
+
+// CacheMetrics instrument a cache strategy, methods are no-op on nil
+type CacheMetrics struct {
+	hits        *Counter
+	misses      *Counter
+	loads       *Counter
+	loadErrors  *Counter
+	loadLatency *Histogram
+	evictions   *Counter
+	rejected    *Counter
+	stale       *Counter
+
+	refreshQueueDepth *Gauge
+	refreshQueued     *Counter
+	refreshDropped    *Counter
+	refreshes         *Counter
+	refreshErrors     *Counter
+	refreshLatency    *Histogram
+}
+
+// CacheMetrics returns metrics of the strategy labeled by its name
+func (r *Registry) CacheMetrics(strategy string) *CacheMetrics {
+	if r == nil {
+		return nil
+	}
+
+	labels := Labels{"strategy": strategy}
+
+	return &CacheMetrics{
+		hits:        r.Counter("cache_hits_total", "Keys found in cache.", labels),
+		misses:      r.Counter("cache_misses_total", "Keys not found in cache.", labels),
+		loads:       r.Counter("cache_loads_total", "Keys loaded from repository on cache miss.", labels),
+		loadErrors:  r.Counter("cache_load_errors_total", "Failed repository loads on cache miss.", labels),
+		loadLatency: r.Histogram("cache_load_duration_seconds", "Latency of repository loads on cache miss.", nil, labels),
+		evictions:   r.Counter("cache_evictions_total", "Entries evicted, expired or removed from cache.", labels),
+		rejected:    r.Counter("cache_load_rejected_total", "Repository loads rejected by open breaker or overload.", labels),
+		stale:       r.Counter("cache_stale_served_total", "Stale keys served because repository load failed.", labels),
+
+		refreshQueueDepth: r.Gauge("cache_refresh_queue_depth", "Keys waiting for refresh.", labels),
+		refreshQueued:     r.Counter("cache_refresh_queued_total", "Keys queued for refresh.", labels),
+		refreshDropped:    r.Counter("cache_refresh_dropped_total", "Keys not queued for refresh because queue is full.", labels),
+		refreshes:         r.Counter("cache_refreshes_total", "Keys refreshed in cache.", labels),
+		refreshErrors:     r.Counter("cache_refresh_errors_total", "Failed refresh batches.", labels),
+		refreshLatency:    r.Histogram("cache_refresh_duration_seconds", "Latency of refresh batches.", nil, labels),
+	}
+}
+
+func (m *CacheMetrics) Hit() {
+	if m == nil {
+		return
+	}
+	m.hits.Inc()
+}
+
+func (m *CacheMetrics) Miss() {
+	if m == nil {
+		return
+	}
+	m.misses.Inc()
+}
+
+// Load records repository load of count keys
+func (m *CacheMetrics) Load(count int, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.loadLatency.Observe(elapsed.Seconds())
+	if err != nil {
+		m.loadErrors.Inc()
+		return
+	}
+	m.loads.Add(float64(count))
+}
+
+// Rejected records repository load not made because of open breaker or overload
+func (m *CacheMetrics) Rejected() {
+	if m == nil {
+		return
+	}
+	m.rejected.Inc()
+}
+
+// Stale records count keys served from stale cache
+func (m *CacheMetrics) Stale(count int) {
+	if m == nil {
+		return
+	}
+	m.stale.Add(float64(count))
+}
+
+func (m *CacheMetrics) Evict() {
+	if m == nil {
+		return
+	}
+	m.evictions.Inc()
+}
+
+// RefreshQueued records key sent to refresh queue, dropped if queue is full
+func (m *CacheMetrics) RefreshQueued(dropped bool) {
+	if m == nil {
+		return
+	}
+	if dropped {
+		m.refreshDropped.Inc()
+		return
+	}
+	m.refreshQueued.Inc()
+}
+
+func (m *CacheMetrics) RefreshQueueDepth(depth int) {
+	if m == nil {
+		return
+	}
+	m.refreshQueueDepth.Set(float64(depth))
+}
+
+// Refresh records refresh batch of count keys
+func (m *CacheMetrics) Refresh(count int, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.refreshLatency.Observe(elapsed.Seconds())
+	if err != nil {
+		m.refreshErrors.Inc()
+		return
+	}
+	m.refreshes.Add(float64(count))
+}
+
+// EvictCallback returns cache evict callback counting evictions
+func EvictCallback[K comparable, V any](m *CacheMetrics) func(key K, value V) {
+	return func(K, V) {
+		m.Evict()
+	}
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ServeHTTP exposes metrics in Prometheus text format, registry isn't locked while writing to scraper
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	if r == nil {
+		return
+	}
+
+	for _, f := range r.collect() {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.typ)
+
+		for _, s := range f.samples {
+			if len(s.Labels) == 0 {
+				fmt.Fprintf(bw, "%s %s\n", s.Name, formatFloat(s.Value))
+				continue
+			}
+			fmt.Fprintf(bw, "%s{%s} %s\n", s.Name, labelsString(s.Labels), formatFloat(s.Value))
+		}
+	}
+}
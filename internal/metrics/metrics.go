@@ -0,0 +1,335 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefBuckets are latency buckets in seconds
+var DefBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type Labels map[string]string
+
+// Sample is a single value of the snapshot, histograms are exposed as _bucket, _sum and _count samples
+type Sample struct {
+	Name   string
+	Labels Labels
+	Value  float64
+}
+
+// Registry keeps metric families, methods of metrics are no-op on nil,
+// so instrumented code works without registry
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     string
+	buckets []float64
+	// labels string -> series
+	series map[string]*series
+}
+
+type series struct {
+	labels Labels
+	metric metric
+}
+
+type metric interface {
+	samples(name string, labels Labels) []Sample
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+func (r *Registry) Counter(name, help string, labels Labels) *Counter {
+	if r == nil {
+		return nil
+	}
+	return r.metric(name, help, typeCounter, nil, labels, func() metric { return &Counter{} }).(*Counter)
+}
+
+func (r *Registry) Gauge(name, help string, labels Labels) *Gauge {
+	if r == nil {
+		return nil
+	}
+	return r.metric(name, help, typeGauge, nil, labels, func() metric { return &Gauge{} }).(*Gauge)
+}
+
+func (r *Registry) Histogram(name, help string, buckets []float64, labels Labels) *Histogram {
+	if r == nil {
+		return nil
+	}
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	return r.metric(name, help, typeHistogram, buckets, labels, func() metric {
+		return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	}).(*Histogram)
+}
+
+// metric returns existing series or registers a new one
+func (r *Registry) metric(
+	name, help, typ string,
+	buckets []float64,
+	labels Labels,
+	create func() metric,
+) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, typ: typ, buckets: buckets, series: make(map[string]*series)}
+		r.families[name] = f
+	}
+	if f.typ != typ {
+		panic("metrics: " + name + " is already registered as " + f.typ)
+	}
+
+	key := labelsString(labels)
+	sr, ok := f.series[key]
+	if !ok {
+		sr = &series{labels: labels, metric: create()}
+		f.series[key] = sr
+	}
+	return sr.metric
+}
+
+// Snapshot returns current values of all metrics sorted by name and labels
+func (r *Registry) Snapshot() []Sample {
+	if r == nil {
+		return nil
+	}
+
+	families := r.collect()
+	samples := make([]Sample, 0, len(families))
+	for _, f := range families {
+		samples = append(samples, f.samples...)
+	}
+	return samples
+}
+
+// familySamples are samples of a family sorted by labels
+type familySamples struct {
+	name    string
+	help    string
+	typ     string
+	samples []Sample
+}
+
+// collect copies samples of all families sorted by name under lock,
+// callers write them after it's released, so slow reader doesn't block metrics
+func (r *Registry) collect() []familySamples {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	families := make([]familySamples, 0, len(r.families))
+	for _, name := range r.namesLocked() {
+		f := r.families[name]
+		samples := make([]Sample, 0, len(f.series))
+		for _, key := range sortedKeys(f.series) {
+			sr := f.series[key]
+			samples = append(samples, sr.metric.samples(f.name, sr.labels)...)
+		}
+		families = append(families, familySamples{name: f.name, help: f.help, typ: f.typ, samples: samples})
+	}
+	return families
+}
+
+// Value returns the value of the sample, 0 if it doesn't exist
+func (r *Registry) Value(name string, labels Labels) float64 {
+	key := labelsString(labels)
+	for _, s := range r.Snapshot() {
+		if s.Name == name && labelsString(s.Labels) == key {
+			return s.Value
+		}
+	}
+	return 0
+}
+
+func (r *Registry) namesLocked() []string {
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type Counter struct {
+	bits uint64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Add(v float64) {
+	if c == nil || v < 0 {
+		return
+	}
+	addFloat(&c.bits, v)
+}
+
+func (c *Counter) Value() float64 {
+	if c == nil {
+		return 0
+	}
+	return math.Float64frombits(atomic.LoadUint64(&c.bits))
+}
+
+func (c *Counter) samples(name string, labels Labels) []Sample {
+	return []Sample{{Name: name, Labels: labels, Value: c.Value()}}
+}
+
+type Gauge struct {
+	bits uint64
+}
+
+func (g *Gauge) Set(v float64) {
+	if g == nil {
+		return
+	}
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+func (g *Gauge) Add(v float64) {
+	if g == nil {
+		return
+	}
+	addFloat(&g.bits, v)
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) Value() float64 {
+	if g == nil {
+		return 0
+	}
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *Gauge) samples(name string, labels Labels) []Sample {
+	return []Sample{{Name: name, Labels: labels, Value: g.Value()}}
+}
+
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	// non-cumulative counts per bucket
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *Histogram) Observe(v float64) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *Histogram) samples(name string, labels Labels) []Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	samples := make([]Sample, 0, len(h.buckets)+3)
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i]
+		samples = append(samples, Sample{
+			Name:   name + "_bucket",
+			Labels: withLabel(labels, "le", formatFloat(le)),
+			Value:  float64(cumulative),
+		})
+	}
+	samples = append(samples,
+		Sample{Name: name + "_bucket", Labels: withLabel(labels, "le", "+Inf"), Value: float64(h.count)},
+		Sample{Name: name + "_sum", Labels: labels, Value: h.sum},
+		Sample{Name: name + "_count", Labels: labels, Value: float64(h.count)},
+	)
+	return samples
+}
+
+func addFloat(bits *uint64, v float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		updated := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(bits, old, updated) {
+			return
+		}
+	}
+}
+
+func withLabel(labels Labels, name, value string) Labels {
+	result := make(Labels, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[name] = value
+	return result
+}
+
+// labelsString returns labels in exposition format sorted by name: a="1",b="2"
+func labelsString(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(labels[name])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]*series) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExposition(t *testing.T) {
+	registry := NewRegistry()
+	m := registry.CacheMetrics("read_write_through")
+
+	m.Hit()
+	m.Hit()
+	m.Miss()
+	m.Load(1, 3*time.Millisecond, nil)
+	m.RefreshQueueDepth(5)
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		"# TYPE cache_hits_total counter",
+		`cache_hits_total{strategy="read_write_through"} 2`,
+		`cache_misses_total{strategy="read_write_through"} 1`,
+		`cache_refresh_queue_depth{strategy="read_write_through"} 5`,
+		"# TYPE cache_load_duration_seconds histogram",
+		`cache_load_duration_seconds_bucket{le="0.0025",strategy="read_write_through"} 0`,
+		`cache_load_duration_seconds_bucket{le="0.005",strategy="read_write_through"} 1`,
+		`cache_load_duration_seconds_bucket{le="+Inf",strategy="read_write_through"} 1`,
+		`cache_load_duration_seconds_count{strategy="read_write_through"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, body)
+		}
+	}
+
+	if v := registry.Value("cache_loads_total", Labels{"strategy": "read_write_through"}); v != 1 {
+		t.Errorf("expected 1 load in snapshot, got %v", v)
+	}
+}
+
+// blockingWriter stalls the first write until released like a stuck scraper
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.writing)
+		<-w.release
+	})
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestServeHTTPDoesNotBlockMetrics(t *testing.T) {
+	registry := NewRegistry()
+	// exposition larger than write buffer is written before the end of ServeHTTP
+	for i := 0; i < 200; i++ {
+		registry.Counter("calls_total", "Calls.", Labels{"n": strconv.Itoa(i)}).Inc()
+	}
+
+	w := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), writing: make(chan struct{}), release: make(chan struct{})}
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		registry.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	}()
+	<-w.writing
+
+	registered := make(chan struct{})
+	go func() {
+		defer close(registered)
+		registry.Counter("other_total", "Other.", nil).Inc()
+	}()
+	select {
+	case <-registered:
+	case <-time.After(time.Second):
+		t.Fatal("expected stalled scraper not to block metrics registry")
+	}
+
+	close(w.release)
+	<-served
+	if !strings.Contains(w.Body.String(), `calls_total{n="199"} 1`) {
+		t.Fatal("expected every series in exposition")
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var registry *Registry
+	m := registry.CacheMetrics("none")
+
+	// must not panic
+	m.Hit()
+	m.Load(1, time.Millisecond, nil)
+	registry.Counter("calls_total", "", nil).Inc()
+
+	if registry.Snapshot() != nil {
+		t.Fatal("expected empty snapshot")
+	}
+}
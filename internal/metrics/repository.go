@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"time"
+)
+
+const (
+	opGet        = "get"
+	opSave       = "save"
+	opListByItem = "list_by_item"
+	opList       = "list"
+	opDelete     = "delete"
+)
+
+type OrderRepoI interface {
+	Save(ctx context.Context, order *order.Order) (uint64, error)
+	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+	ListByItem(ctx context.Context, item string) ([]uint64, error)
+	List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error)
+	Delete(ctx context.Context, ID uint64) error
+}
+
+// Repo is a repository decorator counting db calls, errors and latency per operation
+type Repo struct {
+	orderRepository OrderRepoI
+	keys            *Counter
+	// op -> its series, resolved once, so calls don't take registry lock
+	ops map[string]repoOp
+}
+
+type repoOp struct {
+	calls   *Counter
+	errors  *Counter
+	latency *Histogram
+}
+
+func NewRepo(orderRepository OrderRepoI, registry *Registry) *Repo {
+	ops := make(map[string]repoOp)
+	for _, op := range []string{opGet, opSave, opListByItem, opList, opDelete} {
+		labels := Labels{"op": op}
+		ops[op] = repoOp{
+			calls:   registry.Counter("repository_calls_total", "Repository calls.", labels),
+			errors:  registry.Counter("repository_errors_total", "Failed repository calls.", labels),
+			latency: registry.Histogram("repository_call_duration_seconds", "Latency of repository calls.", nil, labels),
+		}
+	}
+
+	return &Repo{
+		orderRepository: orderRepository,
+		keys:            registry.Counter("repository_keys_total", "Keys requested from repository.", nil),
+		ops:             ops,
+	}
+}
+
+func (r *Repo) Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error) {
+	defer r.observe(opGet, time.Now())
+	r.keys.Add(float64(len(IDs)))
+
+	ordersMap, err := r.orderRepository.Get(ctx, IDs)
+	r.fail(opGet, err)
+	return ordersMap, err
+}
+
+func (r *Repo) Save(ctx context.Context, order *order.Order) (uint64, error) {
+	defer r.observe(opSave, time.Now())
+
+	ID, err := r.orderRepository.Save(ctx, order)
+	r.fail(opSave, err)
+	return ID, err
+}
+
+func (r *Repo) ListByItem(ctx context.Context, item string) ([]uint64, error) {
+	defer r.observe(opListByItem, time.Now())
+
+	IDs, err := r.orderRepository.ListByItem(ctx, item)
+	r.fail(opListByItem, err)
+	return IDs, err
+}
+
+func (r *Repo) List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error) {
+	defer r.observe(opList, time.Now())
+
+	IDs, next, err := r.orderRepository.List(ctx, cursor, limit)
+	r.fail(opList, err)
+	return IDs, next, err
+}
+
+func (r *Repo) Delete(ctx context.Context, ID uint64) error {
+	defer r.observe(opDelete, time.Now())
+
+	err := r.orderRepository.Delete(ctx, ID)
+	r.fail(opDelete, err)
+	return err
+}
+
+func (r *Repo) observe(op string, start time.Time) {
+	r.ops[op].calls.Inc()
+	r.ops[op].latency.Observe(time.Since(start).Seconds())
+}
+
+func (r *Repo) fail(op string, err error) {
+	if err == nil {
+		return
+	}
+	r.ops[op].errors.Inc()
+}
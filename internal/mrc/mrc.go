@@ -0,0 +1,223 @@
+package mrc
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+const (
+	PolicyLRU   = "lru"
+	PolicyFIFO  = "fifo"
+	PolicyClock = "clock"
+)
+
+// Policies are all simulated eviction policies
+var Policies = []string{PolicyLRU, PolicyFIFO, PolicyClock}
+
+// sampling modulus of SHARDS, key is sampled if hash(key) mod shardsModulus < threshold
+const shardsModulus = 1 << 24
+
+type Config struct {
+	Policies []string
+	// Sizes are cache sizes of the curves in keys
+	Sizes []int
+	// SampleRate is a share of keys simulated (SHARDS), 1 simulates every key.
+	// Small rates keep memory and time low at the cost of accuracy for small sizes.
+	SampleRate float64
+}
+
+func (c Config) Validate() error {
+	if len(c.Policies) == 0 {
+		return fmt.Errorf("no policies to simulate")
+	}
+	for _, policy := range c.Policies {
+		switch policy {
+		case PolicyLRU, PolicyFIFO, PolicyClock:
+		default:
+			return fmt.Errorf("unknown policy %q, expected one of %v", policy, Policies)
+		}
+	}
+	if len(c.Sizes) == 0 {
+		return fmt.Errorf("no cache sizes to simulate")
+	}
+	for _, size := range c.Sizes {
+		if size <= 0 {
+			return fmt.Errorf("cache size must be positive, got %d", size)
+		}
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample rate must be in range (0, 1], got %g", c.SampleRate)
+	}
+	return nil
+}
+
+// Point is a miss ratio of the policy at the cache size
+type Point struct {
+	Policy    string  `json:"policy"`
+	Size      int     `json:"size"`
+	MissRatio float64 `json:"miss_ratio"`
+}
+
+// Simulator replays key stream against all policies and sizes in one pass.
+// LRU curve comes from stack distances, so it's exact for every size at once,
+// FIFO and CLOCK have no stack property and are simulated per size.
+type Simulator struct {
+	cfg       Config
+	threshold uint64
+
+	// all and sampled accesses
+	seen     int
+	accesses int
+	lru      *stackDistance
+	caches   map[string][]cache
+}
+
+// cache is a simulated cache of fixed size
+type cache interface {
+	access(key uint64)
+	misses() int
+}
+
+func New(cfg Config) (*Simulator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Simulator{
+		cfg:       cfg,
+		threshold: uint64(math.Ceil(cfg.SampleRate * shardsModulus)),
+		caches:    make(map[string][]cache),
+	}
+	for _, policy := range cfg.Policies {
+		switch policy {
+		case PolicyLRU:
+			s.lru = newStackDistance()
+		case PolicyFIFO, PolicyClock:
+			caches := make([]cache, 0, len(cfg.Sizes))
+			for _, size := range cfg.Sizes {
+				if policy == PolicyFIFO {
+					caches = append(caches, newFIFO(s.scaled(size)))
+				} else {
+					caches = append(caches, newClock(s.scaled(size)))
+				}
+			}
+			s.caches[policy] = caches
+		}
+	}
+
+	return s, nil
+}
+
+// Access replays a single access of the key
+func (s *Simulator) Access(key uint64) {
+	s.seen++
+	if hash(key)%shardsModulus >= s.threshold {
+		return
+	}
+
+	s.accesses++
+	if s.lru != nil {
+		s.lru.access(key)
+	}
+	for _, caches := range s.caches {
+		for _, c := range caches {
+			c.access(key)
+		}
+	}
+}
+
+// Curves returns miss ratios of every policy and size, in order of config
+func (s *Simulator) Curves() []Point {
+	points := make([]Point, 0, len(s.cfg.Policies)*len(s.cfg.Sizes))
+	for _, policy := range s.cfg.Policies {
+		for i, size := range s.cfg.Sizes {
+			point := Point{Policy: policy, Size: size}
+			switch {
+			case s.accesses == 0:
+			case policy == PolicyLRU:
+				point.MissRatio = s.adjusted(s.lru.misses(s.scaled(size)))
+			default:
+				point.MissRatio = s.adjusted(s.caches[policy][i].misses())
+			}
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// adjusted returns miss ratio of sampled misses. Sample of skewed stream often has more or less
+// accesses than expected because of a few hot keys, like SHARDS_adj the difference is counted as hits.
+func (s *Simulator) adjusted(misses int) float64 {
+	expected := float64(s.seen) * s.cfg.SampleRate
+	if s.cfg.SampleRate == 1 {
+		expected = float64(s.accesses)
+	}
+
+	ratio := float64(misses) / expected
+	if ratio > 1 {
+		return 1
+	}
+	return ratio
+}
+
+// scaled is the size of simulated cache for sampled keys
+func (s *Simulator) scaled(size int) int {
+	scaled := int(math.Round(float64(size) * s.cfg.SampleRate))
+	if scaled < 1 {
+		return 1
+	}
+	return scaled
+}
+
+// hash spreads sequential IDs uniformly, so sampling doesn't pick ranges of keys (splitmix64)
+func hash(key uint64) uint64 {
+	key += 0x9e3779b97f4a7c15
+	key = (key ^ (key >> 30)) * 0xbf58476d1ce4e5b9
+	key = (key ^ (key >> 27)) * 0x94d049bb133111eb
+	return key ^ (key >> 31)
+}
+
+// LinearSizes returns count sizes evenly spread up to max
+func LinearSizes(max, count int) []int {
+	if count <= 0 || max <= 0 {
+		return nil
+	}
+	if count > max {
+		count = max
+	}
+
+	sizes := make([]int, 0, count)
+	for i := 1; i <= count; i++ {
+		sizes = append(sizes, max*i/count)
+	}
+	return sizes
+}
+
+// WriteCSV writes curves as "policy,size,miss_ratio" rows sorted by policy and size
+func WriteCSV(w io.Writer, points []Point) error {
+	sorted := make([]Point, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Policy != sorted[j].Policy {
+			return sorted[i].Policy < sorted[j].Policy
+		}
+		return sorted[i].Size < sorted[j].Size
+	})
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"policy", "size", "miss_ratio"}); err != nil {
+		return err
+	}
+	for _, p := range sorted {
+		record := []string{p.Policy, strconv.Itoa(p.Size), strconv.FormatFloat(p.MissRatio, 'f', 6, 64)}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
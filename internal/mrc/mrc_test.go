@@ -0,0 +1,133 @@
+package mrc
+
+import (
+	"bytes"
+	"caching-strategies/internal/lru"
+	"caching-strategies/internal/workload"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func zipfStream(keys uint64, n int) []uint64 {
+	g := workload.Zipf(rand.New(rand.NewSource(1)), keys, 0.99)
+	stream := make([]uint64, n)
+	for i := range stream {
+		stream[i] = g.Next()
+	}
+	return stream
+}
+
+func simulate(t *testing.T, cfg Config, stream []uint64) []Point {
+	t.Helper()
+
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range stream {
+		s.Access(key)
+	}
+	return s.Curves()
+}
+
+// stack distance curve matches real LRU at every size
+func TestLRUCurve(t *testing.T) {
+	stream := zipfStream(2000, 20000)
+	sizes := []int{1, 10, 100, 500, 1000, 2000}
+
+	points := simulate(t, Config{Policies: []string{PolicyLRU}, Sizes: sizes, SampleRate: 1}, stream)
+	for i, size := range sizes {
+		cache := lru.NewLRU[uint64, struct{}](size, nil, 0)
+		misses := 0
+		for _, key := range stream {
+			if _, ok := cache.Get(key); !ok {
+				misses++
+				cache.Add(key, struct{}{})
+			}
+		}
+
+		expected := float64(misses) / float64(len(stream))
+		if points[i].MissRatio != expected {
+			t.Fatalf("size %d: expected miss ratio %.4f, got %.4f", size, expected, points[i].MissRatio)
+		}
+		if i > 0 && points[i].MissRatio > points[i-1].MissRatio {
+			t.Fatalf("expected LRU miss ratio not to grow with size: %v", points)
+		}
+	}
+
+	// scan of more keys than cache never hits LRU
+	scan := make([]uint64, 0, 3000)
+	for i := 0; i < 3; i++ {
+		for key := uint64(0); key < 1000; key++ {
+			scan = append(scan, key)
+		}
+	}
+	points = simulate(t, Config{Policies: []string{PolicyLRU}, Sizes: []int{999, 1000}, SampleRate: 1}, scan)
+	if points[0].MissRatio != 1 || points[1].MissRatio != 1.0/3 {
+		t.Fatalf("unexpected scan curve: %v", points)
+	}
+}
+
+func TestFIFOAndClock(t *testing.T) {
+	stream := []uint64{1, 2, 3, 1, 4, 1}
+	points := simulate(t, Config{Policies: []string{PolicyFIFO, PolicyClock}, Sizes: []int{3}, SampleRate: 1}, stream)
+
+	// fifo evicts 1 despite the hit, clock gives it a second chance and evicts 2
+	if points[0].MissRatio != 5.0/6 || points[1].MissRatio != 4.0/6 {
+		t.Fatalf("unexpected miss ratios: %v", points)
+	}
+}
+
+// SHARDS sampling of 10% of keys stays close to the full simulation
+func TestSampling(t *testing.T) {
+	stream := zipfStream(20000, 200000)
+	sizes := []int{1000, 2000, 5000, 10000}
+	policies := []string{PolicyLRU, PolicyClock}
+
+	full := simulate(t, Config{Policies: policies, Sizes: sizes, SampleRate: 1}, stream)
+	sampled := simulate(t, Config{Policies: policies, Sizes: sizes, SampleRate: 0.1}, stream)
+
+	for i := range full {
+		if diff := math.Abs(full[i].MissRatio - sampled[i].MissRatio); diff > 0.02 {
+			t.Fatalf("%s at %d: sampled %.4f is far from %.4f",
+				full[i].Policy, full[i].Size, sampled[i].MissRatio, full[i].MissRatio)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{Sizes: []int{1}, SampleRate: 1},
+		{Policies: []string{"arc"}, Sizes: []int{1}, SampleRate: 1},
+		{Policies: []string{PolicyLRU}, SampleRate: 1},
+		{Policies: []string{PolicyLRU}, Sizes: []int{0}, SampleRate: 1},
+		{Policies: []string{PolicyLRU}, Sizes: []int{1}, SampleRate: 0},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+
+	if sizes := LinearSizes(100, 4); len(sizes) != 4 || sizes[0] != 25 || sizes[3] != 100 {
+		t.Fatalf("unexpected sizes: %v", sizes)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, []Point{
+		{Policy: PolicyLRU, Size: 20, MissRatio: 0.25},
+		{Policy: PolicyFIFO, Size: 10, MissRatio: 0.5},
+		{Policy: PolicyLRU, Size: 10, MissRatio: 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "policy,size,miss_ratio\nfifo,10,0.500000\nlru,10,0.500000\nlru,20,0.250000\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected csv:\n%s", strings.TrimSpace(buf.String()))
+	}
+}
@@ -0,0 +1,91 @@
+package mrc
+
+type stats struct {
+	hits, total int
+}
+
+func (s *stats) record(hit bool) {
+	s.total++
+	if hit {
+		s.hits++
+	}
+}
+
+func (s *stats) misses() int {
+	return s.total - s.hits
+}
+
+// fifo evicts the oldest inserted key, hits don't change the order
+type fifo struct {
+	stats
+	keys map[uint64]struct{}
+	// ring of inserted keys, next is the slot of the oldest one when full
+	ring []uint64
+	next int
+}
+
+func newFIFO(size int) *fifo {
+	return &fifo{
+		keys: make(map[uint64]struct{}, size),
+		ring: make([]uint64, 0, size),
+	}
+}
+
+func (c *fifo) access(key uint64) {
+	_, hit := c.keys[key]
+	c.record(hit)
+	if hit {
+		return
+	}
+
+	if len(c.ring) < cap(c.ring) {
+		c.ring = append(c.ring, key)
+	} else {
+		delete(c.keys, c.ring[c.next])
+		c.ring[c.next] = key
+		c.next = (c.next + 1) % len(c.ring)
+	}
+	c.keys[key] = struct{}{}
+}
+
+// clock approximates LRU with a reference bit per slot: hand skips and clears referenced slots
+type clock struct {
+	stats
+	slots map[uint64]int
+	keys  []uint64
+	refs  []bool
+	hand  int
+}
+
+func newClock(size int) *clock {
+	return &clock{
+		slots: make(map[uint64]int, size),
+		keys:  make([]uint64, 0, size),
+		refs:  make([]bool, 0, size),
+	}
+}
+
+func (c *clock) access(key uint64) {
+	slot, hit := c.slots[key]
+	c.record(hit)
+	if hit {
+		c.refs[slot] = true
+		return
+	}
+
+	if len(c.keys) < cap(c.keys) {
+		c.slots[key] = len(c.keys)
+		c.keys = append(c.keys, key)
+		c.refs = append(c.refs, false)
+		return
+	}
+
+	for c.refs[c.hand] {
+		c.refs[c.hand] = false
+		c.hand = (c.hand + 1) % len(c.keys)
+	}
+	delete(c.slots, c.keys[c.hand])
+	c.keys[c.hand] = key
+	c.slots[key] = c.hand
+	c.hand = (c.hand + 1) % len(c.keys)
+}
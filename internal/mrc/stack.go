@@ -0,0 +1,97 @@
+package mrc
+
+import "sort"
+
+// stackDistance computes LRU stack distances: distance of an access is the number of distinct keys
+// accessed since the previous access of the same key plus one, the access hits LRU of any size >= distance.
+// Fenwick tree over access times counts keys whose last access is after the previous one.
+type stackDistance struct {
+	// last access time of every key
+	last map[uint64]int
+	tree fenwick
+	now  int
+
+	// distances[d] is the number of accesses with distance d, cold misses have no distance
+	distances []int
+	total     int
+}
+
+func newStackDistance() *stackDistance {
+	return &stackDistance{
+		last: make(map[uint64]int),
+		tree: newFenwick(1024),
+	}
+}
+
+func (s *stackDistance) access(key uint64) {
+	s.total++
+	if s.now+1 >= s.tree.size() {
+		s.compact()
+	}
+	s.now++
+
+	prev, ok := s.last[key]
+	if ok {
+		distance := s.tree.sum(s.now-1) - s.tree.sum(prev) + 1
+		for len(s.distances) <= distance {
+			s.distances = append(s.distances, 0)
+		}
+		s.distances[distance]++
+		s.tree.add(prev, -1)
+	}
+
+	s.last[key] = s.now
+	s.tree.add(s.now, 1)
+}
+
+// misses of LRU of the size, cold misses included
+func (s *stackDistance) misses(size int) int {
+	hits := 0
+	for d := 1; d < len(s.distances) && d <= size; d++ {
+		hits += s.distances[d]
+	}
+	return s.total - hits
+}
+
+// compact renumbers last access times of keys from 1 keeping their order,
+// so the tree is bounded by the number of distinct keys instead of the stream length
+func (s *stackDistance) compact() {
+	keys := make([]uint64, 0, len(s.last))
+	for key := range s.last {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return s.last[keys[i]] < s.last[keys[j]] })
+
+	s.tree = newFenwick(2*len(keys) + 1024)
+	for i, key := range keys {
+		s.last[key] = i + 1
+		s.tree.add(i+1, 1)
+	}
+	s.now = len(keys)
+}
+
+// fenwick is a binary indexed tree of counters at positions [1, size)
+type fenwick []int
+
+func newFenwick(size int) fenwick {
+	return make(fenwick, size)
+}
+
+func (f fenwick) size() int {
+	return len(f)
+}
+
+func (f fenwick) add(i, delta int) {
+	for ; i < len(f); i += i & -i {
+		f[i] += delta
+	}
+}
+
+// sum of positions [1, i]
+func (f fenwick) sum(i int) int {
+	total := 0
+	for ; i > 0; i -= i & -i {
+		total += f[i]
+	}
+	return total
+}
@@ -3,7 +3,11 @@ package order
 import "time"
 
 type Order struct {
-	ID        uint64
-	Item      string
-	ExpiredAt time.Time
+	ID         uint64 `json:"id"`
+	CustomerID uint64 `json:"customer_id"`
+	Item       string `json:"item"`
+	// assigned by repository on every save, grows monotonically
+	Version uint64 `json:"version"`
+	// cache metadata, not exposed to clients
+	ExpiredAt time.Time `json:"-"`
 }
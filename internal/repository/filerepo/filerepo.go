@@ -0,0 +1,434 @@
+package filerepo
+
+import (
+	"bufio"
+	"bytes"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// location of the latest put record of an order in log
+type location struct {
+	offset int64
+	size   int
+	item   string
+}
+
+// Stats are log size counters, Dead bytes are records of overwritten and deleted orders
+type Stats struct {
+	Live int
+	Size int64
+	Dead int64
+}
+
+// Repo is a file-backed repository: every Save and Delete is appended to a log, in-memory index
+// points to the latest record of every order and Get reads records from file.
+// Log is compacted when dead records take compaction ratio of it, torn tail left by crash is truncated on Open.
+type Repo struct {
+	path string
+	// sync makes every write durable with fsync
+	sync         bool
+	compactRatio float64
+	compactMin   int64
+
+	mu    sync.RWMutex
+	f     *os.File
+	size  int64
+	dead  int64
+	index map[uint64]location
+	// secondary index: item -> order IDs
+	items map[string]map[uint64]struct{}
+	// last assigned order version, versions are unique across orders
+	seq uint64
+}
+
+// Option configures Repo
+type Option func(*Repo)
+
+// WithSync makes Save and Delete return only after the record is on disk
+func WithSync() Option {
+	return func(r *Repo) {
+		r.sync = true
+	}
+}
+
+// WithCompaction compacts log of at least minSize bytes when dead records take ratio of it, 0 ratio disables it
+func WithCompaction(ratio float64, minSize int64) Option {
+	return func(r *Repo) {
+		r.compactRatio = ratio
+		r.compactMin = minSize
+	}
+}
+
+// Open opens log at path or creates it and rebuilds index from it
+func Open(path string, opts ...Option) (*Repo, error) {
+	r := &Repo{
+		path:         path,
+		compactRatio: 0.5,
+		compactMin:   1 << 20,
+		index:        make(map[uint64]location),
+		items:        make(map[string]map[uint64]struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open repository log: %w", err)
+	}
+	r.f = f
+	if err := r.replay(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// replay rebuilds index from log, torn tail is truncated,
+// corrupted record followed by others is an error, truncating it would lose them
+func (r *Repo) replay() error {
+	info, err := r.f.Stat()
+	if err != nil {
+		return fmt.Errorf("replay repository log: %w", err)
+	}
+
+	reader := bufio.NewReader(r.f)
+	for {
+		rec, n, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if errors.Is(err, errTorn) && !r.isTail(r.size, info.Size()) {
+			return fmt.Errorf("replay repository log: record at offset %d of %d bytes: %w", r.size, info.Size(), err)
+		}
+		if errors.Is(err, errTorn) {
+			log.Warn().Err(err).Str("path", r.path).Int64("offset", r.size).Msg("repository log truncated")
+			if err := r.f.Truncate(r.size); err != nil {
+				return fmt.Errorf("truncate repository log: %w", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("replay repository log: %w", err)
+		}
+
+		r.apply(rec, r.size, n)
+		r.size += int64(n)
+	}
+}
+
+// isTail tells whether record at offset, as its header declares, reaches the end of log of size bytes,
+// crash leaves only the last record torn, header may be torn too
+func (r *Repo) isTail(offset, size int64) bool {
+	header := make([]byte, headerSize)
+	if _, err := r.f.ReadAt(header, offset); err != nil {
+		return true
+	}
+	return offset+headerSize+int64(binary.LittleEndian.Uint32(header[4:])) >= size
+}
+
+// apply updates index with record written at offset, must be called under r.mu
+func (r *Repo) apply(rec record, offset int64, size int) {
+	r.seq = max(r.seq, rec.order.Version)
+	if rec.op == opSeq {
+		r.dead += int64(size)
+		return
+	}
+
+	ID := rec.order.ID
+	if prev, ok := r.index[ID]; ok {
+		r.dead += int64(prev.size)
+		r.unindex(ID, prev.item)
+		delete(r.index, ID)
+	}
+
+	switch rec.op {
+	case opPut:
+		r.index[ID] = location{offset: offset, size: size, item: rec.order.Item}
+		if r.items[rec.order.Item] == nil {
+			r.items[rec.order.Item] = make(map[uint64]struct{})
+		}
+		r.items[rec.order.Item][ID] = struct{}{}
+	default:
+		// deletion is garbage itself once the put it deletes is compacted
+		r.dead += int64(size)
+	}
+}
+
+// Get returns orders by IDs, missing IDs are skipped like in IN query
+func (r *Repo) Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error) {
+	_, span := tracing.Start(ctx, "repository.Get")
+	defer span.End()
+	span.SetAttribute("ids", len(IDs))
+
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ordersMap := make(map[uint64]order.Order, len(IDs))
+	for _, ID := range IDs {
+		loc, ok := r.index[ID]
+		if !ok {
+			continue
+		}
+
+		buf := make([]byte, loc.size)
+		if _, err := r.f.ReadAt(buf, loc.offset); err != nil {
+			err = fmt.Errorf("read order %d: %w", ID, err)
+			span.RecordError(err)
+			return nil, err
+		}
+		rec, _, err := readRecord(bytes.NewReader(buf))
+		if err != nil {
+			err = fmt.Errorf("read order %d: %w", ID, err)
+			span.RecordError(err)
+			return nil, err
+		}
+		ordersMap[ID] = rec.order
+	}
+
+	return ordersMap, nil
+}
+
+// ListByItem returns sorted IDs of orders with given item
+func (r *Repo) ListByItem(ctx context.Context, item string) ([]uint64, error) {
+	_, span := tracing.Start(ctx, "repository.ListByItem")
+	defer span.End()
+	span.SetAttribute("item", item)
+
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	IDs := make([]uint64, 0, len(r.items[item]))
+	for ID := range r.items[item] {
+		IDs = append(IDs, ID)
+	}
+	sort.Slice(IDs, func(i, j int) bool { return IDs[i] < IDs[j] })
+
+	return IDs, nil
+}
+
+// List returns up to limit sorted IDs starting from cursor and the cursor of the next page,
+// next cursor is 0 if there are no more orders
+func (r *Repo) List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error) {
+	_, span := tracing.Start(ctx, "repository.List")
+	defer span.End()
+	span.SetAttribute("cursor", cursor)
+	span.SetAttribute("limit", limit)
+
+	if limit <= 0 {
+		err := fmt.Errorf("invalid limit: %d", limit)
+		span.RecordError(err)
+		return nil, 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+		return nil, 0, err
+	}
+
+	r.mu.RLock()
+	IDs := make([]uint64, 0, limit)
+	for ID := range r.index {
+		if ID >= cursor {
+			IDs = append(IDs, ID)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Slice(IDs, func(i, j int) bool { return IDs[i] < IDs[j] })
+
+	if len(IDs) <= limit {
+		return IDs, 0, nil
+	}
+
+	IDs = IDs[:limit]
+	return IDs, IDs[limit-1] + 1, nil
+}
+
+// Save upserts order and assigns it a new version, cancelled save isn't applied
+func (r *Repo) Save(ctx context.Context, order *order.Order) (uint64, error) {
+	_, span := tracing.Start(ctx, "repository.Save")
+	defer span.End()
+
+	// record of a larger payload would be read back as torn and truncated with everything after it
+	if len(order.Item) > repo.MaxItemSize || fixedPayloadSize+len(order.Item) > maxPayloadSize {
+		err := fmt.Errorf("%w: %d bytes", repo.ErrItemTooLarge, len(order.Item))
+		span.RecordError(err)
+		return 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	saved := *order
+	saved.Version = r.seq + 1
+	if err := r.append(record{op: opPut, order: saved}); err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	order.Version = saved.Version
+	return order.ID, nil
+}
+
+// Delete removes order, returns ErrNotFound if it doesn't exist
+func (r *Repo) Delete(ctx context.Context, ID uint64) error {
+	_, span := tracing.Start(ctx, "repository.Delete")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.index[ID]; !ok {
+		span.RecordError(repo.ErrNotFound)
+		return repo.ErrNotFound
+	}
+	if err := r.append(record{op: opDelete, order: order.Order{ID: ID, Version: r.seq}}); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// append writes record to the end of log and applies it, failed write is cut off, must be called under r.mu
+func (r *Repo) append(rec record) error {
+	buf := rec.encode()
+	if _, err := r.f.WriteAt(buf, r.size); err != nil {
+		_ = r.f.Truncate(r.size)
+		return fmt.Errorf("write repository log: %w", err)
+	}
+	if r.sync {
+		if err := r.f.Sync(); err != nil {
+			_ = r.f.Truncate(r.size)
+			return fmt.Errorf("sync repository log: %w", err)
+		}
+	}
+
+	r.apply(rec, r.size, len(buf))
+	r.size += int64(len(buf))
+
+	if r.compactRatio > 0 && r.size >= r.compactMin && float64(r.dead) >= r.compactRatio*float64(r.size) {
+		// the write is already durable, failed compaction only leaves garbage in log
+		if err := r.compact(); err != nil {
+			log.Err(err).Str("path", r.path).Msg("repository log compaction error")
+		}
+	}
+	return nil
+}
+
+// Compact rewrites log with only the latest records of existing orders
+func (r *Repo) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.compact()
+}
+
+// compact writes live records to a new file and renames it over log, must be called under r.mu
+func (r *Repo) compact() error {
+	tmpPath := r.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("compact repository log: %w", err)
+	}
+	fail := func(err error) error {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("compact repository log: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	seq := record{op: opSeq, order: order.Order{Version: r.seq}}.encode()
+	if _, err := w.Write(seq); err != nil {
+		return fail(err)
+	}
+
+	IDs := make([]uint64, 0, len(r.index))
+	for ID := range r.index {
+		IDs = append(IDs, ID)
+	}
+	sort.Slice(IDs, func(i, j int) bool { return IDs[i] < IDs[j] })
+
+	index := make(map[uint64]location, len(IDs))
+	offset := int64(len(seq))
+	for _, ID := range IDs {
+		loc := r.index[ID]
+		buf := make([]byte, loc.size)
+		if _, err := r.f.ReadAt(buf, loc.offset); err != nil {
+			return fail(err)
+		}
+		if _, err := w.Write(buf); err != nil {
+			return fail(err)
+		}
+		index[ID] = location{offset: offset, size: loc.size, item: loc.item}
+		offset += int64(loc.size)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	// handle of renamed file stays valid, it becomes the log
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		return fail(err)
+	}
+
+	_ = r.f.Close()
+	r.f = tmp
+	r.index = index
+	r.size = offset
+	r.dead = int64(len(seq))
+	return nil
+}
+
+// Stats returns log size counters
+func (r *Repo) Stats() Stats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return Stats{Live: len(r.index), Size: r.size, Dead: r.dead}
+}
+
+func (r *Repo) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.f.Close()
+}
+
+// unindex removes order ID from the item set, must be called under r.mu
+func (r *Repo) unindex(ID uint64, item string) {
+	delete(r.items[item], ID)
+	if len(r.items[item]) == 0 {
+		delete(r.items, item)
+	}
+}
@@ -0,0 +1,205 @@
+package filerepo
+
+import (
+	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/repository/repotest"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func open(t *testing.T, path string, opts ...Option) *Repo {
+	t.Helper()
+
+	r, err := Open(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.OrderRepoI {
+		return open(t, filepath.Join(t.TempDir(), "orders.log"))
+	})
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.log")
+	r := open(t, path, WithSync())
+
+	saved := order.Order{ID: 1, CustomerID: 10, Item: "book"}
+	if _, err := r.Save(ctx, &saved); err != nil {
+		t.Fatal(err)
+	}
+	deleted := order.Order{ID: 2, Item: "book"}
+	if _, err := r.Save(ctx, &deleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r = open(t, path)
+	ordersMap, err := r.Get(ctx, []uint64{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ordersMap) != 1 || ordersMap[1] != saved {
+		t.Fatalf("expected saved order after reopen, got %v", ordersMap)
+	}
+	if IDs, err := r.ListByItem(ctx, "book"); err != nil || len(IDs) != 1 || IDs[0] != 1 {
+		t.Fatalf("expected item index after reopen, got %v, %v", IDs, err)
+	}
+
+	// versions go on from the log
+	recreated := order.Order{ID: 2}
+	if _, err := r.Save(ctx, &recreated); err != nil {
+		t.Fatal(err)
+	}
+	if recreated.Version <= deleted.Version {
+		t.Fatalf("expected version to grow after reopen, got %d after %d", recreated.Version, deleted.Version)
+	}
+}
+
+func TestTornTail(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.log")
+	r := open(t, path)
+	for _, ID := range []uint64{1, 2} {
+		if _, err := r.Save(ctx, &order.Order{ID: ID, Item: "book"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	size := r.Stats().Size
+	_ = r.Close()
+
+	// crash in the middle of the third write
+	tail := record{op: opPut, order: order.Order{ID: 3, Item: "pen"}}.encode()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(tail[:len(tail)-2]); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	r = open(t, path)
+	if stats := r.Stats(); stats.Live != 2 || stats.Size != size {
+		t.Fatalf("expected torn record to be truncated, got %+v", stats)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != size {
+		t.Fatalf("expected log of %d bytes, got %v, %v", size, info, err)
+	}
+	if _, err := r.Save(ctx, &order.Order{ID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if ordersMap, err := r.Get(ctx, []uint64{1, 2, 3}); err != nil || len(ordersMap) != 3 {
+		t.Fatalf("expected writes after truncated tail, got %v, %v", ordersMap, err)
+	}
+}
+
+func TestCorruptedRecord(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.log")
+	r := open(t, path)
+	for _, ID := range []uint64{1, 2, 3} {
+		if _, err := r.Save(ctx, &order.Order{ID: ID, Item: "book"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	size := r.Stats().Size
+	_ = r.Close()
+
+	// flip a byte of the second record's item, the third one follows it
+	second := int64(len(record{op: opPut, order: order.Order{ID: 1, Item: "book"}}.encode()))
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte{'x'}, second+headerSize+fixedPayloadSize); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if r, err := Open(path); err == nil {
+		_ = r.Close()
+		t.Fatal("expected corrupted record in the middle of log to fail open")
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != size {
+		t.Fatalf("expected log of %d bytes to be kept, got %v, %v", size, info, err)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.log")
+	r := open(t, path, WithCompaction(0, 0))
+
+	for i := 0; i < 10; i++ {
+		for ID := uint64(0); ID < 10; ID++ {
+			if _, err := r.Save(ctx, &order.Order{ID: ID, CustomerID: uint64(i), Item: "book"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var last uint64
+	for ID := uint64(5); ID < 10; ID++ {
+		ordersMap, err := r.Get(ctx, []uint64{ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = max(last, ordersMap[ID].Version)
+		if err := r.Delete(ctx, ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before := r.Stats()
+	if err := r.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after := r.Stats()
+	if after.Live != 5 || after.Size >= before.Size/5 {
+		t.Fatalf("expected log to shrink to live orders, got %+v before %+v", after, before)
+	}
+
+	_ = r.Close()
+	r = open(t, path, WithCompaction(0.5, 0))
+	ordersMap, err := r.Get(ctx, []uint64{0, 1, 2, 3, 4, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ordersMap) != 5 || ordersMap[4].CustomerID != 9 {
+		t.Fatalf("expected the latest live orders after compaction, got %v", ordersMap)
+	}
+
+	// the deleted order had the last version, it's kept by seq record
+	recreated := order.Order{ID: 9}
+	if _, err := r.Save(ctx, &recreated); err != nil {
+		t.Fatal(err)
+	}
+	if recreated.Version <= last {
+		t.Fatalf("expected version to grow after compaction, got %d after %d", recreated.Version, last)
+	}
+
+	// overwrites compact the log automatically
+	for i := 0; i < 50; i++ {
+		if _, err := r.Save(ctx, &order.Order{ID: 0, CustomerID: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := r.Stats(); float64(stats.Dead) >= 0.5*float64(stats.Size) {
+		t.Fatalf("expected log to be compacted, got %+v", stats)
+	}
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Fatalf("expected no leftover compaction file, got %v", err)
+	}
+}
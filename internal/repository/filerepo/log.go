@@ -0,0 +1,105 @@
+package filerepo
+
+import (
+	"caching-strategies/internal/repository/entity/order"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	opPut    byte = 1
+	opDelete byte = 2
+	// opSeq record keeps the last assigned version when compaction drops records of deleted orders,
+	// so re-created order never repeats its old version after restart
+	opSeq byte = 3
+
+	// headerSize is crc32 and length of payload
+	headerSize = 4 + 4
+	// fixedPayloadSize is op, ID, version, customer ID and item length
+	fixedPayloadSize = 1 + 8 + 8 + 8 + 4
+	// maxPayloadSize guards against allocating garbage length of a torn record, Save rejects larger orders
+	maxPayloadSize = 1 << 20
+)
+
+// errTorn is a record cut by crash or corrupted, log is truncated before it if it's the last one
+var errTorn = errors.New("torn log record")
+
+// record is an entry of append-only log:
+//
+//	crc32 of payload uint32 | payload length uint32 | payload
+//	payload: op byte | ID uint64 | version uint64 | customer ID uint64 | item length uint32 | item
+//
+// delete records carry only ID and version of the deletion, seq records only version, numbers are little endian
+type record struct {
+	op    byte
+	order order.Order
+}
+
+func (r record) encode() []byte {
+	payloadSize := fixedPayloadSize + len(r.order.Item)
+	buf := make([]byte, headerSize, headerSize+payloadSize)
+	buf = append(buf, r.op)
+	buf = binary.LittleEndian.AppendUint64(buf, r.order.ID)
+	buf = binary.LittleEndian.AppendUint64(buf, r.order.Version)
+	buf = binary.LittleEndian.AppendUint64(buf, r.order.CustomerID)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(r.order.Item)))
+	buf = append(buf, r.order.Item...)
+
+	binary.LittleEndian.PutUint32(buf[0:], crc32.ChecksumIEEE(buf[headerSize:]))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(payloadSize))
+	return buf
+}
+
+// readRecord reads record at the current position of r, returns its size,
+// errTorn if the record is incomplete or its checksum doesn't match, io.EOF at the end of log
+func readRecord(r io.Reader) (record, int, error) {
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(r, header)
+	if errors.Is(err, io.EOF) {
+		return record{}, 0, io.EOF
+	}
+	if err != nil {
+		return record{}, 0, fmt.Errorf("%w: header of %d bytes", errTorn, n)
+	}
+
+	size := binary.LittleEndian.Uint32(header[4:])
+	if size < fixedPayloadSize || size > maxPayloadSize {
+		return record{}, 0, fmt.Errorf("%w: payload of %d bytes", errTorn, size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return record{}, 0, fmt.Errorf("%w: %w", errTorn, err)
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[0:]) {
+		return record{}, 0, fmt.Errorf("%w: checksum mismatch", errTorn)
+	}
+
+	rec, err := decodePayload(payload)
+	if err != nil {
+		return record{}, 0, err
+	}
+	return rec, headerSize + int(size), nil
+}
+
+func decodePayload(payload []byte) (record, error) {
+	rec := record{
+		op: payload[0],
+		order: order.Order{
+			ID:         binary.LittleEndian.Uint64(payload[1:]),
+			Version:    binary.LittleEndian.Uint64(payload[9:]),
+			CustomerID: binary.LittleEndian.Uint64(payload[17:]),
+		},
+	}
+	itemLen := binary.LittleEndian.Uint32(payload[25:])
+	if int(itemLen) != len(payload)-fixedPayloadSize {
+		return record{}, fmt.Errorf("%w: item of %d bytes in payload of %d", errTorn, itemLen, len(payload))
+	}
+	if rec.op != opPut && rec.op != opDelete && rec.op != opSeq {
+		return record{}, fmt.Errorf("%w: unknown op %d", errTorn, rec.op)
+	}
+	rec.order.Item = string(payload[fixedPayloadSize:])
+	return rec, nil
+}
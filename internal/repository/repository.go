@@ -1,35 +1,85 @@
 package repository
 
 import (
+	"caching-strategies/internal/clock"
 	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
+// ErrNotFound is returned when order doesn't exist
+var ErrNotFound = errors.New("order not found")
+
+// ErrItemTooLarge is returned by Save of order with item longer than MaxItemSize
+var ErrItemTooLarge = errors.New("order item too large")
+
+// MaxItemSize is the longest item in bytes every repository stores
+const MaxItemSize = 64 << 10
+
 type Repo struct {
 	DB sync.Map
+
+	// secondary index: item -> order IDs
+	mu    sync.RWMutex
+	items map[string]map[uint64]struct{}
+	// last assigned order version, versions are unique across orders,
+	// so re-created order never repeats its old version
+	seq uint64
+
+	// latency mock sleeps on the clock and returns ctx error when ctx is done
+	clock clock.Clock
 }
 
-func New() *Repo {
-	return &Repo{}
+// Option configures Repo
+type Option func(*Repo)
+
+// WithClock replaces real clock of latency mock, clock.Fake makes calls instant
+func WithClock(c clock.Clock) Option {
+	return func(r *Repo) {
+		r.clock = c
+	}
 }
 
+func New(opts ...Option) *Repo {
+	r := &Repo{
+		items: make(map[string]map[uint64]struct{}),
+		clock: clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Get returns orders by IDs, missing IDs are skipped like in IN query
 func (r *Repo) Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error) {
+	_, span := tracing.Start(ctx, "repository.Get")
+	defer span.End()
+	span.SetAttribute("ids", len(IDs))
+
 	ordersMap := make(map[uint64]order.Order, len(IDs))
 
 	for _, ID := range IDs {
 		// mock db latency
-		time.Sleep(1 * time.Millisecond)
+		if err := r.clock.SleepContext(ctx, 1*time.Millisecond); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
 
 		value, ok := r.DB.Load(ID)
 		if !ok {
-			return nil, fmt.Errorf("db loading error")
+			continue
 		}
 		ord, ok := value.(order.Order)
 		if !ok {
-			return nil, fmt.Errorf("type casting error")
+			err := fmt.Errorf("type casting error")
+			span.RecordError(err)
+			return nil, err
 		}
 		ordersMap[ord.ID] = ord
 	}
@@ -37,11 +87,140 @@ func (r *Repo) Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, e
 	return ordersMap, nil
 }
 
-func (r *Repo) Save(ctx context.Context, order *order.Order) (uint64, error) {
+// ListByItem returns sorted IDs of orders with given item
+func (r *Repo) ListByItem(ctx context.Context, item string) ([]uint64, error) {
+	_, span := tracing.Start(ctx, "repository.ListByItem")
+	defer span.End()
+	span.SetAttribute("item", item)
+
 	// mock db latency
-	time.Sleep(1 * time.Millisecond)
+	if err := r.clock.SleepContext(ctx, 1*time.Millisecond); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	IDs := make([]uint64, 0, len(r.items[item]))
+	for ID := range r.items[item] {
+		IDs = append(IDs, ID)
+	}
+	sort.Slice(IDs, func(i, j int) bool { return IDs[i] < IDs[j] })
+
+	return IDs, nil
+}
+
+// List returns up to limit sorted IDs starting from cursor and the cursor of the next page,
+// next cursor is 0 if there are no more orders
+func (r *Repo) List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error) {
+	_, span := tracing.Start(ctx, "repository.List")
+	defer span.End()
+	span.SetAttribute("cursor", cursor)
+	span.SetAttribute("limit", limit)
+
+	if limit <= 0 {
+		err := fmt.Errorf("invalid limit: %d", limit)
+		span.RecordError(err)
+		return nil, 0, err
+	}
+
+	// mock db latency
+	if err := r.clock.SleepContext(ctx, 1*time.Millisecond); err != nil {
+		span.RecordError(err)
+		return nil, 0, err
+	}
+
+	IDs := make([]uint64, 0, limit)
+	r.DB.Range(func(key, _ any) bool {
+		if ID, ok := key.(uint64); ok && ID >= cursor {
+			IDs = append(IDs, ID)
+		}
+		return true
+	})
+	sort.Slice(IDs, func(i, j int) bool { return IDs[i] < IDs[j] })
+
+	if len(IDs) <= limit {
+		return IDs, 0, nil
+	}
+
+	IDs = IDs[:limit]
+	return IDs, IDs[limit-1] + 1, nil
+}
+
+// Save upserts order and assigns it a new version
+func (r *Repo) Save(ctx context.Context, order *order.Order) (uint64, error) {
+	_, span := tracing.Start(ctx, "repository.Save")
+	defer span.End()
+
+	if len(order.Item) > MaxItemSize {
+		err := fmt.Errorf("%w: %d bytes", ErrItemTooLarge, len(order.Item))
+		span.RecordError(err)
+		return 0, err
+	}
 
-	order.ExpiredAt = time.Now()
+	// mock db latency, cancelled save isn't applied
+	if err := r.clock.SleepContext(ctx, 1*time.Millisecond); err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	order.ExpiredAt = r.clock.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	order.Version = r.seq
+	r.index(order.ID, order.Item)
 	r.DB.Store(order.ID, *order)
 	return order.ID, nil
 }
+
+// Delete removes order, returns ErrNotFound if it doesn't exist
+func (r *Repo) Delete(ctx context.Context, ID uint64) error {
+	_, span := tracing.Start(ctx, "repository.Delete")
+	defer span.End()
+
+	// mock db latency
+	if err := r.clock.SleepContext(ctx, 1*time.Millisecond); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, ok := r.DB.LoadAndDelete(ID)
+	if !ok {
+		span.RecordError(ErrNotFound)
+		return ErrNotFound
+	}
+	if prev, ok := value.(order.Order); ok {
+		r.unindex(ID, prev.Item)
+	}
+
+	return nil
+}
+
+// index moves order ID to the new item set, must be called under r.mu
+func (r *Repo) index(ID uint64, item string) {
+	if value, ok := r.DB.Load(ID); ok {
+		if prev, ok := value.(order.Order); ok && prev.Item != item {
+			r.unindex(ID, prev.Item)
+		}
+	}
+
+	if r.items[item] == nil {
+		r.items[item] = make(map[uint64]struct{})
+	}
+	r.items[item][ID] = struct{}{}
+}
+
+// unindex removes order ID from the item set, must be called under r.mu
+func (r *Repo) unindex(ID uint64, item string) {
+	delete(r.items[item], ID)
+	if len(r.items[item]) == 0 {
+		delete(r.items, item)
+	}
+}
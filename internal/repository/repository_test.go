@@ -0,0 +1,15 @@
+package repository_test
+
+import (
+	"caching-strategies/internal/clock"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/repotest"
+	"testing"
+	"time"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.OrderRepoI {
+		return repo.New(repo.WithClock(clock.NewFake(time.Unix(0, 0))))
+	})
+}
@@ -0,0 +1,331 @@
+// Package repotest is the conformance suite of order repositories,
+// every backend must pass it to be interchangeable with the in-memory one
+package repotest
+
+import (
+	"caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type OrderRepoI interface {
+	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+	ListByItem(ctx context.Context, item string) ([]uint64, error)
+	List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error)
+	Save(ctx context.Context, order *order.Order) (uint64, error)
+	Delete(ctx context.Context, ID uint64) error
+}
+
+// Run runs the suite, newRepo returns an empty repository for every test
+func Run(t *testing.T, newRepo func(t *testing.T) OrderRepoI) {
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, r OrderRepoI)
+	}{
+		{"SaveGet", testSaveGet},
+		{"Upsert", testUpsert},
+		{"ListByItem", testListByItem},
+		{"List", testList},
+		{"LargeIDs", testLargeIDs},
+		{"ItemTooLarge", testItemTooLarge},
+		{"Delete", testDelete},
+		{"Cancelled", testCancelled},
+		{"ConcurrentSave", testConcurrentSave},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newRepo(t))
+		})
+	}
+}
+
+func save(t *testing.T, r OrderRepoI, ord order.Order) order.Order {
+	t.Helper()
+
+	ID, err := r.Save(context.Background(), &ord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ID != ord.ID {
+		t.Fatalf("expected saved ID %d, got %d", ord.ID, ID)
+	}
+	return ord
+}
+
+func get(t *testing.T, r OrderRepoI, IDs ...uint64) map[uint64]order.Order {
+	t.Helper()
+
+	ordersMap, err := r.Get(context.Background(), IDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ordersMap
+}
+
+// equal compares stored fields, ExpiredAt is cache metadata
+func equal(a, b order.Order) bool {
+	return a.ID == b.ID && a.CustomerID == b.CustomerID && a.Item == b.Item && a.Version == b.Version
+}
+
+func testSaveGet(t *testing.T, r OrderRepoI) {
+	first := save(t, r, order.Order{ID: 1, CustomerID: 10, Item: "book"})
+	second := save(t, r, order.Order{ID: 2, CustomerID: 20, Item: ""})
+	if first.Version == 0 || second.Version <= first.Version {
+		t.Fatalf("expected growing versions, got %d and %d", first.Version, second.Version)
+	}
+
+	// missing IDs are skipped like in IN query
+	ordersMap := get(t, r, 1, 2, 3)
+	if len(ordersMap) != 2 {
+		t.Fatalf("expected 2 orders, got %v", ordersMap)
+	}
+	if !equal(ordersMap[1], first) || !equal(ordersMap[2], second) {
+		t.Fatalf("expected saved orders, got %v", ordersMap)
+	}
+	if ordersMap := get(t, r); len(ordersMap) != 0 {
+		t.Fatalf("expected no orders, got %v", ordersMap)
+	}
+}
+
+func testUpsert(t *testing.T, r OrderRepoI) {
+	ctx := context.Background()
+	first := save(t, r, order.Order{ID: 1, CustomerID: 10, Item: "book"})
+	updated := save(t, r, order.Order{ID: 1, CustomerID: 11, Item: "pen"})
+	if updated.Version <= first.Version {
+		t.Fatalf("expected version to grow on update, got %d after %d", updated.Version, first.Version)
+	}
+	if ord := get(t, r, 1)[1]; !equal(ord, updated) {
+		t.Fatalf("expected updated order, got %+v", ord)
+	}
+
+	// the order is moved to the new item
+	if IDs, err := r.ListByItem(ctx, "book"); err != nil || len(IDs) != 0 {
+		t.Fatalf("expected no orders of the old item, got %v, %v", IDs, err)
+	}
+	if IDs, err := r.ListByItem(ctx, "pen"); err != nil || !slices.Equal(IDs, []uint64{1}) {
+		t.Fatalf("expected order of the new item, got %v, %v", IDs, err)
+	}
+}
+
+func testListByItem(t *testing.T, r OrderRepoI) {
+	for _, ID := range []uint64{5, 3, 9, 1} {
+		save(t, r, order.Order{ID: ID, Item: "book"})
+	}
+	save(t, r, order.Order{ID: 4, Item: "pen"})
+
+	IDs, err := r.ListByItem(context.Background(), "book")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(IDs, []uint64{1, 3, 5, 9}) {
+		t.Fatalf("expected sorted IDs, got %v", IDs)
+	}
+	if IDs, err := r.ListByItem(context.Background(), "cup"); err != nil || len(IDs) != 0 {
+		t.Fatalf("expected no orders, got %v, %v", IDs, err)
+	}
+}
+
+func testList(t *testing.T, r OrderRepoI) {
+	ctx := context.Background()
+	for _, ID := range []uint64{7, 2, 5, 3, 11} {
+		save(t, r, order.Order{ID: ID})
+	}
+
+	var pages [][]uint64
+	cursor := uint64(0)
+	for {
+		IDs, next, err := r.List(ctx, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, IDs)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if !slices.EqualFunc(pages, [][]uint64{{2, 3}, {5, 7}, {11}}, slices.Equal) {
+		t.Fatalf("expected sorted pages, got %v", pages)
+	}
+
+	if IDs, next, err := r.List(ctx, 6, 10); err != nil || next != 0 || !slices.Equal(IDs, []uint64{7, 11}) {
+		t.Fatalf("expected IDs from cursor, got %v, %d, %v", IDs, next, err)
+	}
+	if IDs, next, err := r.List(ctx, 12, 10); err != nil || next != 0 || len(IDs) != 0 {
+		t.Fatalf("expected empty page, got %v, %d, %v", IDs, next, err)
+	}
+	if _, _, err := r.List(ctx, 0, 0); err == nil {
+		t.Fatal("expected invalid limit to be rejected")
+	}
+}
+
+// testLargeIDs checks IDs above math.MaxInt64, they are sorted after the smaller ones
+func testLargeIDs(t *testing.T, r OrderRepoI) {
+	ctx := context.Background()
+	large := uint64(1<<63 + 5)
+	for _, ID := range []uint64{math.MaxUint64, large, math.MaxInt64, 0} {
+		save(t, r, order.Order{ID: ID, CustomerID: math.MaxUint64, Item: "book"})
+	}
+
+	ordersMap := get(t, r, math.MaxUint64, large, math.MaxInt64, 0)
+	for _, ID := range []uint64{math.MaxUint64, large, math.MaxInt64, 0} {
+		if ord, ok := ordersMap[ID]; !ok || ord.ID != ID || ord.CustomerID != math.MaxUint64 {
+			t.Fatalf("expected order %d, got %v", ID, ordersMap)
+		}
+	}
+
+	var pages [][]uint64
+	cursor := uint64(0)
+	for {
+		IDs, next, err := r.List(ctx, cursor, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, IDs)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if !slices.EqualFunc(pages, [][]uint64{{0, math.MaxInt64, large}, {math.MaxUint64}}, slices.Equal) {
+		t.Fatalf("expected sorted pages, got %v", pages)
+	}
+	if IDs, next, err := r.List(ctx, 1<<63, 10); err != nil || next != 0 || !slices.Equal(IDs, []uint64{large, math.MaxUint64}) {
+		t.Fatalf("expected IDs from cursor, got %v, %d, %v", IDs, next, err)
+	}
+	if IDs, err := r.ListByItem(ctx, "book"); err != nil || !slices.Equal(IDs, []uint64{0, math.MaxInt64, large, math.MaxUint64}) {
+		t.Fatalf("expected sorted IDs, got %v, %v", IDs, err)
+	}
+
+	if err := r.Delete(ctx, math.MaxUint64); err != nil {
+		t.Fatal(err)
+	}
+	if ordersMap := get(t, r, math.MaxUint64); len(ordersMap) != 0 {
+		t.Fatalf("expected deleted order to be missing, got %v", ordersMap)
+	}
+}
+
+func testItemTooLarge(t *testing.T, r OrderRepoI) {
+	ctx := context.Background()
+	kept := save(t, r, order.Order{ID: 1, Item: "book"})
+	save(t, r, order.Order{ID: 2, Item: strings.Repeat("x", repository.MaxItemSize)})
+
+	large := strings.Repeat("x", 2<<20)
+	if _, err := r.Save(ctx, &order.Order{ID: 1, Item: large}); !errors.Is(err, repository.ErrItemTooLarge) {
+		t.Fatalf("expected ErrItemTooLarge, got %v", err)
+	}
+	if _, err := r.Save(ctx, &order.Order{ID: 3, Item: large}); !errors.Is(err, repository.ErrItemTooLarge) {
+		t.Fatalf("expected ErrItemTooLarge, got %v", err)
+	}
+
+	// rejected saves aren't applied, other orders are intact
+	ordersMap := get(t, r, 1, 2, 3)
+	if len(ordersMap) != 2 || !equal(ordersMap[1], kept) || len(ordersMap[2].Item) != repository.MaxItemSize {
+		t.Fatalf("expected only the saved orders, got %d orders", len(ordersMap))
+	}
+	if IDs, _, err := r.List(ctx, 0, 10); err != nil || !slices.Equal(IDs, []uint64{1, 2}) {
+		t.Fatalf("expected rejected order not to be listed, got %v, %v", IDs, err)
+	}
+}
+
+func testDelete(t *testing.T, r OrderRepoI) {
+	ctx := context.Background()
+	deleted := save(t, r, order.Order{ID: 1, Item: "book"})
+	save(t, r, order.Order{ID: 2, Item: "book"})
+
+	if err := r.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(ctx, 1); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if ordersMap := get(t, r, 1, 2); len(ordersMap) != 1 {
+		t.Fatalf("expected deleted order to be missing, got %v", ordersMap)
+	}
+	if IDs, err := r.ListByItem(ctx, "book"); err != nil || !slices.Equal(IDs, []uint64{2}) {
+		t.Fatalf("expected deleted order to be unindexed, got %v, %v", IDs, err)
+	}
+	if IDs, _, err := r.List(ctx, 0, 10); err != nil || !slices.Equal(IDs, []uint64{2}) {
+		t.Fatalf("expected deleted order not to be listed, got %v, %v", IDs, err)
+	}
+
+	// re-created order never repeats its old version
+	recreated := save(t, r, order.Order{ID: 1, Item: "book"})
+	if recreated.Version <= deleted.Version {
+		t.Fatalf("expected version of re-created order to grow, got %d after %d", recreated.Version, deleted.Version)
+	}
+}
+
+func testCancelled(t *testing.T, r OrderRepoI) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	kept := save(t, r, order.Order{ID: 1, Item: "book"})
+
+	if _, err := r.Save(ctx, &order.Order{ID: 1, Item: "pen"}); err == nil {
+		t.Fatal("expected cancelled save to fail")
+	}
+	if _, err := r.Save(ctx, &order.Order{ID: 2}); err == nil {
+		t.Fatal("expected cancelled save to fail")
+	}
+	if err := r.Delete(ctx, 1); err == nil {
+		t.Fatal("expected cancelled delete to fail")
+	}
+	if _, err := r.Get(ctx, []uint64{1}); err == nil {
+		t.Fatal("expected cancelled get to fail")
+	}
+
+	// cancelled writes aren't applied
+	ordersMap := get(t, r, 1, 2)
+	if len(ordersMap) != 1 || !equal(ordersMap[1], kept) {
+		t.Fatalf("expected only the kept order, got %v", ordersMap)
+	}
+}
+
+func testConcurrentSave(t *testing.T, r OrderRepoI) {
+	const (
+		writers = 8
+		saves   = 25
+	)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		versions = make(map[uint64]struct{})
+	)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < saves; i++ {
+				ord := order.Order{ID: uint64(i % 5), CustomerID: uint64(w)}
+				if _, err := r.Save(context.Background(), &ord); err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				versions[ord.Version] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(versions) != writers*saves {
+		t.Fatalf("expected %d unique versions, got %d", writers*saves, len(versions))
+	}
+	// the latest version of every order wins
+	latest := slices.Max(slices.Collect(maps.Keys(versions)))
+	found := false
+	for _, ord := range get(t, r, 0, 1, 2, 3, 4) {
+		found = found || ord.Version == latest
+	}
+	if !found {
+		t.Fatalf("expected the latest version %d to be stored", latest)
+	}
+}
@@ -0,0 +1,119 @@
+package sqliterepo
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrations are applied in order of their number: 0001_orders.sql, 0002_orders_item.sql, ...
+// applied migrations must never be edited, schema is changed by a new one
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+func loadMigrations() ([]migration, error) {
+	names, err := fs.Glob(migrationsFS, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(names))
+	for _, name := range names {
+		base := path.Base(name)
+		number, _, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a positive number", base)
+		}
+		query, err := fs.ReadFile(migrationsFS, name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: base, query: string(query)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("migrations %s and %s have the same number", migrations[i-1].name, migrations[i].name)
+		}
+	}
+	return migrations, nil
+}
+
+// migrate applies migrations newer than schema version of db, every migration in its own transaction
+func migrate(ctx context.Context, db *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT    NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if latest := migrations[len(migrations)-1].version; current > latest {
+		return fmt.Errorf("schema version %d is newer than the latest known migration %d", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := apply(ctx, db, m); err != nil {
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+		log.Info().Str("migration", m.name).Msg("repository schema migrated")
+	}
+	return nil
+}
+
+func apply(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, m.query); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now().Unix(),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
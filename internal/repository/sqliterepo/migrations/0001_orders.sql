@@ -0,0 +1,15 @@
+CREATE TABLE orders (
+    id          INTEGER PRIMARY KEY,
+    customer_id INTEGER NOT NULL,
+    item        TEXT    NOT NULL,
+    version     INTEGER NOT NULL
+);
+
+-- last assigned order version, versions are unique across orders,
+-- so re-created order never repeats its old version
+CREATE TABLE order_seq (
+    id      INTEGER PRIMARY KEY CHECK (id = 1),
+    version INTEGER NOT NULL
+);
+
+INSERT INTO order_seq (id, version) VALUES (1, 0);
@@ -0,0 +1,2 @@
+-- ListByItem reads IDs of an item from index only
+CREATE INDEX orders_item_id ON orders (item, id);
@@ -0,0 +1,249 @@
+package sqliterepo
+
+import (
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
+	"context"
+	"database/sql"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"net/url"
+	"strings"
+)
+
+// maxBatch IDs are bound in a single IN query, bigger Get is split into several queries
+const maxBatch = 500
+
+const upsertQuery = `INSERT INTO orders (id, customer_id, item, version) VALUES (?, ?, ?, ?)
+	ON CONFLICT (id) DO UPDATE SET
+		customer_id = excluded.customer_id,
+		item = excluded.item,
+		version = excluded.version`
+
+// Repo is a repository backed by SQLite database file through cgo-free driver,
+// schema is migrated on Open. IDs are stored as signed INTEGER with flipped sign bit,
+// so IDs above math.MaxInt64 are listed in the same order as in the other repositories
+type Repo struct {
+	db *sql.DB
+}
+
+// key maps order ID to stored INTEGER keeping its order: 0 -> math.MinInt64, math.MaxUint64 -> math.MaxInt64
+func key(ID uint64) int64 {
+	return int64(ID ^ 1<<63)
+}
+
+// id maps stored INTEGER back to order ID
+func id(key int64) uint64 {
+	return uint64(key) ^ 1<<63
+}
+
+// Open opens database at path or creates it and migrates its schema to the latest version
+func Open(ctx context.Context, path string) (*Repo, error) {
+	// WAL lets reads go on during a write, writes wait for each other up to busy_timeout,
+	// immediate transactions take write lock on begin, so concurrent saves don't fail on lock upgrade
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "synchronous(NORMAL)")
+	query.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("open repository database: %w", err)
+	}
+	if err := migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate repository database: %w", err)
+	}
+	return &Repo{db: db}, nil
+}
+
+// SchemaVersion returns number of the latest applied migration
+func (r *Repo) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, r.db)
+}
+
+// Get returns orders by IDs in IN queries of up to maxBatch IDs, missing IDs are skipped
+func (r *Repo) Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error) {
+	ctx, span := tracing.Start(ctx, "repository.Get")
+	defer span.End()
+	span.SetAttribute("ids", len(IDs))
+
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	ordersMap := make(map[uint64]order.Order, len(IDs))
+	for start := 0; start < len(IDs); start += maxBatch {
+		if err := r.get(ctx, IDs[start:min(start+maxBatch, len(IDs))], ordersMap); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	return ordersMap, nil
+}
+
+func (r *Repo) get(ctx context.Context, IDs []uint64, ordersMap map[uint64]order.Order) error {
+	args := make([]any, len(IDs))
+	for i, ID := range IDs {
+		args[i] = key(ID)
+	}
+	placeholders := strings.Repeat("?, ", len(IDs)-1) + "?"
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, customer_id, item, version FROM orders WHERE id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stored, customerID, version int64
+		var item string
+		if err := rows.Scan(&stored, &customerID, &item, &version); err != nil {
+			return err
+		}
+		ordersMap[id(stored)] = order.Order{
+			ID:         id(stored),
+			CustomerID: uint64(customerID),
+			Item:       item,
+			Version:    uint64(version),
+		}
+	}
+	return rows.Err()
+}
+
+// ListByItem returns sorted IDs of orders with given item
+func (r *Repo) ListByItem(ctx context.Context, item string) ([]uint64, error) {
+	ctx, span := tracing.Start(ctx, "repository.ListByItem")
+	defer span.End()
+	span.SetAttribute("item", item)
+
+	IDs, err := r.listIDs(ctx, `SELECT id FROM orders WHERE item = ? ORDER BY id`, item)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return IDs, nil
+}
+
+// List returns up to limit sorted IDs starting from cursor and the cursor of the next page,
+// next cursor is 0 if there are no more orders
+func (r *Repo) List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error) {
+	ctx, span := tracing.Start(ctx, "repository.List")
+	defer span.End()
+	span.SetAttribute("cursor", cursor)
+	span.SetAttribute("limit", limit)
+
+	if limit <= 0 {
+		err := fmt.Errorf("invalid limit: %d", limit)
+		span.RecordError(err)
+		return nil, 0, err
+	}
+
+	// one more ID tells if there is the next page
+	IDs, err := r.listIDs(ctx, `SELECT id FROM orders WHERE id >= ? ORDER BY id LIMIT ?`, key(cursor), limit+1)
+	if err != nil {
+		span.RecordError(err)
+		return nil, 0, err
+	}
+
+	if len(IDs) <= limit {
+		return IDs, 0, nil
+	}
+
+	IDs = IDs[:limit]
+	return IDs, IDs[limit-1] + 1, nil
+}
+
+func (r *Repo) listIDs(ctx context.Context, query string, args ...any) ([]uint64, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	IDs := make([]uint64, 0)
+	for rows.Next() {
+		var stored int64
+		if err := rows.Scan(&stored); err != nil {
+			return nil, err
+		}
+		IDs = append(IDs, id(stored))
+	}
+	return IDs, rows.Err()
+}
+
+// Save upserts order and assigns it a new version in one transaction, cancelled save isn't applied
+func (r *Repo) Save(ctx context.Context, order *order.Order) (uint64, error) {
+	ctx, span := tracing.Start(ctx, "repository.Save")
+	defer span.End()
+
+	if len(order.Item) > repo.MaxItemSize {
+		err := fmt.Errorf("%w: %d bytes", repo.ErrItemTooLarge, len(order.Item))
+		span.RecordError(err)
+		return 0, err
+	}
+
+	version, err := r.save(ctx, order)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	order.Version = version
+	return order.ID, nil
+}
+
+func (r *Repo) save(ctx context.Context, order *order.Order) (uint64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var version int64
+	if err := tx.QueryRowContext(ctx,
+		`UPDATE order_seq SET version = version + 1 WHERE id = 1 RETURNING version`,
+	).Scan(&version); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, upsertQuery,
+		key(order.ID), int64(order.CustomerID), order.Item, version,
+	); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return uint64(version), nil
+}
+
+// Delete removes order, returns ErrNotFound if it doesn't exist
+func (r *Repo) Delete(ctx context.Context, ID uint64) error {
+	ctx, span := tracing.Start(ctx, "repository.Delete")
+	defer span.End()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM orders WHERE id = ?`, key(ID))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if deleted == 0 {
+		span.RecordError(repo.ErrNotFound)
+		return repo.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) Close() error {
+	return r.db.Close()
+}
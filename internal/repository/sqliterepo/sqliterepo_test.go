@@ -0,0 +1,94 @@
+package sqliterepo
+
+import (
+	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/repository/repotest"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func open(t *testing.T, path string) *Repo {
+	t.Helper()
+
+	r, err := Open(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.OrderRepoI {
+		return open(t, filepath.Join(t.TempDir(), "orders.db"))
+	})
+}
+
+func TestGetBatches(t *testing.T) {
+	ctx := context.Background()
+	r := open(t, filepath.Join(t.TempDir(), "orders.db"))
+
+	IDs := make([]uint64, 0, 2*maxBatch+1)
+	for i := 0; i < 2*maxBatch+1; i++ {
+		IDs = append(IDs, uint64(i))
+		if i%2 == 1 {
+			continue
+		}
+		if _, err := r.Save(ctx, &order.Order{ID: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ordersMap, err := r.Get(ctx, IDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ordersMap) != maxBatch+1 {
+		t.Fatalf("expected %d orders of 3 queries, got %d", maxBatch+1, len(ordersMap))
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrations[len(migrations)-1].version
+
+	r := open(t, path)
+	if version, err := r.SchemaVersion(ctx); err != nil || version != latest {
+		t.Fatalf("expected schema version %d, got %d, %v", latest, version, err)
+	}
+	saved := order.Order{ID: 1, Item: "book"}
+	if _, err := r.Save(ctx, &saved); err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Close()
+
+	// migrated database is opened as is, orders and versions are kept
+	r = open(t, path)
+	if version, err := r.SchemaVersion(ctx); err != nil || version != latest {
+		t.Fatalf("expected schema version %d after reopen, got %d, %v", latest, version, err)
+	}
+	if ordersMap, err := r.Get(ctx, []uint64{1}); err != nil || ordersMap[1] != saved {
+		t.Fatalf("expected saved order after reopen, got %v, %v", ordersMap, err)
+	}
+	next := order.Order{ID: 2}
+	if _, err := r.Save(ctx, &next); err != nil || next.Version <= saved.Version {
+		t.Fatalf("expected version to grow after reopen, got %d after %d, %v", next.Version, saved.Version, err)
+	}
+
+	// database of a newer build isn't touched
+	if _, err := r.db.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'future.sql', 0)`, latest+1,
+	); err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Close()
+	if _, err := Open(ctx, path); err == nil {
+		t.Fatal("expected database of newer schema to be rejected")
+	}
+}
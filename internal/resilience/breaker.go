@@ -0,0 +1,223 @@
+package resilience
+
+import (
+	"caching-strategies/internal/clock"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	// StateClosed lets every call through and counts consecutive failures
+	StateClosed State = iota
+	// StateOpen rejects every call until open timeout passes
+	StateOpen
+	// StateHalfOpen lets a few probe calls through, they decide whether to close or open again
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return fmt.Sprintf("state(%d)", int(s))
+	}
+}
+
+// ErrOpen is returned instead of calling repository while breaker is open
+var ErrOpen = errors.New("circuit breaker is open")
+
+type BreakerConfig struct {
+	// FailureThreshold consecutive failures open the breaker
+	FailureThreshold int
+	// OpenTimeout is how long open breaker rejects calls before probing
+	OpenTimeout time.Duration
+	// HalfOpenProbes concurrent probes are let through in half-open state, all of them must succeed to close
+	HalfOpenProbes int
+}
+
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      time.Second,
+		HalfOpenProbes:   1,
+	}
+}
+
+func (c BreakerConfig) Validate() error {
+	if c.FailureThreshold <= 0 {
+		return fmt.Errorf("breaker failure threshold must be positive, got %d", c.FailureThreshold)
+	}
+	if c.OpenTimeout <= 0 {
+		return fmt.Errorf("breaker open timeout must be positive, got %s", c.OpenTimeout)
+	}
+	if c.HalfOpenProbes <= 0 {
+		return fmt.Errorf("breaker half-open probes must be positive, got %d", c.HalfOpenProbes)
+	}
+	return nil
+}
+
+// Event is a state transition of breaker
+type Event struct {
+	From, To State
+	At       time.Time
+}
+
+// Breaker stops calling sick repository, so misses fail fast instead of piling on it.
+// Methods of nil breaker let every call through.
+type Breaker struct {
+	cfg   BreakerConfig
+	clock clock.Clock
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	// probes in flight and succeeded ones in half-open state
+	probes    int
+	succeeded int
+	// generation changes on every transition, so results of calls allowed in a previous state are ignored
+	generation uint64
+
+	listeners []func(Event)
+}
+
+func NewBreaker(cfg BreakerConfig, c clock.Clock) *Breaker {
+	return &Breaker{cfg: cfg, clock: c}
+}
+
+// OnStateChange subscribes fn to transitions, fn is called without lock after every transition
+func (b *Breaker) OnStateChange(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.listeners = append(b.listeners, fn)
+}
+
+func (b *Breaker) State() State {
+	if b == nil {
+		return StateClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.expireLocked()
+	return b.state
+}
+
+// Allow reserves a call or returns ErrOpen, done must be called with the call result.
+// Cancellation of the caller is neither success nor failure of repository.
+func (b *Breaker) Allow() (done func(err error), err error) {
+	if b == nil {
+		return func(error) {}, nil
+	}
+
+	b.mu.Lock()
+	events := b.expireLocked()
+	switch b.state {
+	case StateOpen:
+		b.mu.Unlock()
+		b.notify(events)
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.probes+b.succeeded >= b.cfg.HalfOpenProbes {
+			b.mu.Unlock()
+			b.notify(events)
+			return nil, ErrOpen
+		}
+		b.probes++
+	}
+	generation := b.generation
+	b.mu.Unlock()
+	b.notify(events)
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { b.done(generation, err) })
+	}, nil
+}
+
+func (b *Breaker) done(generation uint64, err error) {
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+
+	var events []Event
+	canceled := errors.Is(err, context.Canceled)
+	switch b.state {
+	case StateClosed:
+		if err == nil {
+			b.failures = 0
+		} else if !canceled {
+			b.failures++
+			if b.failures >= b.cfg.FailureThreshold {
+				events = b.toLocked(StateOpen)
+			}
+		}
+	case StateHalfOpen:
+		b.probes--
+		switch {
+		case err == nil:
+			b.succeeded++
+			if b.succeeded >= b.cfg.HalfOpenProbes {
+				events = b.toLocked(StateClosed)
+			}
+		case !canceled:
+			events = b.toLocked(StateOpen)
+		}
+	}
+	b.mu.Unlock()
+	b.notify(events)
+}
+
+// expireLocked moves open breaker to half-open after timeout, must be called under b.mu
+func (b *Breaker) expireLocked() []Event {
+	if b.state != StateOpen || b.clock.Since(b.openedAt) < b.cfg.OpenTimeout {
+		return nil
+	}
+	return b.toLocked(StateHalfOpen)
+}
+
+// toLocked must be called under b.mu
+func (b *Breaker) toLocked(state State) []Event {
+	event := Event{From: b.state, To: state, At: b.clock.Now()}
+
+	b.state = state
+	b.generation++
+	b.failures = 0
+	b.probes = 0
+	b.succeeded = 0
+	if state == StateOpen {
+		b.openedAt = event.At
+	}
+
+	return []Event{event}
+}
+
+func (b *Breaker) notify(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	listeners := b.listeners
+	b.mu.Unlock()
+
+	for _, e := range events {
+		for _, fn := range listeners {
+			fn(e)
+		}
+	}
+}
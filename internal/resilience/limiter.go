@@ -0,0 +1,116 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrOverloaded is returned when all slots are busy and the queue is full, the call is shed
+var ErrOverloaded = errors.New("too many concurrent repository calls")
+
+// Limiter bounds concurrent repository calls, callers above the limit wait in a bounded FIFO queue
+// and the rest are shed at once. Methods of nil limiter don't limit anything.
+type Limiter struct {
+	maxConcurrent int
+	maxQueue      int
+
+	mu       sync.Mutex
+	inFlight int
+	// waiters get a slot of the finished call directly, so callers coming later can't take it
+	waiters []chan struct{}
+}
+
+// NewLimiter allows maxConcurrent calls and maxQueue waiting callers, 0 queue sheds every call above the limit
+func NewLimiter(maxConcurrent, maxQueue int) (*Limiter, error) {
+	if maxConcurrent <= 0 {
+		return nil, fmt.Errorf("limiter concurrency must be positive, got %d", maxConcurrent)
+	}
+	if maxQueue < 0 {
+		return nil, fmt.Errorf("limiter queue must not be negative, got %d", maxQueue)
+	}
+
+	return &Limiter{
+		maxConcurrent: maxConcurrent,
+		maxQueue:      maxQueue,
+	}, nil
+}
+
+// Acquire takes a slot, waiting for it until ctx is done if there is room in the queue,
+// release must be called when the call is finished
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	if l.inFlight < l.maxConcurrent {
+		l.inFlight++
+		l.mu.Unlock()
+		return l.release, nil
+	}
+	if len(l.waiters) >= l.maxQueue {
+		l.mu.Unlock()
+		return nil, ErrOverloaded
+	}
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return l.release, nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	for i, w := range l.waiters {
+		if w == ready {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	l.mu.Unlock()
+
+	// slot was handed over together with cancellation, pass it on
+	l.release()
+	return nil, ctx.Err()
+}
+
+// InFlight returns the number of calls holding a slot
+func (l *Limiter) InFlight() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight
+}
+
+// Waiting returns the number of queued callers
+func (l *Limiter) Waiting() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.waiters)
+}
+
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.waiters) == 0 {
+		l.inFlight--
+		return
+	}
+	close(l.waiters[0])
+	l.waiters = l.waiters[1:]
+}
@@ -0,0 +1,34 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+)
+
+// Do calls fn if limiter has a slot and breaker is not open, fn result is reported to breaker.
+// Shed calls never reach breaker, so overload of the service itself doesn't open it.
+func Do(ctx context.Context, b *Breaker, l *Limiter, fn func(ctx context.Context) error) error {
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn(ctx)
+	if ctx.Err() != nil {
+		// caller gave up, it says nothing about repository health
+		done(context.Canceled)
+		return err
+	}
+	done(err)
+	return err
+}
+
+// Rejected reports whether the call was not made because of open breaker or overload
+func Rejected(err error) bool {
+	return errors.Is(err, ErrOpen) || errors.Is(err, ErrOverloaded)
+}
@@ -0,0 +1,188 @@
+package resilience
+
+import (
+	"caching-strategies/internal/clock"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errDB = errors.New("db is down")
+
+func call(b *Breaker, err error) error {
+	done, allowErr := b.Allow()
+	if allowErr != nil {
+		return allowErr
+	}
+	done(err)
+	return err
+}
+
+// closed -> open -> half-open -> open -> half-open -> closed
+func TestBreaker(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := NewBreaker(BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Second, HalfOpenProbes: 2}, fake)
+
+	var transitions []string
+	b.OnStateChange(func(e Event) {
+		transitions = append(transitions, e.From.String()+"->"+e.To.String())
+	})
+
+	// success resets consecutive failures
+	_ = call(b, errDB)
+	_ = call(b, errDB)
+	_ = call(b, nil)
+	_ = call(b, errDB)
+	_ = call(b, context.Canceled)
+	_ = call(b, errDB)
+	if b.State() != StateClosed {
+		t.Fatalf("expected closed breaker, got %s", b.State())
+	}
+	_ = call(b, errDB)
+	if err := call(b, nil); !errors.Is(err, ErrOpen) || b.State() != StateOpen {
+		t.Fatalf("expected open breaker to reject, got %v in %s", err, b.State())
+	}
+
+	// a failed probe opens breaker again
+	fake.Advance(time.Second)
+	if err := call(b, errDB); !errors.Is(err, errDB) || b.State() != StateOpen {
+		t.Fatalf("expected failed probe to open breaker, got %v in %s", err, b.State())
+	}
+
+	// only 2 concurrent probes are let through, both must succeed
+	fake.Advance(time.Second)
+	first, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected third probe to be rejected, got %v", err)
+	}
+	first(nil)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("expected half-open breaker after the first probe, got %s", b.State())
+	}
+	second(nil)
+	if b.State() != StateClosed {
+		t.Fatalf("expected closed breaker, got %s", b.State())
+	}
+
+	expected := []string{
+		"closed->open",
+		"open->half_open", "half_open->open",
+		"open->half_open", "half_open->closed",
+	}
+	if !reflect.DeepEqual(transitions, expected) {
+		t.Fatalf("expected transitions %v, got %v", expected, transitions)
+	}
+}
+
+// results of calls allowed before the transition don't count in the new state
+func TestBreakerStaleResults(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second, HalfOpenProbes: 1}, fake)
+
+	slow, _ := b.Allow()
+	_ = call(b, errDB)
+	fake.Advance(time.Second)
+	if err := call(b, nil); err != nil || b.State() != StateClosed {
+		t.Fatalf("expected probe to close breaker, got %v in %s", err, b.State())
+	}
+
+	slow(errDB)
+	if b.State() != StateClosed {
+		t.Fatalf("expected late failure to be ignored, got %s", b.State())
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	l, err := NewLimiter(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := l.Acquire(ctx)
+	second, _ := l.Acquire(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	acquired := make(chan func())
+	go func() {
+		defer wg.Done()
+		release, err := l.Acquire(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		acquired <- release
+	}()
+	for l.Waiting() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// queue is full
+	if _, err := l.Acquire(ctx); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected call to be shed, got %v", err)
+	}
+
+	// waiter gets the slot before anyone else
+	first()
+	third := <-acquired
+	if l.InFlight() != 2 || l.Waiting() != 0 {
+		t.Fatalf("expected slot to be handed to waiter, got %d in flight and %d waiting", l.InFlight(), l.Waiting())
+	}
+
+	// waiting ends with ctx
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+
+	second()
+	third()
+	wg.Wait()
+	if l.InFlight() != 0 || l.Waiting() != 0 {
+		t.Fatalf("expected free limiter, got %d in flight and %d waiting", l.InFlight(), l.Waiting())
+	}
+}
+
+// open breaker and shed calls never reach fn, shedding doesn't open breaker
+func TestDo(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second, HalfOpenProbes: 1}, fake)
+	l, _ := NewLimiter(1, 0)
+
+	calls := 0
+	fn := func(context.Context) error {
+		calls++
+		return errDB
+	}
+
+	release, _ := l.Acquire(ctx)
+	if err := Do(ctx, b, l, fn); !errors.Is(err, ErrOverloaded) || !Rejected(err) || b.State() != StateClosed {
+		t.Fatalf("expected shed call, got %v in %s", err, b.State())
+	}
+	release()
+
+	if err := Do(ctx, b, l, fn); !errors.Is(err, errDB) || Rejected(err) {
+		t.Fatalf("expected db error, got %v", err)
+	}
+	if err := Do(ctx, b, l, fn); !errors.Is(err, ErrOpen) || calls != 1 {
+		t.Fatalf("expected open breaker after %d calls, got %v", calls, err)
+	}
+
+	// nil breaker and limiter let everything through
+	if err := Do(ctx, nil, nil, fn); !errors.Is(err, errDB) || calls != 2 {
+		t.Fatalf("expected call without guards, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"caching-strategies/internal/repository/entity/order"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TTLProvider is implemented by usecases with cache,
+// remaining TTL of cached orders is used as max-age for downstream caches
+type TTLProvider interface {
+	RemainingTTL(ID uint64) (time.Duration, bool)
+}
+
+// etag derives strong ETag from order versions: "id-version" for single order,
+// hash of sorted id-version pairs for batch
+func etag(orders []order.Order) string {
+	if len(orders) == 1 {
+		return fmt.Sprintf(`"%d-%d"`, orders[0].ID, orders[0].Version)
+	}
+
+	sorted := make([]order.Order, len(orders))
+	copy(sorted, orders)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	h := fnv.New64a()
+	for _, ord := range sorted {
+		_, _ = fmt.Fprintf(h, "%d-%d,", ord.ID, ord.Version)
+	}
+	return fmt.Sprintf(`"%x"`, h.Sum64())
+}
+
+// cacheControl allows caching for the smallest remaining TTL of the orders,
+// orders not cached by strategy must be revalidated
+func (s *Server) cacheControl(orders []order.Order) string {
+	provider, ok := s.usecase.(TTLProvider)
+	if !ok || len(orders) == 0 {
+		return "no-cache"
+	}
+
+	var maxAge time.Duration
+	for i, ord := range orders {
+		ttl, ok := provider.RemainingTTL(ord.ID)
+		if !ok || ttl <= 0 {
+			return "no-cache"
+		}
+		if i == 0 || ttl < maxAge {
+			maxAge = ttl
+		}
+	}
+
+	return fmt.Sprintf("max-age=%d", int(maxAge/time.Second))
+}
+
+// notModified writes caching headers and returns true if client already has the orders
+func (s *Server) notModified(w http.ResponseWriter, r *http.Request, orders []order.Order) bool {
+	tag := etag(orders)
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", s.cacheControl(orders))
+
+	if !matchETag(r.Header.Get("If-None-Match"), tag) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// matchETag uses weak comparison as required for If-None-Match
+func matchETag(header, tag string) bool {
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	ordersPath  = "/orders"
+	metricsPath = "/metrics"
+	maxBodySize = 1 << 20
+)
+
+type UsecaseI interface {
+	Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
+	Save(ctx context.Context, order *order.Order) error
+	Delete(ctx context.Context, ID uint64) error
+}
+
+// Server is an HTTP API over the order usecase:
+//
+//	GET    /orders?ids=1,2,3 - 200 some or all found, missing IDs are listed, 404 none found
+//	GET    /orders/{id}      - 200 found, 304 not modified, 404 not found
+//	PUT    /orders/{id}      - 200 saved order
+//	DELETE /orders/{id}      - 204 deleted, 404 not found
+//	GET    /metrics          - metrics in Prometheus text format
+type Server struct {
+	usecase UsecaseI
+	mux     *http.ServeMux
+}
+
+// GetResponse lists found orders and IDs missing in repository
+type GetResponse struct {
+	Orders  []order.Order `json:"orders"`
+	Missing []uint64      `json:"missing,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func New(usecase UsecaseI, metricsHandler http.Handler) *Server {
+	s := &Server{
+		usecase: usecase,
+		mux:     http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc(ordersPath, s.handleOrders)
+	s.mux.HandleFunc(ordersPath+"/", s.handleOrder)
+	if metricsHandler != nil {
+		s.mux.Handle(metricsPath, metricsHandler)
+	}
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	IDs, err := parseIDs(r.URL.Query().Get("ids"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	orders, err := s.usecase.Get(r.Context(), IDs)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := GetResponse{Orders: orders, Missing: missing(IDs, orders)}
+
+	status := http.StatusOK
+	switch {
+	case len(orders) == 0:
+		status = http.StatusNotFound
+	case s.notModified(w, r, orders):
+		return
+	}
+	writeJSON(w, status, resp)
+}
+
+func (s *Server) handleOrder(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, ordersPath+"/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid order id: %w", err))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.getOrder(w, r, ID)
+	case http.MethodPut:
+		s.saveOrder(w, r, ID)
+	case http.MethodDelete:
+		s.deleteOrder(w, r, ID)
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut, http.MethodDelete}, ", "))
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request, ID uint64) {
+	orders, err := s.usecase.Get(r.Context(), []uint64{ID})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(orders) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("order %d not found", ID))
+		return
+	}
+
+	if s.notModified(w, r, orders) {
+		return
+	}
+	writeJSON(w, http.StatusOK, orders[0])
+}
+
+func (s *Server) saveOrder(w http.ResponseWriter, r *http.Request, ID uint64) {
+	ord := order.Order{ID: ID}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ord); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid order: %w", err))
+		return
+	}
+	if ord.ID != ID {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("order id %d doesn't match path id %d", ord.ID, ID))
+		return
+	}
+
+	if err := s.usecase.Save(r.Context(), &ord); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ord)
+}
+
+func (s *Server) deleteOrder(w http.ResponseWriter, r *http.Request, ID uint64) {
+	err := s.usecase.Delete(r.Context(), ID)
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func parseIDs(value string) ([]uint64, error) {
+	if value == "" {
+		return nil, errors.New("ids are required")
+	}
+
+	parts := strings.Split(value, ",")
+	IDs := make([]uint64, 0, len(parts))
+	seen := make(map[uint64]struct{}, len(parts))
+	for _, part := range parts {
+		ID, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid order id %q: %w", part, err)
+		}
+		if _, ok := seen[ID]; ok {
+			continue
+		}
+		seen[ID] = struct{}{}
+		IDs = append(IDs, ID)
+	}
+	return IDs, nil
+}
+
+// missing returns requested IDs not found in orders keeping request order
+func missing(IDs []uint64, orders []order.Order) []uint64 {
+	found := make(map[uint64]struct{}, len(orders))
+	for _, ord := range orders {
+		found[ord.ID] = struct{}{}
+	}
+
+	var result []uint64
+	for _, ID := range IDs {
+		if _, ok := found[ID]; !ok {
+			result = append(result, ID)
+		}
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Err(err).Msg("response encoding error")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		log.Err(err).Msg("request error")
+	}
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
@@ -0,0 +1,220 @@
+package server_test
+
+import (
+	"caching-strategies/internal/accesstrace"
+	"caching-strategies/internal/app"
+	"caching-strategies/internal/config"
+	"caching-strategies/internal/server"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	for _, strategy := range config.Strategies {
+		strategy := strategy
+		t.Run(strategy, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Strategy = strategy
+
+			application, err := app.New(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			application.Start(context.Background())
+			defer application.Stop(context.Background())
+
+			srv := httptest.NewServer(server.New(application.Usecase, application.Metrics))
+			defer srv.Close()
+
+			for _, ID := range []string{"1", "2"} {
+				resp := do(t, http.MethodPut, srv.URL+"/orders/"+ID, `{"item":"book","customer_id":7}`)
+				expectStatus(t, resp, http.StatusOK)
+			}
+			expectStatus(t, do(t, http.MethodPut, srv.URL+"/orders/3", `{"id":4}`), http.StatusBadRequest)
+
+			resp := do(t, http.MethodGet, srv.URL+"/orders?ids=1,2", "")
+			expectStatus(t, resp, http.StatusOK)
+			var body server.GetResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Orders) != 2 || body.Orders[0].Item != "book" || body.Orders[0].CustomerID != 7 {
+				t.Fatalf("unexpected orders: %+v", body.Orders)
+			}
+
+			resp = do(t, http.MethodGet, srv.URL+"/orders?ids=1,5", "")
+			expectStatus(t, resp, http.StatusOK)
+			body = server.GetResponse{}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Missing) != 1 || body.Missing[0] != 5 {
+				t.Fatalf("expected order 5 to be missing, got %v", body.Missing)
+			}
+
+			expectStatus(t, do(t, http.MethodGet, srv.URL+"/orders?ids=5", ""), http.StatusNotFound)
+			expectStatus(t, do(t, http.MethodGet, srv.URL+"/orders?ids=x", ""), http.StatusBadRequest)
+
+			// deleted order isn't served from cache
+			expectStatus(t, do(t, http.MethodDelete, srv.URL+"/orders/1", ""), http.StatusNoContent)
+			expectStatus(t, do(t, http.MethodDelete, srv.URL+"/orders/1", ""), http.StatusNotFound)
+			expectStatus(t, do(t, http.MethodGet, srv.URL+"/orders?ids=1", ""), http.StatusNotFound)
+
+			expectStatus(t, do(t, http.MethodGet, srv.URL+"/metrics", ""), http.StatusOK)
+		})
+	}
+}
+
+// service records calls it gets to trace, the trace is complete once the service is stopped
+func TestTraceRecording(t *testing.T) {
+	cfg := config.Default()
+	cfg.Strategy = config.StrategyReadWriteThrough
+	cfg.TracePath = filepath.Join(t.TempDir(), "trace.jsonl")
+
+	application, err := app.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	application.Start(context.Background())
+
+	srv := httptest.NewServer(server.New(application.Usecase, application.Metrics))
+	defer srv.Close()
+
+	expectStatus(t, do(t, http.MethodPut, srv.URL+"/orders/1", `{"item":"book"}`), http.StatusOK)
+	resp := do(t, http.MethodGet, srv.URL+"/orders?ids=1,2", "")
+	expectStatus(t, resp, http.StatusOK)
+	if cacheControl := resp.Header.Get("Cache-Control"); cacheControl == "no-cache" {
+		t.Fatal("expected recording not to hide remaining cache TTL")
+	}
+	expectStatus(t, do(t, http.MethodDelete, srv.URL+"/orders/1", ""), http.StatusNoContent)
+
+	if _, err := application.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(cfg.TracePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	reader := accesstrace.NewReader(f)
+	var ops []string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ops = append(ops, record.Op)
+	}
+	if !slices.Equal(ops, []string{accesstrace.OpSave, accesstrace.OpGet, accesstrace.OpDelete}) {
+		t.Fatalf("expected every call in trace, got %v", ops)
+	}
+}
+
+func do(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp
+}
+
+func expectStatus(t *testing.T, resp *http.Response, status int) {
+	t.Helper()
+
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s: expected status %d, got %d", resp.Request.Method, resp.Request.URL, status, resp.StatusCode)
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		strategy string
+		// counting wraps usecase into warmup.Counter, it must keep remaining TTL visible
+		counting bool
+	}{
+		{config.StrategyWithoutCache, config.StrategyWithoutCache, false},
+		{config.StrategyReadWriteThrough, config.StrategyReadWriteThrough, false},
+		{config.StrategyReadWriteThrough + "_counting", config.StrategyReadWriteThrough, true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			strategy := tc.strategy
+			cfg := config.Default()
+			cfg.Strategy = strategy
+			if tc.counting {
+				cfg.WarmupCountsPath = filepath.Join(t.TempDir(), "counts")
+			}
+
+			application, err := app.New(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			srv := httptest.NewServer(server.New(application.Usecase, application.Metrics))
+			defer srv.Close()
+
+			expectStatus(t, do(t, http.MethodPut, srv.URL+"/orders/1", `{"item":"book"}`), http.StatusOK)
+
+			resp := do(t, http.MethodGet, srv.URL+"/orders/1", "")
+			expectStatus(t, resp, http.StatusOK)
+			etag := resp.Header.Get("ETag")
+			if etag == "" {
+				t.Fatal("expected ETag")
+			}
+
+			cacheControl := resp.Header.Get("Cache-Control")
+			if strategy == config.StrategyWithoutCache && cacheControl != "no-cache" {
+				t.Fatalf("expected no-cache without cache, got %q", cacheControl)
+			}
+			if strategy != config.StrategyWithoutCache && cacheControl != fmt.Sprintf("max-age=%d", int(cfg.CacheTTL.Seconds())-1) {
+				t.Fatalf("expected max-age of remaining cache TTL, got %q", cacheControl)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/orders/1", nil)
+			req.Header.Set("If-None-Match", `"other", `+etag)
+			resp, err = http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			expectStatus(t, resp, http.StatusNotModified)
+
+			// new version changes ETag
+			expectStatus(t, do(t, http.MethodPut, srv.URL+"/orders/1", `{"item":"pen"}`), http.StatusOK)
+			req.Header.Set("If-None-Match", etag)
+			resp, err = http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			expectStatus(t, resp, http.StatusOK)
+			if resp.Header.Get("ETag") == etag {
+				t.Fatal("expected new ETag after save")
+			}
+		})
+	}
+}
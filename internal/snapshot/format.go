@@ -0,0 +1,131 @@
+package snapshot
+
+import (
+	"bufio"
+	"caching-strategies/internal/repository/entity/order"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// Version of the format, Read rejects snapshots of other versions
+const Version = 1
+
+const (
+	magic = "CSNP"
+	// maxItemLen guards against allocating garbage length of corrupted snapshot
+	maxItemLen = 1 << 20
+)
+
+// ErrFormat is returned for corrupted or foreign files
+var ErrFormat = errors.New("invalid cache snapshot")
+
+// Entry is a cached order with its remaining TTL, 0 if it never expires
+type Entry struct {
+	Order order.Order
+	TTL   time.Duration
+}
+
+// Snapshot is cache contents at CreatedAt, entries go from the least to the most recently used
+type Snapshot struct {
+	CreatedAt time.Time
+	Entries   []Entry
+}
+
+// Write encodes snapshot:
+//
+//	magic "CSNP" | version uint16 | created at unix nanos int64 | count uint32 | entries | crc32 of all before
+//	entry: ID uint64 | customer ID uint64 | version uint64 | TTL nanos int64 | item length uint32 | item
+//
+// numbers are little endian
+func Write(w io.Writer, snap Snapshot) error {
+	buf := bufio.NewWriter(w)
+	crc := crc32.NewIEEE()
+	out := io.MultiWriter(buf, crc)
+
+	header := make([]byte, 0, len(magic)+2+8+4)
+	header = append(header, magic...)
+	header = binary.LittleEndian.AppendUint16(header, Version)
+	header = binary.LittleEndian.AppendUint64(header, uint64(snap.CreatedAt.UnixNano()))
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(snap.Entries)))
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]byte, 0, 64)
+	for _, e := range snap.Entries {
+		record = record[:0]
+		record = binary.LittleEndian.AppendUint64(record, e.Order.ID)
+		record = binary.LittleEndian.AppendUint64(record, e.Order.CustomerID)
+		record = binary.LittleEndian.AppendUint64(record, e.Order.Version)
+		record = binary.LittleEndian.AppendUint64(record, uint64(e.TTL))
+		record = binary.LittleEndian.AppendUint32(record, uint32(len(e.Order.Item)))
+		record = append(record, e.Order.Item...)
+		if _, err := out.Write(record); err != nil {
+			return err
+		}
+	}
+
+	if err := binary.Write(buf, binary.LittleEndian, crc.Sum32()); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// Read decodes snapshot written by Write, checksum mismatch and unknown version are ErrFormat
+func Read(r io.Reader) (Snapshot, error) {
+	crc := crc32.NewIEEE()
+	in := io.TeeReader(bufio.NewReader(r), crc)
+
+	header := make([]byte, len(magic)+2+8+4)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return Snapshot{}, fmt.Errorf("%w: header: %w", ErrFormat, err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return Snapshot{}, fmt.Errorf("%w: unknown magic %q", ErrFormat, header[:len(magic)])
+	}
+	if version := binary.LittleEndian.Uint16(header[4:]); version != Version {
+		return Snapshot{}, fmt.Errorf("%w: unsupported version %d, expected %d", ErrFormat, version, Version)
+	}
+	snap := Snapshot{CreatedAt: time.Unix(0, int64(binary.LittleEndian.Uint64(header[6:])))}
+	count := binary.LittleEndian.Uint32(header[14:])
+
+	snap.Entries = make([]Entry, 0, min(count, 1<<16))
+	record := make([]byte, 8+8+8+8+4)
+	for i := uint32(0); i < count; i++ {
+		if _, err := io.ReadFull(in, record); err != nil {
+			return Snapshot{}, fmt.Errorf("%w: entry %d: %w", ErrFormat, i, err)
+		}
+		itemLen := binary.LittleEndian.Uint32(record[32:])
+		if itemLen > maxItemLen {
+			return Snapshot{}, fmt.Errorf("%w: entry %d: item of %d bytes", ErrFormat, i, itemLen)
+		}
+		item := make([]byte, itemLen)
+		if _, err := io.ReadFull(in, item); err != nil {
+			return Snapshot{}, fmt.Errorf("%w: entry %d: %w", ErrFormat, i, err)
+		}
+
+		snap.Entries = append(snap.Entries, Entry{
+			Order: order.Order{
+				ID:         binary.LittleEndian.Uint64(record[0:]),
+				CustomerID: binary.LittleEndian.Uint64(record[8:]),
+				Version:    binary.LittleEndian.Uint64(record[16:]),
+				Item:       string(item),
+			},
+			TTL: time.Duration(binary.LittleEndian.Uint64(record[24:])),
+		})
+	}
+
+	sum := crc.Sum32()
+	var expected uint32
+	if err := binary.Read(in, binary.LittleEndian, &expected); err != nil {
+		return Snapshot{}, fmt.Errorf("%w: checksum: %w", ErrFormat, err)
+	}
+	if sum != expected {
+		return Snapshot{}, fmt.Errorf("%w: checksum mismatch", ErrFormat)
+	}
+	return snap, nil
+}
@@ -0,0 +1,242 @@
+package snapshot
+
+import (
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/lru"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// validateBatch IDs are checked against repository in a single Get
+	validateBatch = 100
+	// validateConcurrency batches are checked at once
+	validateConcurrency = 8
+)
+
+type CacheInterface interface {
+	Entries() []lru.Entry[uint64, *order.Order]
+	AddWithTTL(key uint64, value *order.Order, ttl time.Duration) (evicted bool)
+}
+
+type OrderRepoI interface {
+	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+}
+
+// RestoreStats tell what happened to snapshot entries on restore
+type RestoreStats struct {
+	// Restored entries are cached as they were
+	Restored int
+	// Updated entries changed in repository since snapshot, their current version is cached
+	Updated int
+	// Missing entries were deleted from repository and aren't cached
+	Missing int
+	// Expired entries outlived their TTL while service was down
+	Expired int
+}
+
+// Snapshotter saves cache contents to file periodically and restores them on start.
+// It's a lifecycle.Worker: the last snapshot is taken on stop.
+type Snapshotter struct {
+	path     string
+	cache    CacheInterface
+	interval time.Duration
+	clock    clock.Clock
+
+	// mu serializes saves of periodic snapshot and the one on stop
+	mu sync.Mutex
+}
+
+// Option configures Snapshotter
+type Option func(*Snapshotter)
+
+// WithClock replaces real clock of snapshot time and remaining TTL
+func WithClock(c clock.Clock) Option {
+	return func(s *Snapshotter) {
+		s.clock = c
+	}
+}
+
+func New(path string, cache CacheInterface, interval time.Duration, opts ...Option) *Snapshotter {
+	s := &Snapshotter{
+		path:     path,
+		cache:    cache,
+		interval: interval,
+		clock:    clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Save writes cache contents to file, file is replaced atomically so a crash never leaves half of a snapshot
+func (s *Snapshotter) Save() (count int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.cache.Entries()
+	snap := Snapshot{CreatedAt: s.clock.Now(), Entries: make([]Entry, 0, len(entries))}
+	for _, e := range entries {
+		if e.Value == nil {
+			continue
+		}
+		snap.Entries = append(snap.Entries, Entry{Order: *e.Value, TTL: e.TTL})
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return 0, fmt.Errorf("snapshot: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := Write(tmp, snap); err != nil {
+		return 0, fmt.Errorf("snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return 0, fmt.Errorf("snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return 0, fmt.Errorf("snapshot: %w", err)
+	}
+	return len(snap.Entries), nil
+}
+
+// Restore caches entries of the snapshot which are still valid: TTL is reduced by the time since snapshot,
+// entries are checked against repository, deleted ones are skipped and changed ones are replaced.
+// Missing snapshot file is an error matching fs.ErrNotExist.
+func (s *Snapshotter) Restore(ctx context.Context, orderRepository OrderRepoI) (RestoreStats, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return RestoreStats{}, fmt.Errorf("snapshot: %w", err)
+	}
+	defer f.Close()
+
+	snap, err := Read(f)
+	if err != nil {
+		return RestoreStats{}, fmt.Errorf("snapshot %s: %w", s.path, err)
+	}
+
+	var stats RestoreStats
+	downtime := max(0, s.clock.Since(snap.CreatedAt))
+	alive := make([]Entry, 0, len(snap.Entries))
+	for _, e := range snap.Entries {
+		if e.TTL > 0 {
+			if e.TTL -= downtime; e.TTL <= 0 {
+				stats.Expired++
+				continue
+			}
+		}
+		alive = append(alive, e)
+	}
+
+	current, err := s.validate(ctx, orderRepository, alive)
+	if err != nil {
+		return stats, err
+	}
+
+	// oldest first, so recency order of the snapshot is kept
+	now := s.clock.Now()
+	for _, e := range alive {
+		ord, ok := current[e.Order.ID]
+		switch {
+		case !ok:
+			stats.Missing++
+			continue
+		case ord.Version != e.Order.Version:
+			stats.Updated++
+		default:
+			stats.Restored++
+		}
+
+		if e.TTL > 0 {
+			ord.ExpiredAt = now.Add(e.TTL)
+		}
+		_ = s.cache.AddWithTTL(ord.ID, &ord, e.TTL)
+	}
+	return stats, nil
+}
+
+// validate loads current orders of entries in concurrent batches
+func (s *Snapshotter) validate(ctx context.Context, orderRepository OrderRepoI, entries []Entry) (map[uint64]order.Order, error) {
+	var mu sync.Mutex
+	current := make(map[uint64]order.Order, len(entries))
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(validateConcurrency)
+	for start := 0; start < len(entries); start += validateBatch {
+		IDs := make([]uint64, 0, validateBatch)
+		for _, e := range entries[start:min(start+validateBatch, len(entries))] {
+			IDs = append(IDs, e.Order.ID)
+		}
+
+		g.Go(func() error {
+			ordersMap, err := orderRepository.Get(gctx, IDs)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for ID, ord := range ordersMap {
+				current[ID] = ord
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("snapshot validation: %w", err)
+	}
+	return current, nil
+}
+
+// Run saves snapshot every interval until stop is closed
+func (s *Snapshotter) Run(ctx context.Context, stop <-chan struct{}) {
+	ticker := s.clock.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			s.save()
+		}
+	}
+}
+
+// Drain takes the last snapshot, nothing is queued so nothing is dropped
+func (s *Snapshotter) Drain(context.Context) (dropped int) {
+	s.save()
+	return 0
+}
+
+func (s *Snapshotter) save() {
+	start := s.clock.Now()
+	count, err := s.Save()
+	if err != nil {
+		log.Err(err).Msg("cache snapshot error")
+		return
+	}
+	log.Info().
+		Int("count", count).
+		Str("elapsed time", s.clock.Since(start).String()).
+		Msg("cache snapshot saved")
+}
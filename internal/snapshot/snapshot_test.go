@@ -0,0 +1,126 @@
+package snapshot
+
+import (
+	"bytes"
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/lru"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	snap := Snapshot{
+		CreatedAt: time.Unix(100, 5),
+		Entries: []Entry{
+			{Order: order.Order{ID: 1, CustomerID: 7, Item: "book", Version: 3}, TTL: time.Second},
+			{Order: order.Order{ID: 2, Version: 4}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, snap); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	decoded, err := Read(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.CreatedAt.Equal(snap.CreatedAt) || len(decoded.Entries) != len(snap.Entries) {
+		t.Fatalf("expected %+v, got %+v", snap, decoded)
+	}
+	for i := range snap.Entries {
+		if decoded.Entries[i] != snap.Entries[i] {
+			t.Fatalf("expected %+v, got %+v", snap.Entries[i], decoded.Entries[i])
+		}
+	}
+
+	corrupted := bytes.Clone(encoded)
+	corrupted[30] ^= 1
+	newer := bytes.Clone(encoded)
+	newer[4] = Version + 1
+	for name, data := range map[string][]byte{
+		"corrupted": corrupted,
+		"truncated": encoded[:len(encoded)-1],
+		"version":   newer,
+		"foreign":   []byte("{\"orders\": []}"),
+	} {
+		if _, err := Read(bytes.NewReader(data)); !errors.Is(err, ErrFormat) {
+			t.Fatalf("%s: expected format error, got %v", name, err)
+		}
+	}
+}
+
+func TestSaveRestore(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Unix(0, 0))
+	repository := repo.New(repo.WithClock(fake))
+	cache := lru.NewLRU[uint64, *order.Order](0, nil, time.Minute, lru.WithClock(fake))
+
+	for i := uint64(0); i < 5; i++ {
+		if _, err := repository.Save(ctx, &order.Order{ID: i, Item: "book"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ordersMap, err := repository.Get(ctx, []uint64{0, 1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ID := uint64(0); ID < 5; ID++ {
+		ord := ordersMap[ID]
+		cache.AddWithTTL(ID, &ord, time.Minute)
+	}
+	// 4 is the shortest lived
+	short := ordersMap[4]
+	cache.AddWithTTL(4, &short, time.Second)
+
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	s := New(path, cache, time.Second, WithClock(fake))
+	if count, err := s.Save(); err != nil || count != 5 {
+		t.Fatalf("expected 5 saved entries, got %d, %v", count, err)
+	}
+
+	// while service is down 1 is changed and 2 is deleted
+	fake.Advance(10 * time.Second)
+	if _, err := repository.Save(ctx, &order.Order{ID: 1, Item: "pen"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := lru.NewLRU[uint64, *order.Order](0, nil, time.Minute, lru.WithClock(fake))
+	stats, err := New(path, restored, time.Second, WithClock(fake)).Restore(ctx, repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (RestoreStats{Restored: 2, Updated: 1, Missing: 1, Expired: 1}) {
+		t.Fatalf("unexpected restore stats %+v", stats)
+	}
+
+	if ord, ok := restored.Get(1); !ok || ord.Item != "pen" {
+		t.Fatalf("expected changed order to be cached in its current version, got %+v", ord)
+	}
+	if restored.Contains(2) || restored.Contains(4) {
+		t.Fatal("expected deleted and expired orders not to be restored")
+	}
+	// repository latency mock runs on the fake clock too
+	ttl, ok := restored.TTL(0)
+	if !ok || ttl > 50*time.Second || ttl < 49*time.Second {
+		t.Fatalf("expected TTL to be reduced by downtime, got %s", ttl)
+	}
+	if ord, _ := restored.Peek(0); !ord.ExpiredAt.Equal(fake.Now().Add(ttl)) {
+		t.Fatalf("expected expiration of restored order to follow its TTL, got %s", ord.ExpiredAt)
+	}
+
+	if _, err := New(filepath.Join(t.TempDir(), "missing"), restored, time.Second).Restore(ctx, repository); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected missing snapshot error, got %v", err)
+	}
+}
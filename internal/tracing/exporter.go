@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// InMemoryExporter keeps finished spans, used in tests
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns finished spans in order of finishing
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	spans := make([]SpanData, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
+
+// ByName returns finished spans with the name
+func (e *InMemoryExporter) ByName(name string) []SpanData {
+	result := make([]SpanData, 0)
+	for _, span := range e.Spans() {
+		if span.Name == name {
+			result = append(result, span)
+		}
+	}
+	return result
+}
+
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
+
+// WriterExporter writes spans as JSON lines
+type WriterExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{enc: json.NewEncoder(w)}
+}
+
+func NewStdoutExporter() *WriterExporter {
+	return NewWriterExporter(os.Stdout)
+}
+
+func (e *WriterExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_ = e.enc.Encode(span)
+}
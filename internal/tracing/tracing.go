@@ -0,0 +1,165 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type ctxKey int
+
+const (
+	tracerKey ctxKey = iota
+	spanKey
+)
+
+// SpanData is a finished span passed to exporter
+type SpanData struct {
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	Name       string         `json:"name"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+func (d SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+type Exporter interface {
+	Export(span SpanData)
+}
+
+type Tracer struct {
+	exporter Exporter
+}
+
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultTracer *Tracer
+)
+
+// SetDefault sets tracer used when ctx doesn't carry one, nil disables tracing
+func SetDefault(tracer *Tracer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	defaultTracer = tracer
+}
+
+// ContextWithTracer returns ctx carrying tracer for spans started from it
+func ContextWithTracer(ctx context.Context, tracer *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey, tracer)
+}
+
+func tracerFromContext(ctx context.Context) *Tracer {
+	if tracer, ok := ctx.Value(tracerKey).(*Tracer); ok {
+		return tracer
+	}
+
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+
+	return defaultTracer
+}
+
+// Span is an operation in progress, methods are no-op on nil span
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// Start starts span as a child of the span in ctx,
+// returns nil span and the same ctx if tracing is disabled
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	tracer := tracerFromContext(ctx)
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer: tracer,
+		data: SpanData{
+			SpanID: newID(8),
+			Name:   name,
+			Start:  time.Now(),
+		},
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.data.TraceID = parent.data.TraceID
+		span.data.ParentID = parent.data.SpanID
+	} else {
+		span.data.TraceID = newID(16)
+	}
+
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]any)
+	}
+	s.data.Attributes[key] = value
+}
+
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Error = err.Error()
+}
+
+// End finishes span and exports it, repeated calls are ignored
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.tracer.exporter != nil {
+		s.tracer.exporter.Export(data)
+	}
+}
+
+func newID(bytes int) string {
+	b := make([]byte, bytes)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
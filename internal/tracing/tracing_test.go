@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSpanHierarchy(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	ctx := ContextWithTracer(context.Background(), NewTracer(exporter))
+
+	ctx, root := Start(ctx, "root")
+	_, child := Start(ctx, "child")
+	child.SetAttribute("hits", 1)
+	child.RecordError(errors.New("boom"))
+	child.End()
+	child.End()
+	root.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name != "child" || spans[1].Name != "root" {
+		t.Fatalf("unexpected spans order: %s, %s", spans[0].Name, spans[1].Name)
+	}
+	if spans[0].TraceID != spans[1].TraceID || spans[0].ParentID != spans[1].SpanID {
+		t.Fatal("expected child span in root trace")
+	}
+	if spans[0].Attributes["hits"] != 1 || spans[0].Error != "boom" {
+		t.Fatalf("unexpected child span: %+v", spans[0])
+	}
+}
+
+func TestDisabledTracing(t *testing.T) {
+	ctx := context.Background()
+
+	spanCtx, span := Start(ctx, "noop")
+	if span != nil || spanCtx != ctx {
+		t.Fatal("expected no span without tracer")
+	}
+
+	// must not panic
+	span.SetAttribute("key", "value")
+	span.End()
+}
@@ -0,0 +1,97 @@
+package ttl
+
+import (
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/repository/entity/order"
+	"math/rand"
+	"time"
+)
+
+// Func returns TTL of the entry by its key, value or load cost
+type Func func(key uint64, value *order.Order, loadCost time.Duration) time.Duration
+
+type Cache interface {
+	Add(key uint64, value *order.Order) (evicted bool)
+	AddWithTTL(key uint64, value *order.Order, ttl time.Duration) (evicted bool)
+}
+
+// Policy calculates per-entry TTL with random jitter,
+// so entries loaded together don't expire together.
+// Methods of nil policy fall back to the cache default TTL.
+type Policy struct {
+	fn Func
+	// relative jitter, 0.1 spreads TTL over ±10%
+	jitter float64
+	clock  clock.Clock
+}
+
+// Option configures Policy
+type Option func(*Policy)
+
+// WithClock replaces real clock used for ExpiredAt, e.g. with clock.Fake in tests
+func WithClock(c clock.Clock) Option {
+	return func(p *Policy) {
+		p.clock = c
+	}
+}
+
+func NewPolicy(fn Func, jitter float64, opts ...Option) *Policy {
+	if jitter < 0 {
+		jitter = 0
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+
+	p := &Policy{
+		fn:     fn,
+		jitter: jitter,
+		clock:  clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Fixed returns the same TTL for every entry
+func Fixed(ttl time.Duration) Func {
+	return func(uint64, *order.Order, time.Duration) time.Duration {
+		return ttl
+	}
+}
+
+// ByLoadCost keeps expensive entries longer: base + factor * loadCost, capped by max
+func ByLoadCost(base time.Duration, factor float64, max time.Duration) Func {
+	return func(_ uint64, _ *order.Order, loadCost time.Duration) time.Duration {
+		ttl := base + time.Duration(factor*float64(loadCost))
+		if max > 0 && ttl > max {
+			ttl = max
+		}
+		return ttl
+	}
+}
+
+// TTL returns jittered TTL of the entry
+func (p *Policy) TTL(key uint64, value *order.Order, loadCost time.Duration) time.Duration {
+	ttl := p.fn(key, value, loadCost)
+	if p.jitter == 0 || ttl <= 0 {
+		return ttl
+	}
+
+	// uniform in [ttl * (1 - jitter), ttl * (1 + jitter))
+	delta := (rand.Float64()*2 - 1) * p.jitter * float64(ttl)
+	return ttl + time.Duration(delta)
+}
+
+// Add adds order with policy TTL and sets its ExpiredAt, returns true if an entry was evicted
+func (p *Policy) Add(cache Cache, key uint64, value *order.Order, loadCost time.Duration) (evicted bool) {
+	if p == nil {
+		return cache.Add(key, value)
+	}
+
+	ttl := p.TTL(key, value, loadCost)
+	value.ExpiredAt = p.clock.Now().Add(ttl)
+
+	return cache.AddWithTTL(key, value, ttl)
+}
@@ -1,24 +1,36 @@
 package order_usecase
 
 import (
-	"caching-strategies/internal/repository"
 	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
 	"context"
 	"fmt"
 )
 
+type OrderRepoI interface {
+	Save(ctx context.Context, order *order.Order) (uint64, error)
+	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+	Delete(ctx context.Context, ID uint64) error
+}
+
 type Usecase struct {
-	repo *repository.Repo
+	repo OrderRepoI
 }
 
-func New(repo *repository.Repo) *Usecase {
+func New(repo OrderRepoI) *Usecase {
 	return &Usecase{repo: repo}
 }
 
 func (uc *Usecase) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
+	ctx, span := tracing.Start(ctx, "usecase.Get")
+	defer span.End()
+	span.SetAttribute("strategy", "without_cache")
+	span.SetAttribute("ids", len(IDs))
+
 	ordersMap, err := uc.repo.Get(ctx, IDs)
 	if err != nil {
-		return nil, fmt.Errorf("err from repository: %s", err.Error())
+		span.RecordError(err)
+		return nil, fmt.Errorf("err from repository: %w", err)
 	}
 
 	result := make([]order.Order, 0, len(IDs))
@@ -30,7 +42,24 @@ func (uc *Usecase) Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
 }
 
 func (uc *Usecase) Save(ctx context.Context, order *order.Order) error {
+	ctx, span := tracing.Start(ctx, "usecase.Save")
+	defer span.End()
+	span.SetAttribute("strategy", "without_cache")
+
 	if _, err := uc.repo.Save(ctx, order); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+func (uc *Usecase) Delete(ctx context.Context, ID uint64) error {
+	ctx, span := tracing.Start(ctx, "usecase.Delete")
+	defer span.End()
+	span.SetAttribute("strategy", "without_cache")
+
+	if err := uc.repo.Delete(ctx, ID); err != nil {
+		span.RecordError(err)
 		return err
 	}
 	return nil
@@ -1,23 +1,30 @@
 package order_usecase_with_cache_aside
 
 import (
-	"caching-strategies/internal/repository"
 	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
 	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
+	"time"
 
 	"caching-strategies/internal/cache_implementations/cache_aside"
 )
 
+type OrderRepoI interface {
+	Save(ctx context.Context, order *order.Order) (uint64, error)
+	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+	Delete(ctx context.Context, ID uint64) error
+}
+
 type Usecase struct {
-	repo  *repository.Repo
+	repo  OrderRepoI
 	cache *cache_aside.CacheAside
 }
 
-func New(repo *repository.Repo, cache *cache_aside.CacheAside) *Usecase {
+func New(repo OrderRepoI, cache *cache_aside.CacheAside) *Usecase {
 	return &Usecase{
 		repo:  repo,
 		cache: cache,
@@ -25,18 +32,29 @@ func New(repo *repository.Repo, cache *cache_aside.CacheAside) *Usecase {
 }
 
 func (uc *Usecase) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
+	ctx, span := tracing.Start(ctx, "usecase.Get")
+	defer span.End()
+	span.SetAttribute("strategy", "cache_aside")
+	span.SetAttribute("ids", len(IDs))
+
 	notInCacheCh := make(chan uint64, len(IDs))
 	notInCache := make([]uint64, 0, len(IDs))
 
 	inCacheCh := make(chan order.Order, len(IDs))
 
-	g := errgroup.Group{}
+	_, lookupSpan := tracing.Start(ctx, "cache.lookup")
+
+	g, gctx := errgroup.WithContext(ctx)
 	g.SetLimit(100)
 
 	// split requests to DB
 	for _, ID := range IDs {
 		ID := ID
 		g.Go(func() error {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+
 			value, ok := uc.cache.Get(ID)
 			if !ok || value == nil {
 				// нет в кэше, будем искать в бд
@@ -52,11 +70,20 @@ func (uc *Usecase) Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
 		})
 	}
 
-	// never returns err
-	_ = g.Wait()
+	// fails only when ctx is done
+	err := g.Wait()
 	close(notInCacheCh)
 	close(inCacheCh)
 
+	lookupSpan.SetAttribute("hits", len(inCacheCh))
+	lookupSpan.SetAttribute("misses", len(notInCacheCh))
+	lookupSpan.End()
+
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
 	result := make([]order.Order, 0, len(IDs))
 	// append cache to result
 	for ord := range inCacheCh {
@@ -68,36 +95,65 @@ func (uc *Usecase) Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
 		notInCache = append(notInCache, ID)
 	}
 
-	log.Debug().Msgf("get items from cache", "count", len(IDs))
+	log.Debug().Int("count", len(IDs)).Msg("get items from cache")
 
 	// обновляем данные в кэше
 	if len(notInCache) > 0 {
+		// orders deleted or saved while loading aren't cached
+		generation := uc.cache.BeginLoad()
+		defer uc.cache.EndLoad()
+
+		start := uc.cache.Clock().Now()
 		ordersMap, err := uc.repo.Get(ctx, notInCache)
+		uc.cache.Metrics().Load(len(ordersMap), uc.cache.Clock().Since(start), err)
 		if err != nil {
-			return nil, fmt.Errorf("err from repository: %s", err.Error())
+			span.RecordError(err)
+			return nil, fmt.Errorf("err from repository: %w", err)
+		}
+		loadCost := uc.cache.Clock().Since(start) / time.Duration(len(notInCache))
+
+		// request was cancelled while loading, its orders aren't cached
+		if err := ctx.Err(); err != nil {
+			span.RecordError(err)
+			return nil, err
 		}
 
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(100)
+
 		for _, ord := range ordersMap {
 			ord := ord
 			result = append(result, ord)
 
 			g.Go(func() error {
-				_ = uc.cache.Add(ord.ID, &ord)
+				if err := gctx.Err(); err != nil {
+					return err
+				}
+
+				_ = uc.cache.AddLoaded(ord.ID, &ord, loadCost, generation)
 
 				return nil
 			})
 		}
-		_ = g.Wait()
+		if err := g.Wait(); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
 
-		log.Debug().Msgf("get from db", "count", len(ordersMap))
+		log.Debug().Int("count", len(ordersMap)).Msg("get from db")
 	}
 
 	return result, nil
 }
 
 func (uc *Usecase) Save(ctx context.Context, order *order.Order) error {
+	ctx, span := tracing.Start(ctx, "usecase.Save")
+	defer span.End()
+	span.SetAttribute("strategy", "cache_aside")
+
 	orderID, err := uc.repo.Save(ctx, order)
 	if err != nil {
+		span.RecordError(err)
 		return errors.Wrap(err, "repo.Save")
 	}
 
@@ -105,7 +161,28 @@ func (uc *Usecase) Save(ctx context.Context, order *order.Order) error {
 
 	_ = uc.cache.Add(orderID, order)
 
-	log.Debug().Msgf("cache updated: \n", "order", *order)
+	log.Debug().Interface("order", *order).Msg("cache updated")
 
 	return nil
 }
+
+func (uc *Usecase) Delete(ctx context.Context, ID uint64) error {
+	ctx, span := tracing.Start(ctx, "usecase.Delete")
+	defer span.End()
+	span.SetAttribute("strategy", "cache_aside")
+
+	err := uc.repo.Delete(ctx, ID)
+	// чистим кэш даже если заказа уже нет в бд
+	uc.cache.Invalidate(ID)
+	if err != nil {
+		span.RecordError(err)
+		return errors.Wrap(err, "repo.Delete")
+	}
+
+	return nil
+}
+
+// RemainingTTL returns time left until the cached order expires, false if it isn't cached
+func (uc *Usecase) RemainingTTL(ID uint64) (time.Duration, bool) {
+	return uc.cache.RemainingTTL(ID)
+}
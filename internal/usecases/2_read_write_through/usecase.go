@@ -2,12 +2,16 @@ package order_usecase_with_cache_through
 
 import (
 	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
 	"context"
+	"time"
 )
 
 type HotStorageI interface {
 	Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
 	Add(ctx context.Context, order *order.Order) error
+	Delete(ctx context.Context, ID uint64) error
+	RemainingTTL(ID uint64) (time.Duration, bool)
 }
 
 type Usecase struct {
@@ -19,9 +23,37 @@ func New(hotStorage HotStorageI) *Usecase {
 }
 
 func (uc *Usecase) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
-	return uc.hotStorage.Get(ctx, IDs)
+	ctx, span := tracing.Start(ctx, "usecase.Get")
+	defer span.End()
+	span.SetAttribute("strategy", "read_write_through")
+	span.SetAttribute("ids", len(IDs))
+
+	orders, err := uc.hotStorage.Get(ctx, IDs)
+	span.RecordError(err)
+	return orders, err
 }
 
 func (uc *Usecase) Save(ctx context.Context, order *order.Order) error {
-	return uc.hotStorage.Add(ctx, order)
+	ctx, span := tracing.Start(ctx, "usecase.Save")
+	defer span.End()
+	span.SetAttribute("strategy", "read_write_through")
+
+	err := uc.hotStorage.Add(ctx, order)
+	span.RecordError(err)
+	return err
+}
+
+func (uc *Usecase) Delete(ctx context.Context, ID uint64) error {
+	ctx, span := tracing.Start(ctx, "usecase.Delete")
+	defer span.End()
+	span.SetAttribute("strategy", "read_write_through")
+
+	err := uc.hotStorage.Delete(ctx, ID)
+	span.RecordError(err)
+	return err
+}
+
+// RemainingTTL returns time left until the cached order expires, false if it isn't cached
+func (uc *Usecase) RemainingTTL(ID uint64) (time.Duration, bool) {
+	return uc.hotStorage.RemainingTTL(ID)
 }
@@ -0,0 +1,95 @@
+package order_usecase_with_query_cache
+
+import (
+	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
+	"context"
+	"time"
+)
+
+// HotStorageI is the outermost cache layer, writes through it invalidate cached queries
+type HotStorageI interface {
+	Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
+	Add(ctx context.Context, order *order.Order) error
+	Delete(ctx context.Context, ID uint64) error
+	RemainingTTL(ID uint64) (time.Duration, bool)
+}
+
+type QueryStorageI interface {
+	ListByItem(ctx context.Context, item string) ([]order.Order, error)
+}
+
+type PageStorageI interface {
+	List(ctx context.Context, cursor uint64, limit int) ([]order.Order, uint64, error)
+}
+
+type Usecase struct {
+	hotStorage   HotStorageI
+	queryStorage QueryStorageI
+	pageStorage  PageStorageI
+}
+
+func New(hotStorage HotStorageI, queryStorage QueryStorageI, pageStorage PageStorageI) *Usecase {
+	return &Usecase{
+		hotStorage:   hotStorage,
+		queryStorage: queryStorage,
+		pageStorage:  pageStorage,
+	}
+}
+
+func (uc *Usecase) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
+	ctx, span := tracing.Start(ctx, "usecase.Get")
+	defer span.End()
+	span.SetAttribute("strategy", "query_cache")
+	span.SetAttribute("ids", len(IDs))
+
+	orders, err := uc.hotStorage.Get(ctx, IDs)
+	span.RecordError(err)
+	return orders, err
+}
+
+func (uc *Usecase) ListByItem(ctx context.Context, item string) ([]order.Order, error) {
+	ctx, span := tracing.Start(ctx, "usecase.ListByItem")
+	defer span.End()
+	span.SetAttribute("item", item)
+
+	orders, err := uc.queryStorage.ListByItem(ctx, item)
+	span.RecordError(err)
+	return orders, err
+}
+
+func (uc *Usecase) List(ctx context.Context, cursor uint64, limit int) ([]order.Order, uint64, error) {
+	ctx, span := tracing.Start(ctx, "usecase.List")
+	defer span.End()
+	span.SetAttribute("cursor", cursor)
+	span.SetAttribute("limit", limit)
+
+	orders, next, err := uc.pageStorage.List(ctx, cursor, limit)
+	span.RecordError(err)
+	return orders, next, err
+}
+
+func (uc *Usecase) Save(ctx context.Context, order *order.Order) error {
+	ctx, span := tracing.Start(ctx, "usecase.Save")
+	defer span.End()
+	span.SetAttribute("strategy", "query_cache")
+
+	err := uc.hotStorage.Add(ctx, order)
+	span.RecordError(err)
+	return err
+}
+
+func (uc *Usecase) Delete(ctx context.Context, ID uint64) error {
+	ctx, span := tracing.Start(ctx, "usecase.Delete")
+	defer span.End()
+	span.SetAttribute("strategy", "query_cache")
+
+	err := uc.hotStorage.Delete(ctx, ID)
+	span.RecordError(err)
+	return err
+}
+
+// RemainingTTL returns time left until the cached order expires, false if it isn't cached
+func (uc *Usecase) RemainingTTL(ID uint64) (time.Duration, bool) {
+	return uc.hotStorage.RemainingTTL(ID)
+}
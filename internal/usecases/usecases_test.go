@@ -2,19 +2,42 @@ package usecases
 
 import (
 	"caching-strategies/internal/cache_implementations/cache_aside"
+	"caching-strategies/internal/cache_implementations/options"
+	"caching-strategies/internal/cache_implementations/page_cache"
+	"caching-strategies/internal/cache_implementations/query_cache"
 	"caching-strategies/internal/cache_implementations/read_write_through"
 	"caching-strategies/internal/cache_implementations/refresh_ahead"
+	"caching-strategies/internal/chaos"
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/config"
+	"caching-strategies/internal/dataloader"
+	"caching-strategies/internal/invalidation"
+	"caching-strategies/internal/lifecycle"
+	"caching-strategies/internal/lru"
+	"caching-strategies/internal/metrics"
 	repo "caching-strategies/internal/repository"
 	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/repository/filerepo"
+	"caching-strategies/internal/repository/sqliterepo"
+	"caching-strategies/internal/resilience"
+	"caching-strategies/internal/tracing"
+	"caching-strategies/internal/ttl"
 	order_usecase "caching-strategies/internal/usecases/0_without_cache"
 	order_usecase_with_cache_aside "caching-strategies/internal/usecases/1_cache_aside"
 	order_usecase_with_cache_through "caching-strategies/internal/usecases/2_read_write_through"
 	order_usecase_with_cache_refresh "caching-strategies/internal/usecases/3_refresh_ahead"
+	order_usecase_with_query_cache "caching-strategies/internal/usecases/4_query_cache"
 	"caching-strategies/internal/watcher"
+	"caching-strategies/internal/workload"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/rs/zerolog"
+	"math/rand"
+	"path/filepath"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 )
@@ -25,18 +48,69 @@ const (
 	ordersNumber = 1000
 	batchSize    = 1
 	ctxTimeout   = 10 * time.Second
+	itemsNumber  = 10
+	pageSize     = 100
 )
 
+// repoBackend runs strategies against another repository: go test ./internal/usecases -args -repo=sqlite
+var repoBackend = flag.String("repo", config.RepoMemory, "repository of strategy tests: memory, file or sqlite")
+
 type UsecaseI interface {
 	Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
 	Save(ctx context.Context, order *order.Order) error
+	Delete(ctx context.Context, ID uint64) error
+}
+
+type OrderRepoI interface {
+	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+	ListByItem(ctx context.Context, item string) ([]uint64, error)
+	List(ctx context.Context, cursor uint64, limit int) ([]uint64, uint64, error)
+	Save(ctx context.Context, order *order.Order) (uint64, error)
+	Delete(ctx context.Context, ID uint64) error
 }
 
-func setup(ctx context.Context) (*repo.Repo, *expirable.LRU[uint64, *order.Order]) {
+// setup seeds repository selected by -repo flag
+func setup(ctx context.Context, t *testing.T) (OrderRepoI, *lru.LRU[uint64, *order.Order]) {
+	t.Helper()
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 
-	repository := repo.New()
-	cache := expirable.NewLRU[uint64, *order.Order](cacheSize, nil, cacheTTL)
+	var repository OrderRepoI
+	switch *repoBackend {
+	case config.RepoMemory:
+		repository = repo.New()
+	case config.RepoFile:
+		fileRepo, err := filerepo.Open(filepath.Join(t.TempDir(), "orders.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = fileRepo.Close() })
+		repository = fileRepo
+	case config.RepoSQLite:
+		sqliteRepo, err := sqliterepo.Open(ctx, filepath.Join(t.TempDir(), "orders.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = sqliteRepo.Close() })
+		repository = sqliteRepo
+	default:
+		t.Fatalf("unknown repository %q, expected one of %v", *repoBackend, config.Repos)
+	}
+
+	for i := 0; i < ordersNumber; i++ {
+		if _, err := repository.Save(ctx, &order.Order{ID: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repository, lru.NewLRU[uint64, *order.Order](cacheSize, nil, cacheTTL)
+}
+
+// setupWithClock seeds in-memory repository whatever -repo is,
+// its latency mock and cache expiration follow the clock
+func setupWithClock(ctx context.Context, c clock.Clock) (*repo.Repo, *lru.LRU[uint64, *order.Order]) {
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	repository := repo.New(repo.WithClock(c))
+	cache := lru.NewLRU[uint64, *order.Order](cacheSize, nil, cacheTTL, lru.WithClock(c))
 	for i := 0; i < ordersNumber; i++ {
 		_, err := repository.Save(ctx, &order.Order{ID: uint64(i)})
 		if err != nil {
@@ -46,19 +120,30 @@ func setup(ctx context.Context) (*repo.Repo, *expirable.LRU[uint64, *order.Order
 	return repository, cache
 }
 
-func getOrders(ctx context.Context, N uint64, uc UsecaseI) {
+// sequential reads orders 0..n-1 in batches of batchSize
+func sequential(n uint64) *workload.Mix {
+	return workload.NewMix(workload.Scan(n), nil, 1, batchSize)
+}
+
+func getOrders(ctx context.Context, t *testing.T, N int, uc UsecaseI, mix *workload.Mix) {
+	t.Helper()
 	start := time.Now()
 
-	var i uint64
-	for ; i < N; i++ {
-		_, err := uc.Get(ctx, []uint64{i})
-		if err != nil {
-			panic(err.Error())
+	for i := 0; i < N; i++ {
+		op := mix.Next()
+		if op.Write {
+			if err := uc.Save(ctx, &order.Order{ID: op.Keys[0]}); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if _, err := uc.Get(ctx, op.Keys); err != nil {
+			t.Fatal(err)
 		}
 	}
 
 	elapsed := time.Since(start)
-	fmt.Printf("getOrders timeout: %s\n", elapsed)
+	t.Logf("getOrders timeout: %s", elapsed)
 }
 
 // without cache ~ 1.2 sec
@@ -66,13 +151,13 @@ func TestWithoutCache(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
 
-	repository, _ := setup(ctx)
+	repository, _ := setup(ctx, t)
 	usecase := order_usecase.New(repository)
 
 	// cold cache
-	getOrders(ctx, ordersNumber, usecase)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
 	// cold cache
-	getOrders(ctx, ordersNumber, usecase)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
 }
 
 // with warm cache ~ 6 msec
@@ -80,14 +165,14 @@ func TestCacheAside(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
 
-	repository, cache := setup(ctx)
+	repository, cache := setup(ctx, t)
 	asideCache := cache_aside.New(cache)
 	usecase := order_usecase_with_cache_aside.New(repository, asideCache)
 
 	// cold cache
-	getOrders(ctx, ordersNumber, usecase)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
 	// warm cache
-	getOrders(ctx, ordersNumber, usecase)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
 }
 
 // with warm cache ~ 6 msec
@@ -95,64 +180,931 @@ func TestCacheThrough(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
 
-	repository, cache := setup(ctx)
+	repository, cache := setup(ctx, t)
 	readWriteThroughCache := read_write_through.New(cache, repository)
 	usecase := order_usecase_with_cache_through.New(readWriteThroughCache)
 
 	// cold cache
-	getOrders(ctx, ordersNumber, usecase)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
 	// warm cache
-	getOrders(ctx, ordersNumber, usecase)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
 }
 
-// with warm cache ~ 6 msec
-// after expiring ~ 1.2 sec
+// simulated time: loading 1000 orders takes 1 sec of repository latency,
+// so after two halves of TTL every order loaded by the cold read is expired
 func TestCacheThroughWithTimeout(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
 
-	repository, cache := setup(ctx)
-	readWriteThroughCache := read_write_through.New(cache, repository)
+	fake := clock.NewFake(time.Unix(0, 0))
+	repository, cache := setupWithClock(ctx, fake)
+	registry := metrics.NewRegistry()
+	readWriteThroughCache := read_write_through.New(
+		cache,
+		metrics.NewRepo(repository, registry),
+		options.WithMetrics(registry.CacheMetrics("read_write_through")),
+		options.WithClock(fake),
+	)
 	usecase := order_usecase_with_cache_through.New(readWriteThroughCache)
 
 	// cold cache
-	getOrders(ctx, ordersNumber, usecase)
-	time.Sleep(cacheTTL / 2)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
+	fake.Advance(cacheTTL / 2)
 
 	// warm cache
-	getOrders(ctx, ordersNumber, usecase)
-	time.Sleep(cacheTTL / 2)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
+	fake.Advance(cacheTTL / 2)
 
 	// cache was expired
-	getOrders(ctx, ordersNumber, usecase)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
+
+	hits := registry.Value("cache_hits_total", metrics.Labels{"strategy": "read_write_through"})
+	misses := registry.Value("cache_misses_total", metrics.Labels{"strategy": "read_write_through"})
+	if hits != ordersNumber || misses != 2*ordersNumber {
+		t.Fatalf("expected %d hits and %d misses, got %v and %v", ordersNumber, 2*ordersNumber, hits, misses)
+	}
+	t.Logf("hit ratio: %.2f, db calls: %v",
+		hits/(hits+misses),
+		registry.Value("repository_calls_total", metrics.Labels{"op": "get"}),
+	)
 }
 
-// warm cache ~ 5 msec
-// refreshed cache ~ 9 msec
-func TestCacheRefreshAhead(t *testing.T) {
+// cache of 10% of orders: sequential scan never hits LRU,
+// skewed workloads keep hit ratio far above uniform one
+func TestCacheAsideWorkloads(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*ctxTimeout)
+	defer cancel()
+
+	const (
+		smallCacheSize = ordersNumber / 10
+		requests       = 1000
+		readRatio      = 0.9
+	)
+
+	repository, _ := setup(ctx, t)
+
+	hitRatios := make(map[string]float64, len(workload.Distributions))
+	for i, distribution := range workload.Distributions {
+		spec := workload.DefaultSpec(ordersNumber)
+		spec.Distribution = distribution
+		spec.ShiftEvery = requests / 4
+		spec.BurstPeriod = requests / 4
+		spec.BurstLength = requests / 8
+
+		rnd := rand.New(rand.NewSource(int64(i)))
+		keys, err := spec.New(rnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		registry := metrics.NewRegistry()
+		cache := lru.NewLRU[uint64, *order.Order](smallCacheSize, nil, cacheTTL)
+		asideCache := cache_aside.New(cache, options.WithMetrics(registry.CacheMetrics(distribution)))
+		usecase := order_usecase_with_cache_aside.New(repository, asideCache)
+
+		getOrders(ctx, t, requests, usecase, workload.NewMix(keys, rnd, readRatio, batchSize))
+
+		hits := registry.Value("cache_hits_total", metrics.Labels{"strategy": distribution})
+		misses := registry.Value("cache_misses_total", metrics.Labels{"strategy": distribution})
+		hitRatios[distribution] = hits / (hits + misses)
+		t.Logf("%s hit ratio: %.2f", distribution, hitRatios[distribution])
+	}
+
+	if hitRatios[workload.DistributionScan] != 0 {
+		t.Fatalf("expected scan to miss LRU smaller than key space, got %.2f", hitRatios[workload.DistributionScan])
+	}
+	uniform := hitRatios[workload.DistributionUniform]
+	for _, distribution := range []string{workload.DistributionZipf, workload.DistributionHotspot} {
+		if hitRatios[distribution] <= 2*uniform {
+			t.Fatalf("expected %s hit ratio to be far above uniform %.2f, got %.2f", distribution, uniform, hitRatios[distribution])
+		}
+	}
+}
+
+// DB outage: strategy without cache fails every read, warm caches keep serving reads
+// and fail only the writes, which have to reach the DB
+func TestStrategiesUnderOutage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	fake := clock.NewFake(time.Unix(0, 0))
+	repository, _ := setupWithClock(ctx, fake)
+	sick := chaos.New(repository, chaos.WithClock(fake), chaos.WithSeed(1))
+
+	for _, tc := range []struct {
+		name    string
+		usecase UsecaseI
+		warm    bool
+	}{
+		{name: "without_cache", usecase: order_usecase.New(sick)},
+		{
+			name: "cache_aside",
+			usecase: order_usecase_with_cache_aside.New(
+				sick,
+				cache_aside.New(lru.NewLRU[uint64, *order.Order](cacheSize, nil, cacheTTL, lru.WithClock(fake)),
+					options.WithClock(fake)),
+			),
+			warm: true,
+		},
+		{
+			name: "read_write_through",
+			usecase: order_usecase_with_cache_through.New(read_write_through.New(
+				lru.NewLRU[uint64, *order.Order](cacheSize, nil, cacheTTL, lru.WithClock(fake)),
+				sick,
+				options.WithClock(fake),
+			)),
+			warm: true,
+		},
+	} {
+		sick.Heal()
+		getOrders(ctx, t, ordersNumber, tc.usecase, sequential(ordersNumber))
+		sick.Outage(cacheTTL)
+
+		failedReads := 0
+		for i := uint64(0); i < ordersNumber; i++ {
+			if _, err := tc.usecase.Get(ctx, []uint64{i}); err != nil {
+				failedReads++
+			}
+		}
+		err := tc.usecase.Save(ctx, &order.Order{ID: 1})
+
+		if tc.warm && failedReads != 0 || !tc.warm && failedReads != ordersNumber {
+			t.Fatalf("%s: unexpected %d failed reads of %d", tc.name, failedReads, ordersNumber)
+		}
+		if !errors.Is(err, chaos.ErrOutage) {
+			t.Fatalf("%s: expected write to fail with outage, got %v", tc.name, err)
+		}
+		t.Logf("%s: %d of %d reads failed during outage", tc.name, failedReads, ordersNumber)
+	}
+}
+
+// 1000 concurrent single-ID reads of cold cache: dataloader merges misses of all strategies into few repository calls
+func TestStrategiesWithLoader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	repository, _ := setup(ctx, t)
+	// a call costs 5ms however many IDs it loads
+	slow := chaos.New(repository, chaos.WithSeed(1),
+		chaos.WithOpFaults(chaos.OpGet, chaos.Faults{Latency: chaos.Fixed(5 * time.Millisecond)}))
+
+	for _, tc := range []struct {
+		name       string
+		newUsecase func(loader *dataloader.Loader) UsecaseI
+	}{
+		{name: "without_cache", newUsecase: func(loader *dataloader.Loader) UsecaseI {
+			return order_usecase.New(loader)
+		}},
+		{name: "cache_aside", newUsecase: func(loader *dataloader.Loader) UsecaseI {
+			cache := lru.NewLRU[uint64, *order.Order](cacheSize, nil, cacheTTL)
+			return order_usecase_with_cache_aside.New(loader, cache_aside.New(cache))
+		}},
+		{name: "read_write_through", newUsecase: func(loader *dataloader.Loader) UsecaseI {
+			cache := lru.NewLRU[uint64, *order.Order](cacheSize, nil, cacheTTL)
+			return order_usecase_with_cache_through.New(read_write_through.New(cache, loader))
+		}},
+	} {
+		loader, err := dataloader.New(slow, dataloader.DefaultPolicy())
+		if err != nil {
+			t.Fatal(err)
+		}
+		usecase := tc.newUsecase(loader)
+		callsBefore := slow.Stats().Calls
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var failed int
+		for i := 0; i < ordersNumber; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				orders, err := usecase.Get(ctx, []uint64{uint64(i)})
+				if err != nil || len(orders) != 1 || orders[0].ID != uint64(i) {
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		calls := slow.Stats().Calls - callsBefore
+		if failed != 0 {
+			t.Fatalf("%s: %d reads returned wrong orders", tc.name, failed)
+		}
+		if calls > ordersNumber/10 {
+			t.Fatalf("%s: expected reads to be merged, got %d repository calls for %d reads", tc.name, calls, ordersNumber)
+		}
+		t.Logf("%s: %d repository calls for %d reads", tc.name, calls, ordersNumber)
+	}
+}
+
+// DB outage after cache expired: breaker stops calling DB after a few failures,
+// expired orders are served from stale cache, first probe after outage closes breaker
+func TestCacheThroughBreaker(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	const threshold = 5
+
+	fake := clock.NewFake(time.Unix(0, 0))
+	repository, cache := setupWithClock(ctx, fake)
+	sick := chaos.New(repository, chaos.WithClock(fake))
+	registry := metrics.NewRegistry()
+	breaker := resilience.NewBreaker(resilience.BreakerConfig{
+		FailureThreshold: threshold,
+		OpenTimeout:      time.Second,
+		HalfOpenProbes:   1,
+	}, fake)
+	readWriteThroughCache := read_write_through.New(
+		cache,
+		sick,
+		options.WithMetrics(registry.CacheMetrics("read_write_through")),
+		options.WithClock(fake),
+		options.WithBreaker(breaker),
+		options.WithStale(lru.NewLRU[uint64, *order.Order](cacheSize, nil, 10*cacheTTL, lru.WithClock(fake))),
+	)
+	usecase := order_usecase_with_cache_through.New(readWriteThroughCache)
+
+	// cold cache
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
+	fake.Advance(cacheTTL)
+
+	// cache expired, every read fails over to stale cache, panics on error
+	sick.Outage(time.Hour)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
+
+	labels := metrics.Labels{"strategy": "read_write_through"}
+	if calls := sick.Stats().Outages; calls != threshold {
+		t.Fatalf("expected breaker to open after %d failed calls, got %d", threshold, calls)
+	}
+	if rejected := registry.Value("cache_load_rejected_total", labels); rejected != ordersNumber-threshold {
+		t.Fatalf("expected %d rejected loads, got %v", ordersNumber-threshold, rejected)
+	}
+	if stale := registry.Value("cache_stale_served_total", labels); stale != ordersNumber {
+		t.Fatalf("expected %d stale orders, got %v", ordersNumber, stale)
+	}
+
+	// order never loaded has no stale copy
+	if _, err := usecase.Get(ctx, []uint64{ordersNumber}); !errors.Is(err, resilience.ErrOpen) {
+		t.Fatalf("expected open breaker error, got %v", err)
+	}
+
+	sick.Heal()
+	fake.Advance(time.Second)
+	getOrders(ctx, t, 1, usecase, sequential(1))
+	if breaker.State() != resilience.StateClosed {
+		t.Fatalf("expected probe to close breaker, got %s", breaker.State())
+	}
+}
+
+// request deadline interrupts cold load of 1000 orders (~1 sec of repository latency):
+// every strategy returns promptly, nothing is cached and no goroutine is left behind
+func TestCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	const deadline = 20 * time.Millisecond
+
+	repository, _ := setupWithClock(ctx, clock.Real())
+	IDs := make([]uint64, ordersNumber)
+	for i := range IDs {
+		IDs[i] = uint64(i)
+	}
+
+	newCache := func() *lru.LRU[uint64, *order.Order] {
+		return lru.NewLRU[uint64, *order.Order](cacheSize, nil, cacheTTL)
+	}
+	refreshQueue := lifecycle.NewQueue[uint64](ordersNumber)
+	caches := map[string]*lru.LRU[uint64, *order.Order]{
+		"cache_aside":        newCache(),
+		"read_write_through": newCache(),
+		"refresh_ahead":      newCache(),
+	}
+	usecases := map[string]UsecaseI{
+		"without_cache": order_usecase.New(repository),
+		"cache_aside": order_usecase_with_cache_aside.New(
+			repository,
+			cache_aside.New(caches["cache_aside"]),
+		),
+		"read_write_through": order_usecase_with_cache_through.New(
+			read_write_through.New(caches["read_write_through"], repository),
+		),
+		"refresh_ahead": order_usecase_with_cache_refresh.New(
+			refresh_ahead.New(caches["refresh_ahead"], repository, cacheTTL, refreshQueue),
+		),
+	}
+
+	goroutines := runtime.NumGoroutine()
+	for name, usecase := range usecases {
+		reqCtx, reqCancel := context.WithTimeout(ctx, deadline)
+		start := time.Now()
+		_, err := usecase.Get(reqCtx, IDs)
+		elapsed := time.Since(start)
+		reqCancel()
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("%s: expected deadline error, got %v", name, err)
+		}
+		if elapsed > 10*deadline {
+			t.Fatalf("%s: expected prompt return after %s, took %s", name, deadline, elapsed)
+		}
+		if cache, ok := caches[name]; ok && cache.Len() != 0 {
+			t.Fatalf("%s: expected cancelled load not to be cached, got %d orders", name, cache.Len())
+		}
+		t.Logf("%s returned in %s", name, elapsed)
+	}
+
+	// cancelled before start
+	cancelled, cancelNow := context.WithCancel(ctx)
+	cancelNow()
+	for name, usecase := range usecases {
+		if _, err := usecase.Get(cancelled, IDs); !errors.Is(err, context.Canceled) {
+			t.Fatalf("%s: expected cancellation error, got %v", name, err)
+		}
+	}
+
+	for wait := time.Now(); runtime.NumGoroutine() > goroutines; {
+		if time.Since(wait) > time.Second {
+			t.Fatalf("goroutines leaked: %d before, %d after", goroutines, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// stopped watcher drops refresh in flight instead of writing its result to cache
+func TestWatcherCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	repository, cache := setupWithClock(ctx, clock.Real())
+	refreshQueue := lifecycle.NewQueue[uint64](ordersNumber)
+	cacheWatcher := watcher.New(cache, repository, refreshQueue, cacheTTL)
+
+	for i := 0; i < ordersNumber; i++ {
+		if err := refreshQueue.Offer(uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	watcherCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cacheWatcher.Start(watcherCtx)
+	}()
+
+	// first tick takes the whole queue, its load lasts ~1 sec
+	for refreshQueue.Len() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	start := time.Now()
+	stop()
+	<-done
+
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("expected watcher to stop promptly, took %s", elapsed)
+	}
+	if cache.Len() != 0 {
+		t.Fatalf("expected interrupted refresh not to be cached, got %d orders", cache.Len())
+	}
+}
+
+// stopped watcher finishes queued refreshes within stop deadline, rejects new ones and reports what it dropped
+func TestWatcherDrain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	for _, tc := range []struct {
+		name    string
+		timeout time.Duration
+		dropped bool
+	}{
+		{name: "in time", timeout: ctxTimeout},
+		// refresh of the whole queue takes ~1 sec
+		{name: "deadline", timeout: 50 * time.Millisecond, dropped: true},
+	} {
+		repository, cache := setupWithClock(ctx, clock.Real())
+		refreshQueue := lifecycle.NewQueue[uint64](ordersNumber)
+		workers := lifecycle.NewManager()
+		workers.Add("refresh_watcher", watcher.New(cache, repository, refreshQueue, cacheTTL))
+		workers.Start(ctx)
+
+		for i := 0; i < ordersNumber; i++ {
+			if err := refreshQueue.Offer(uint64(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		stopCtx, stopCancel := context.WithTimeout(ctx, tc.timeout)
+		report, err := workers.Stop(stopCtx)
+		stopCancel()
+
+		if err := refreshQueue.Offer(0); !errors.Is(err, lifecycle.ErrStopped) {
+			t.Fatalf("%s: expected stopped watcher to reject refresh, got %v", tc.name, err)
+		}
+		dropped := report.Dropped["refresh_watcher"]
+		if !tc.dropped && (err != nil || dropped != 0 || cache.Len() != ordersNumber) {
+			t.Fatalf("%s: expected all refreshes to be drained, got %d cached, %d dropped, %v", tc.name, cache.Len(), dropped, err)
+		}
+		if tc.dropped && (!errors.Is(err, context.DeadlineExceeded) || dropped+cache.Len() != ordersNumber) {
+			t.Fatalf("%s: expected undone refreshes to be reported, got %d cached, %d dropped, %v", tc.name, cache.Len(), dropped, err)
+		}
+		t.Logf("%s: %d refreshed, %d dropped", tc.name, cache.Len(), dropped)
+	}
+}
+
+// orders loaded in one batch expire over [TTL/2, 3*TTL/2) instead of all at once
+func TestCacheThroughWithJitter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	const ttlWithJitter = 300 * time.Millisecond
+
+	fake := clock.NewFake(time.Unix(0, 0))
+	repository, _ := setupWithClock(ctx, fake)
+	cache := lru.NewLRU[uint64, *order.Order](cacheSize, nil, cacheTTL, lru.WithClock(fake))
+	policy := ttl.NewPolicy(ttl.Fixed(ttlWithJitter), 0.5, ttl.WithClock(fake))
+	readWriteThroughCache := read_write_through.New(cache, repository,
+		options.WithTTLPolicy(policy), options.WithClock(fake))
+	usecase := order_usecase_with_cache_through.New(readWriteThroughCache)
+
+	IDs := make([]uint64, 0, ordersNumber)
+	for i := 0; i < ordersNumber; i++ {
+		IDs = append(IDs, uint64(i))
+	}
+	if _, err := usecase.Get(ctx, IDs); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing expires before TTL/2
+	fake.Advance(ttlWithJitter/2 - time.Millisecond)
+	if cached := cache.Len(); cached != ordersNumber {
+		t.Fatalf("expected no order to expire before TTL/2, %d of %d cached", cached, ordersNumber)
+	}
+
+	// about half of the orders expired
+	fake.Advance(ttlWithJitter / 2)
+	if cached := cache.Len(); cached == 0 || cached == ordersNumber {
+		t.Fatalf("expected orders to expire gradually, %d of %d cached", cached, ordersNumber)
+	}
+
+	// everything expired by 3*TTL/2
+	fake.Advance(ttlWithJitter)
+	if cached := cache.Len(); cached != 0 {
+		t.Fatalf("expected all orders to expire by 3*TTL/2, %d of %d cached", cached, ordersNumber)
+	}
+}
+
+// active orders stay cached beyond TTL, idle ones expire
+func TestCacheThroughSliding(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
 
-	repository, cache := setup(ctx)
+	const (
+		slidingTTL   = 300 * time.Millisecond
+		activeNumber = 10
+	)
 
-	refreshCh := make(chan uint64, 1000)
-	defer close(refreshCh)
+	fake := clock.NewFake(time.Unix(0, 0))
+	repository, _ := setupWithClock(ctx, fake)
+	cache := lru.NewLRU[uint64, *order.Order](cacheSize, nil, slidingTTL,
+		lru.WithSliding(10*slidingTTL), lru.WithClock(fake))
+	readWriteThroughCache := read_write_through.New(cache, repository, options.WithClock(fake))
+	usecase := order_usecase_with_cache_through.New(readWriteThroughCache)
 
-	// start cache-refresh watcher
-	cacheWatcher := watcher.New(cache, repository, refreshCh, cacheTTL)
-	go cacheWatcher.Start(ctx)
+	// cold cache
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
+
+	for start := fake.Now(); fake.Since(start) < 2*slidingTTL; {
+		fake.Advance(slidingTTL / 3)
+		getOrders(ctx, t, activeNumber, usecase, sequential(activeNumber))
+	}
 
-	refreshAheadCache := refresh_ahead.New(cache, repository, cacheTTL, refreshCh)
+	if cached := cache.Len(); cached != activeNumber {
+		t.Fatalf("expected only %d active orders to be cached, got %d", activeNumber, cached)
+	}
+}
+
+// spans show where time of a partially cached batch went
+func TestCacheThroughTracing(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+	ctx, cancel := context.WithTimeout(
+		tracing.ContextWithTracer(context.Background(), tracing.NewTracer(exporter)),
+		ctxTimeout,
+	)
+	defer cancel()
+
+	repository, cache := setup(ctx, t)
+	readWriteThroughCache := read_write_through.New(cache, repository)
+	usecase := order_usecase_with_cache_through.New(readWriteThroughCache)
+
+	// warm half of the batch
+	getOrders(ctx, t, 5, usecase, sequential(5))
+	exporter.Reset()
+
+	if _, err := usecase.Get(ctx, []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}); err != nil {
+		t.Fatal(err)
+	}
+
+	root := exporter.ByName("usecase.Get")
+	lookup := exporter.ByName("cache.lookup")
+	load := exporter.ByName("repository.Get")
+	if len(root) != 1 || len(lookup) != 1 || len(load) != 1 {
+		t.Fatalf("expected one span of each kind, got %d, %d, %d", len(root), len(lookup), len(load))
+	}
+	if lookup[0].Attributes["hits"] != 5 || lookup[0].Attributes["misses"] != 5 {
+		t.Fatalf("unexpected lookup attributes: %v", lookup[0].Attributes)
+	}
+	if load[0].Attributes["ids"] != 5 || load[0].TraceID != root[0].TraceID {
+		t.Fatalf("expected repository load of 5 misses in the same trace: %+v", load[0])
+	}
+	t.Logf("lookup: %s, load: %s, total: %s", lookup[0].Duration(), load[0].Duration(), root[0].Duration())
+}
+
+// simulated time: watcher is ticked by hand, so refreshed orders are known exactly
+func TestCacheRefreshAhead(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	fake := clock.NewFake(time.Unix(0, 0))
+	repository, cache := setupWithClock(ctx, fake)
+	registry := metrics.NewRegistry()
+	opts := []options.Option{
+		options.WithMetrics(registry.CacheMetrics("refresh_ahead")),
+		options.WithClock(fake),
+	}
+
+	refreshQueue := lifecycle.NewQueue[uint64](ordersNumber)
+	cacheWatcher := watcher.New(cache, repository, refreshQueue, cacheTTL, opts...)
+	refreshAheadCache := refresh_ahead.New(cache, repository, cacheTTL, refreshQueue, opts...)
 	usecase := order_usecase_with_cache_refresh.New(refreshAheadCache)
 
 	// cold cache
-	getOrders(ctx, ordersNumber, usecase)
-	time.Sleep(cacheTTL / 2)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
+	fake.Advance(cacheTTL / 2)
 
-	// warming cache while reading
-	getOrders(ctx, ordersNumber, usecase)
-	time.Sleep(cacheTTL / 2)
+	// warming cache while reading: every order is half expired and queued for refresh
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
+	cacheWatcher.Tick(ctx)
+	fake.Advance(cacheTTL / 2)
 
 	// cache wasn't expired
-	getOrders(ctx, ordersNumber, usecase)
+	getOrders(ctx, t, ordersNumber, usecase, sequential(ordersNumber))
+
+	labels := metrics.Labels{"strategy": "refresh_ahead"}
+	if refreshed := registry.Value("cache_refreshes_total", labels); refreshed != ordersNumber {
+		t.Fatalf("expected %d refreshed orders, got %v", ordersNumber, refreshed)
+	}
+	hits, misses := registry.Value("cache_hits_total", labels), registry.Value("cache_misses_total", labels)
+	if hits != 2*ordersNumber || misses != ordersNumber {
+		t.Fatalf("expected only cold read to miss, got %v hits and %v misses", hits, misses)
+	}
+}
+
+// pausedRepo holds loaded orders until released, so writes happen while the load is in flight
+type pausedRepo struct {
+	OrderRepoI
+	loaded  chan struct{}
+	release chan struct{}
+}
+
+func (r *pausedRepo) Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error) {
+	ordersMap, err := r.OrderRepoI.Get(ctx, IDs)
+	r.loaded <- struct{}{}
+	<-r.release
+	return ordersMap, err
+}
+
+// order deleted or saved during watcher refresh isn't overwritten by the refresh loaded before
+func TestCacheRefreshAheadWriteDuringRefresh(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	fake := clock.NewFake(time.Unix(0, 0))
+	repository, cache := setupWithClock(ctx, fake)
+	opts := []options.Option{
+		options.WithClock(fake),
+		options.WithGenerations(invalidation.NewGenerations()),
+	}
+
+	paused := &pausedRepo{OrderRepoI: repository, loaded: make(chan struct{}), release: make(chan struct{})}
+	refreshQueue := lifecycle.NewQueue[uint64](ordersNumber)
+	cacheWatcher := watcher.New(cache, paused, refreshQueue, cacheTTL, opts...)
+	usecase := order_usecase_with_cache_refresh.New(refresh_ahead.New(cache, repository, cacheTTL, refreshQueue, opts...))
+
+	for _, ID := range []uint64{1, 2, 3} {
+		if err := refreshQueue.Offer(ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		cacheWatcher.Tick(ctx)
+	}()
+
+	<-paused.loaded
+	if err := usecase.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := usecase.Save(ctx, &order.Order{ID: 2, Item: "saved"}); err != nil {
+		t.Fatal(err)
+	}
+	close(paused.release)
+	<-refreshed
+
+	if cache.Contains(1) {
+		t.Fatal("expected deleted order not to be written back by refresh")
+	}
+	if ord, ok := cache.Peek(2); !ok || ord.Item != "saved" {
+		t.Fatalf("expected saved order not to be overwritten by refresh, got %+v", ord)
+	}
+	if !cache.Contains(3) {
+		t.Fatal("expected untouched order to be refreshed")
+	}
+}
+
+// order deleted or saved during cache miss load isn't overwritten by the load result
+func TestCacheWriteDuringLoad(t *testing.T) {
+	for name, newUsecase := range map[string]func(repository OrderRepoI, cache *lru.LRU[uint64, *order.Order], opts ...options.Option) UsecaseI{
+		"cache_aside": func(repository OrderRepoI, cache *lru.LRU[uint64, *order.Order], opts ...options.Option) UsecaseI {
+			return order_usecase_with_cache_aside.New(repository, cache_aside.New(cache, opts...))
+		},
+		"read_write_through": func(repository OrderRepoI, cache *lru.LRU[uint64, *order.Order], opts ...options.Option) UsecaseI {
+			return order_usecase_with_cache_through.New(read_write_through.New(cache, repository, opts...))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+			defer cancel()
+
+			fake := clock.NewFake(time.Unix(0, 0))
+			repository, cache := setupWithClock(ctx, fake)
+			for _, ID := range []uint64{1, 2, 3} {
+				if _, err := repository.Save(ctx, &order.Order{ID: ID, Item: "loaded"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			paused := &pausedRepo{OrderRepoI: repository, loaded: make(chan struct{}), release: make(chan struct{})}
+			usecase := newUsecase(paused, cache,
+				options.WithClock(fake),
+				options.WithGenerations(invalidation.NewGenerations()),
+			)
+
+			loaded := make(chan error)
+			go func() {
+				_, err := usecase.Get(ctx, []uint64{1, 2, 3})
+				loaded <- err
+			}()
+
+			<-paused.loaded
+			if err := usecase.Delete(ctx, 1); err != nil {
+				t.Fatal(err)
+			}
+			if err := usecase.Save(ctx, &order.Order{ID: 2, Item: "saved"}); err != nil {
+				t.Fatal(err)
+			}
+			close(paused.release)
+			if err := <-loaded; err != nil {
+				t.Fatal(err)
+			}
+
+			if cache.Contains(1) {
+				t.Fatal("expected deleted order not to be cached by the load")
+			}
+			if ord, ok := cache.Peek(2); !ok || ord.Item != "saved" {
+				t.Fatalf("expected saved order not to be overwritten by the load, got %+v", ord)
+			}
+			if !cache.Contains(3) {
+				t.Fatal("expected untouched order to be cached")
+			}
+		})
+	}
+}
+
+// cold list ~ 1 msec per order
+// warm list ~ 0 msec
+func TestQueryCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	repository, cache := setup(ctx, t)
+	usecase := setupQueryCache(repository, cache)
+
+	for i := 0; i < ordersNumber; i++ {
+		err := usecase.Save(ctx, &order.Order{ID: uint64(i), Item: fmt.Sprintf("item-%d", i%itemsNumber)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// cold list
+	listOrders(ctx, t, "item-1", ordersNumber/itemsNumber, usecase)
+	// warm list
+	listOrders(ctx, t, "item-1", ordersNumber/itemsNumber, usecase)
+
+	// moving order to another item invalidates both lists
+	if err := usecase.Save(ctx, &order.Order{ID: 1, Item: "item-2"}); err != nil {
+		t.Fatal(err)
+	}
+	listOrders(ctx, t, "item-1", ordersNumber/itemsNumber-1, usecase)
+	orders := listOrders(ctx, t, "item-2", ordersNumber/itemsNumber+1, usecase)
+	if orders[0].ID != 1 {
+		t.Fatalf("expected order 1 first, got %d", orders[0].ID)
+	}
+}
+
+func listOrders(
+	ctx context.Context,
+	t *testing.T,
+	item string,
+	expected int,
+	uc *order_usecase_with_query_cache.Usecase,
+) []order.Order {
+	start := time.Now()
+
+	orders, err := uc.ListByItem(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != expected {
+		t.Fatalf("expected %d orders of %s, got %d", expected, item, len(orders))
+	}
+	for _, ord := range orders {
+		if ord.Item != item {
+			t.Fatalf("order %d has item %s, expected %s", ord.ID, ord.Item, item)
+		}
+	}
+
+	elapsed := time.Since(start)
+	t.Logf("listOrders timeout: %s", elapsed)
+
+	return orders
+}
+
+// cold pages ~ 1.1 sec
+// warm pages ~ 1 msec
+func TestPageCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	repository, cache := setup(ctx, t)
+	registry := metrics.NewRegistry()
+	usecase := setupQueryCache(metrics.NewRepo(repository, registry), cache)
+
+	// pages served from cache don't list repository
+	lists := func() float64 {
+		return registry.Value("repository_calls_total", metrics.Labels{"op": "list"})
+	}
+	expectLists := func(stage string, expected float64) {
+		t.Helper()
+		if got := lists(); got != expected {
+			t.Fatalf("%s: expected %v list calls, got %v", stage, expected, got)
+		}
+	}
+
+	// cold pages
+	pageOrders(ctx, t, ordersNumber, usecase)
+	cold := lists()
+	// warm pages
+	pageOrders(ctx, t, ordersNumber, usecase)
+	expectLists("warm pages", cold)
+
+	// update doesn't shift pages
+	if err := usecase.Save(ctx, &order.Order{ID: 1, Item: "updated"}); err != nil {
+		t.Fatal(err)
+	}
+	pageOrders(ctx, t, ordersNumber, usecase)
+	expectLists("update", cold)
+
+	// insert invalidates only the last page, reloaded one points to a new empty page
+	if err := usecase.Save(ctx, &order.Order{ID: ordersNumber}); err != nil {
+		t.Fatal(err)
+	}
+	pageOrders(ctx, t, ordersNumber+1, usecase)
+	expectLists("insert", cold+2)
+}
+
+// invalidating customer tag drops its orders and cascades to lists containing them
+func TestInvalidateTag(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	repository, cache := setup(ctx, t)
+	registry := invalidation.New(invalidation.DefaultTags)
+	usecase := setupQueryCache(repository, cache, options.WithRegistry(registry))
+
+	for i := 0; i < ordersNumber; i++ {
+		err := usecase.Save(ctx, &order.Order{
+			ID:         uint64(i),
+			CustomerID: uint64(i % itemsNumber),
+			Item:       fmt.Sprintf("item-%d", i%2),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	listOrders(ctx, t, "item-0", ordersNumber/2, usecase)
+
+	// customer 2 orders are in item-0 list only
+	invalidated := registry.InvalidateTag(invalidation.CustomerTag(2))
+	if invalidated != ordersNumber/itemsNumber {
+		t.Fatalf("expected %d invalidated orders, got %d", ordersNumber/itemsNumber, invalidated)
+	}
+	if cache.Contains(2) || !cache.Contains(1) {
+		t.Fatal("expected only customer orders to be removed from cache")
+	}
+
+	// dependent list is reloaded
+	listOrders(ctx, t, "item-0", ordersNumber/2, usecase)
+	if invalidated = registry.InvalidateTag(invalidation.CustomerTag(2)); invalidated != ordersNumber/itemsNumber {
+		t.Fatalf("expected orders to be tagged again after reload, got %d", invalidated)
+	}
+}
+
+// evicted orders are untagged, so registry doesn't grow beyond cache
+func TestInvalidateTagAfterEviction(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	const size = 10
+	repository, _ := setupWithClock(ctx, clock.NewFake(time.Unix(0, 0)))
+	registry := invalidation.New(invalidation.DefaultTags)
+	cache := lru.NewLRU[uint64, *order.Order](size, registry.Evicted, cacheTTL)
+	usecase := order_usecase_with_cache_through.New(
+		read_write_through.New(cache, repository, options.WithRegistry(registry)),
+	)
+
+	for i := 0; i < ordersNumber; i++ {
+		if err := usecase.Save(ctx, &order.Order{ID: uint64(i), CustomerID: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if invalidated := registry.InvalidateTag(invalidation.CustomerTag(1)); invalidated != size {
+		t.Fatalf("expected only %d cached orders to be tagged, got %d", size, invalidated)
+	}
+	if cache.Len() != 0 {
+		t.Fatalf("expected tagged orders to be removed from cache, got %d", cache.Len())
+	}
+}
+
+func setupQueryCache(
+	repository OrderRepoI,
+	cache *lru.LRU[uint64, *order.Order],
+	opts ...options.Option,
+) *order_usecase_with_query_cache.Usecase {
+	readWriteThroughCache := read_write_through.New(cache, repository, opts...)
+	queryCache := query_cache.New(
+		lru.NewLRU[string, []uint64](cacheSize, nil, cacheTTL),
+		readWriteThroughCache,
+		repository,
+		opts...,
+	)
+	pageCache := page_cache.New(
+		lru.NewLRU[string, *page_cache.Page](cacheSize, nil, cacheTTL),
+		queryCache,
+		repository,
+		opts...,
+	)
+
+	// page cache is the outermost layer, so saves invalidate both queries and pages
+	return order_usecase_with_query_cache.New(pageCache, queryCache, pageCache)
+}
+
+func pageOrders(
+	ctx context.Context,
+	t *testing.T,
+	expected int,
+	uc *order_usecase_with_query_cache.Usecase,
+) {
+	start := time.Now()
+
+	var (
+		cursor uint64
+		total  int
+	)
+	for {
+		orders, next, err := uc.List(ctx, cursor, pageSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ord := range orders {
+			if ord.ID != uint64(total) {
+				t.Fatalf("expected order %d, got %d", total, ord.ID)
+			}
+			total++
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if total != expected {
+		t.Fatalf("expected %d orders, got %d", expected, total)
+	}
+
+	elapsed := time.Since(start)
+	t.Logf("pageOrders timeout: %s", elapsed)
 }
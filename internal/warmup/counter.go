@@ -0,0 +1,103 @@
+package warmup
+
+import (
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type UsecaseI interface {
+	Get(ctx context.Context, IDs []uint64) ([]order.Order, error)
+	Save(ctx context.Context, order *order.Order) error
+	Delete(ctx context.Context, ID uint64) error
+}
+
+// Counter is a usecase decorator counting reads of every order, counts are written to file on stop,
+// so the next deploy preloads what was accessed most with CountsFile.
+// It's a lifecycle.Worker doing nothing but the write on stop.
+type Counter struct {
+	UsecaseI
+	path string
+
+	mu     sync.Mutex
+	counts map[uint64]int
+}
+
+func NewCounter(usecase UsecaseI, path string) *Counter {
+	return &Counter{
+		UsecaseI: usecase,
+		path:     path,
+		counts:   make(map[uint64]int),
+	}
+}
+
+func (c *Counter) Get(ctx context.Context, IDs []uint64) ([]order.Order, error) {
+	c.mu.Lock()
+	for _, ID := range IDs {
+		c.counts[ID]++
+	}
+	c.mu.Unlock()
+
+	return c.UsecaseI.Get(ctx, IDs)
+}
+
+// RemainingTTL forwards to the wrapped usecase, so counting doesn't hide its cache TTL from server,
+// false if the usecase has no cache
+func (c *Counter) RemainingTTL(ID uint64) (time.Duration, bool) {
+	provider, ok := c.UsecaseI.(interface {
+		RemainingTTL(ID uint64) (time.Duration, bool)
+	})
+	if !ok {
+		return 0, false
+	}
+	return provider.RemainingTTL(ID)
+}
+
+// SaveCounts writes counts to file, file is replaced atomically
+func (c *Counter) SaveCounts() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("access counts: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := WriteCounts(tmp, c.counts); err != nil {
+		return fmt.Errorf("access counts: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("access counts: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("access counts: %w", err)
+	}
+	return nil
+}
+
+// Run waits for stop, reads are counted by Get
+func (c *Counter) Run(ctx context.Context, stop <-chan struct{}) {
+	select {
+	case <-stop:
+	case <-ctx.Done():
+	}
+}
+
+// Drain writes counts, nothing is queued so nothing is dropped
+func (c *Counter) Drain(context.Context) (dropped int) {
+	if err := c.SaveCounts(); err != nil {
+		log.Err(err).Msg("access counts saving error")
+	}
+	return 0
+}
@@ -0,0 +1,159 @@
+package warmup
+
+import (
+	"bufio"
+	"caching-strategies/internal/accesstrace"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Source returns IDs to preload, the most accessed first
+type Source interface {
+	Keys(ctx context.Context) ([]uint64, error)
+}
+
+type static []uint64
+
+// Static preloads IDs in given order
+func Static(IDs ...uint64) Source {
+	return static(IDs)
+}
+
+func (s static) Keys(context.Context) ([]uint64, error) {
+	return s, nil
+}
+
+type traceFile string
+
+// TraceFile preloads IDs of access trace ordered by number of reads
+func TraceFile(path string) Source {
+	return traceFile(path)
+}
+
+func (s traceFile) Keys(ctx context.Context) ([]uint64, error) {
+	f, err := os.Open(string(s))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counts := make(map[uint64]int)
+	r := accesstrace.NewReader(f)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("trace %s: %w", s, err)
+		}
+		if record.Op != accesstrace.OpGet {
+			continue
+		}
+		for _, ID := range record.IDs {
+			counts[ID]++
+		}
+	}
+	return hottest(counts), nil
+}
+
+type countsFile string
+
+// CountsFile preloads IDs of access counts file written by Counter, the most accessed first
+func CountsFile(path string) Source {
+	return countsFile(path)
+}
+
+func (s countsFile) Keys(context.Context) ([]uint64, error) {
+	f, err := os.Open(string(s))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counts, err := ReadCounts(f)
+	if err != nil {
+		return nil, fmt.Errorf("counts %s: %w", s, err)
+	}
+	return hottest(counts), nil
+}
+
+// ParseSource parses source in form static:1,2,3, trace:orders.jsonl or counts:counts.txt
+func ParseSource(s string) (Source, error) {
+	kind, arg, _ := strings.Cut(s, ":")
+	if arg == "" {
+		return nil, fmt.Errorf("warmup source %q: expected kind:argument", s)
+	}
+
+	switch kind {
+	case "static":
+		var IDs []uint64
+		for _, field := range strings.Split(arg, ",") {
+			ID, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("warmup source %q: invalid id %q", s, field)
+			}
+			IDs = append(IDs, ID)
+		}
+		return Static(IDs...), nil
+	case "trace":
+		return TraceFile(arg), nil
+	case "counts":
+		return CountsFile(arg), nil
+	default:
+		return nil, fmt.Errorf("unknown warmup source %q, expected static, trace or counts", kind)
+	}
+}
+
+// ReadCounts reads access counts written by WriteCounts
+func ReadCounts(r io.Reader) (map[uint64]int, error) {
+	counts := make(map[uint64]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var ID uint64
+		var count int
+		if _, err := fmt.Sscanf(scanner.Text(), "%d %d", &ID, &count); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		counts[ID] += count
+	}
+	return counts, scanner.Err()
+}
+
+// WriteCounts writes access counts as "id count" lines, the most accessed first
+func WriteCounts(w io.Writer, counts map[uint64]int) error {
+	bw := bufio.NewWriter(w)
+	for _, ID := range hottest(counts) {
+		if _, err := fmt.Fprintf(bw, "%d %d\n", ID, counts[ID]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// hottest orders IDs by count descending, ties by ID so order is stable
+func hottest(counts map[uint64]int) []uint64 {
+	IDs := make([]uint64, 0, len(counts))
+	for ID := range counts {
+		IDs = append(IDs, ID)
+	}
+	sort.Slice(IDs, func(i, j int) bool {
+		if counts[IDs[i]] != counts[IDs[j]] {
+			return counts[IDs[i]] > counts[IDs[j]]
+		}
+		return IDs[i] < IDs[j]
+	})
+	return IDs
+}
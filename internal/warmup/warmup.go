@@ -0,0 +1,212 @@
+package warmup
+
+import (
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/repository/entity/order"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+type CacheInterface interface {
+	Contains(key uint64) bool
+	AddWithTTL(key uint64, value *order.Order, ttl time.Duration) (evicted bool)
+}
+
+type OrderRepoI interface {
+	Get(ctx context.Context, IDs []uint64) (map[uint64]order.Order, error)
+}
+
+// Policy limits how hard warm-up hits repository
+type Policy struct {
+	// Top IDs of the source are preloaded, 0 preloads all of them
+	Top int
+	// Batch IDs are loaded in a single Get
+	Batch int
+	// Rate is batches per second, 0 loads them back to back
+	Rate float64
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		Top:   1000,
+		Batch: 100,
+		Rate:  10,
+	}
+}
+
+func (p Policy) Validate() error {
+	if p.Top < 0 {
+		return fmt.Errorf("warmup top must not be negative, got %d", p.Top)
+	}
+	if p.Batch <= 0 {
+		return fmt.Errorf("warmup batch must be positive, got %d", p.Batch)
+	}
+	if p.Rate < 0 {
+		return fmt.Errorf("warmup rate must not be negative, got %g", p.Rate)
+	}
+	return nil
+}
+
+// Stats are progress of warm-up
+type Stats struct {
+	// Keys to preload, the top of the source
+	Keys int
+	// Cached were already in cache, e.g. restored from snapshot, and weren't loaded
+	Cached int
+	Loaded int
+	// Missing aren't in repository
+	Missing int
+	// Failed are IDs of batches failed to load, warm-up goes on without them
+	Failed int
+}
+
+// Warmer preloads the most accessed orders into cache in rate-limited batches,
+// Ready is closed when warm-up is over, so serving can wait for it
+type Warmer struct {
+	source          Source
+	cache           CacheInterface
+	orderRepository OrderRepoI
+	ttl             time.Duration
+	policy          Policy
+	clock           clock.Clock
+
+	ready chan struct{}
+	once  sync.Once
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// Option configures Warmer
+type Option func(*Warmer)
+
+// WithClock replaces real clock of rate limit and expiration
+func WithClock(c clock.Clock) Option {
+	return func(w *Warmer) {
+		w.clock = c
+	}
+}
+
+func New(
+	source Source,
+	cache CacheInterface,
+	orderRepository OrderRepoI,
+	ttl time.Duration,
+	policy Policy,
+	opts ...Option,
+) (*Warmer, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	w := &Warmer{
+		source:          source,
+		cache:           cache,
+		orderRepository: orderRepository,
+		ttl:             ttl,
+		policy:          policy,
+		clock:           clock.Real(),
+		ready:           make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w, nil
+}
+
+// Ready is closed when warm-up is over, successfully or not
+func (w *Warmer) Ready() <-chan struct{} {
+	return w.ready
+}
+
+func (w *Warmer) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.stats
+}
+
+// Run preloads keys of the source until all of them are loaded or ctx is done,
+// failed batches are logged and skipped, error is returned only if source fails or ctx is done
+func (w *Warmer) Run(ctx context.Context) (Stats, error) {
+	defer w.once.Do(func() { close(w.ready) })
+
+	keys, err := w.source.Keys(ctx)
+	if err != nil {
+		return Stats{}, fmt.Errorf("warmup source: %w", err)
+	}
+	if w.policy.Top > 0 && len(keys) > w.policy.Top {
+		keys = keys[:w.policy.Top]
+	}
+	w.update(func(s *Stats) { s.Keys = len(keys) })
+
+	var interval time.Duration
+	if w.policy.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / w.policy.Rate)
+	}
+
+	batch := make([]uint64, 0, w.policy.Batch)
+	loaded := 0
+	for i, ID := range keys {
+		if w.cache.Contains(ID) {
+			w.update(func(s *Stats) { s.Cached++ })
+		} else {
+			batch = append(batch, ID)
+		}
+		if len(batch) < w.policy.Batch && i < len(keys)-1 {
+			continue
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		if loaded > 0 {
+			if err := w.clock.SleepContext(ctx, interval); err != nil {
+				return w.Stats(), err
+			}
+		}
+		if err := w.load(ctx, batch); err != nil {
+			return w.Stats(), err
+		}
+		loaded++
+		batch = batch[:0]
+	}
+	return w.Stats(), nil
+}
+
+// load returns error only when ctx is done
+func (w *Warmer) load(ctx context.Context, IDs []uint64) error {
+	ordersMap, err := w.orderRepository.Get(ctx, IDs)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Err(err).Int("ids", len(IDs)).Msg("warmup batch error")
+		w.update(func(s *Stats) { s.Failed += len(IDs) })
+		return nil
+	}
+
+	now := w.clock.Now()
+	for _, ord := range ordersMap {
+		ord := ord
+		if w.ttl > 0 {
+			ord.ExpiredAt = now.Add(w.ttl)
+		}
+		_ = w.cache.AddWithTTL(ord.ID, &ord, w.ttl)
+	}
+	w.update(func(s *Stats) {
+		s.Loaded += len(ordersMap)
+		s.Missing += len(IDs) - len(ordersMap)
+	})
+	return nil
+}
+
+func (w *Warmer) update(fn func(s *Stats)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	fn(&w.stats)
+}
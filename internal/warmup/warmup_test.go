@@ -0,0 +1,149 @@
+package warmup
+
+import (
+	"bytes"
+	"caching-strategies/internal/accesstrace"
+	"caching-strategies/internal/clock"
+	"caching-strategies/internal/lru"
+	repo "caching-strategies/internal/repository"
+	"caching-strategies/internal/repository/entity/order"
+	order_usecase "caching-strategies/internal/usecases/0_without_cache"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+const ordersNumber = 200
+
+func TestWarmer(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Unix(0, 0))
+	repository := repo.New(repo.WithClock(fake))
+	for i := 0; i < ordersNumber; i++ {
+		if _, err := repository.Save(ctx, &order.Order{ID: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache := lru.NewLRU[uint64, *order.Order](0, nil, time.Minute, lru.WithClock(fake))
+	// restored from snapshot
+	cache.Add(0, &order.Order{ID: 0})
+
+	// 250 IDs, 50 of them missing, the last 10 are beyond the top
+	IDs := make([]uint64, 260)
+	for i := range IDs {
+		IDs[i] = uint64(i)
+	}
+	w, err := New(Static(IDs...), cache, repository, time.Minute, Policy{Top: 250, Batch: 100, Rate: 10}, WithClock(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-w.Ready():
+		t.Fatal("expected warmer not to be ready before run")
+	default:
+	}
+
+	start := fake.Now()
+	stats, err := w.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-w.Ready()
+
+	if stats != (Stats{Keys: 250, Cached: 1, Loaded: 199, Missing: 50}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if cache.Len() != ordersNumber {
+		t.Fatalf("expected %d cached orders, got %d", ordersNumber, cache.Len())
+	}
+	// 3 batches wait for 2 intervals of 100ms, the rest is repository latency mock
+	if elapsed := fake.Since(start); elapsed < 200*time.Millisecond || elapsed >= 300*time.Millisecond+250*time.Millisecond {
+		t.Fatalf("expected batches to be rate limited, took %s", elapsed)
+	}
+	if ord, _ := cache.Peek(1); !ord.ExpiredAt.After(fake.Now()) {
+		t.Fatalf("expected preloaded order to have expiration, got %+v", ord)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	w, _ = New(Static(300, 301), cache, repository, time.Minute, DefaultPolicy(), WithClock(fake))
+	if _, err := w.Run(cancelled); err == nil {
+		t.Fatal("expected cancelled warm-up to fail")
+	}
+	<-w.Ready()
+}
+
+func TestSources(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	tracePath := filepath.Join(dir, "trace.jsonl")
+	f, err := os.Create(tracePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := accesstrace.NewWriter(f)
+	for _, record := range []accesstrace.Record{
+		{Op: accesstrace.OpGet, IDs: []uint64{3, 1}},
+		{Op: accesstrace.OpGet, IDs: []uint64{1}},
+		{Op: accesstrace.OpSave, IDs: []uint64{2}},
+		{Op: accesstrace.OpGet, IDs: []uint64{2}},
+	} {
+		if err := tw.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	// reads are counted by usecase decorator and written on stop
+	countsPath := filepath.Join(dir, "counts.txt")
+	counter := NewCounter(order_usecase.New(repo.New(repo.WithClock(clock.NewFake(time.Unix(0, 0))))), countsPath)
+	for _, IDs := range [][]uint64{{5, 6, 7}, {6, 7}, {7, 6}} {
+		if _, err := counter.Get(ctx, IDs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dropped := counter.Drain(ctx); dropped != 0 {
+		t.Fatalf("expected nothing dropped, got %d", dropped)
+	}
+
+	for spec, expected := range map[string][]uint64{
+		"static:4,2,9":       {4, 2, 9},
+		"trace:" + tracePath: {1, 2, 3},
+		// ties are ordered by ID
+		"counts:" + countsPath: {6, 7, 5},
+	} {
+		source, err := ParseSource(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, err := source.Keys(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(keys, expected) {
+			t.Fatalf("%s: expected %v, got %v", spec, expected, keys)
+		}
+	}
+
+	for _, spec := range []string{"static:", "static:a", "redis:keys"} {
+		if _, err := ParseSource(spec); err == nil {
+			t.Fatalf("expected %q to be rejected", spec)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCounts(&buf, map[uint64]int{1: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if counts, err := ReadCounts(&buf); err != nil || counts[1] != 2 {
+		t.Fatalf("expected counts to round trip, got %v, %v", counts, err)
+	}
+}
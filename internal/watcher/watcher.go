@@ -1,10 +1,14 @@
 package watcher
 
 import (
+	"caching-strategies/internal/cache_implementations/options"
+	"caching-strategies/internal/lifecycle"
 	"caching-strategies/internal/repository/entity/order"
+	"caching-strategies/internal/tracing"
 	"context"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,6 +19,7 @@ const (
 type CacheInterface[K uint64, V *order.Order] interface {
 	Get(key K) (value *order.Order, ok bool)
 	Add(key K, value *order.Order) (evicted bool)
+	AddWithTTL(key K, value *order.Order, ttl time.Duration) (evicted bool)
 }
 
 type OrderRepoI interface {
@@ -25,76 +30,145 @@ type OrderRepoI interface {
 type CacheRefresh struct {
 	cache           CacheInterface[uint64, *order.Order]
 	orderRepository OrderRepoI
-	refreshCh       <-chan uint64
+	refreshQueue    *lifecycle.Queue[uint64]
 	cacheTTL        time.Duration
+	opts            options.Options
+	// dropped are refreshes taken from queue and interrupted by ctx
+	dropped atomic.Int64
 }
 
 func New(
 	cache CacheInterface[uint64, *order.Order],
 	orderRepository OrderRepoI,
-	refreshCh <-chan uint64,
+	refreshQueue *lifecycle.Queue[uint64],
 	cacheTTL time.Duration,
+	opts ...options.Option,
 ) *CacheRefresh {
 	return &CacheRefresh{
 		cache:           cache,
 		orderRepository: orderRepository,
-		refreshCh:       refreshCh,
+		refreshQueue:    refreshQueue,
 		cacheTTL:        cacheTTL,
+		opts:            options.New(opts...),
 	}
 }
 
+// Start refreshes queued orders until ctx is done, refresh in flight is cancelled with ctx
 func (c *CacheRefresh) Start(ctx context.Context) {
-	ticker := time.NewTicker(watchTimeout)
+	c.Run(ctx, ctx.Done())
+}
+
+// Run refreshes queued orders until stop is closed, refresh in flight is finished unless ctx is done
+func (c *CacheRefresh) Run(ctx context.Context, stop <-chan struct{}) {
+	ticker := c.opts.Clock.NewTicker(watchTimeout)
 	defer ticker.Stop()
 
-	// проверяем канал refreshCh c периодичностью watchTimeout
+	// проверяем очередь обновления c периодичностью watchTimeout
 	for {
 		select {
+		case <-stop:
+			return
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			if len(c.refreshCh) > 0 {
-				chLen := len(c.refreshCh)
-				IDs := make([]uint64, 0, chLen)
+		case <-ticker.C():
+			c.Tick(ctx)
+		}
+	}
+}
 
-				// читаем из канала IDs которые нужно обновить в кэше
-				for i := 0; i < chLen; i++ {
-					IDs = append(IDs, <-c.refreshCh)
-				}
+// Tick refreshes orders queued so far, Run calls it every watchTimeout,
+// tests with fake clock call it directly to refresh synchronously
+func (c *CacheRefresh) Tick(ctx context.Context) {
+	c.opts.Metrics.RefreshQueueDepth(c.refreshQueue.Len())
 
-				c.refresh(ctx, IDs)
-			}
+	// читаем из очереди IDs которые нужно обновить в кэше
+	IDs := c.refreshQueue.Batch()
+	if len(IDs) == 0 {
+		return
+	}
+
+	if err := c.refresh(ctx, IDs); err != nil && ctx.Err() != nil {
+		c.dropped.Add(int64(len(IDs)))
+	}
+}
+
+// Drain rejects new refreshes and refreshes queued ones until ctx is done,
+// orders which were not refreshed, including ones interrupted earlier, are reported as dropped
+func (c *CacheRefresh) Drain(ctx context.Context) (dropped int) {
+	c.refreshQueue.Close()
+
+	for {
+		IDs := c.refreshQueue.Batch()
+		if len(IDs) == 0 {
+			return int(c.dropped.Load())
+		}
+		if err := c.refresh(ctx, IDs); err != nil && ctx.Err() != nil {
+			return int(c.dropped.Load()) + len(IDs) + len(c.refreshQueue.Batch())
 		}
 	}
 }
 
-func (c *CacheRefresh) refresh(ctx context.Context, IDs []uint64) {
-	start := time.Now()
+// refresh returns error only to tell whether orders were refreshed, it's logged and recorded here
+func (c *CacheRefresh) refresh(ctx context.Context, IDs []uint64) error {
+	ctx, span := tracing.Start(ctx, "watcher.refresh")
+	defer span.End()
+	span.SetAttribute("ids", len(IDs))
+
+	// orders deleted or saved while loading aren't written back
+	generation := c.opts.Generations.Begin()
+	defer c.opts.Generations.End()
+
+	start := c.opts.Clock.Now()
 
 	ordersMap, err := c.orderRepository.Get(ctx, IDs)
+	c.opts.Metrics.Refresh(len(ordersMap), c.opts.Clock.Since(start), err)
 	if err != nil {
-		log.Err(err).Msg("watcher.refresh error")
-		return
+		span.RecordError(err)
+		if ctx.Err() == nil {
+			log.Err(err).Msg("watcher.refresh error")
+		}
+		return err
+	}
+
+	// watcher was stopped while loading, stale results aren't written
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+		return err
 	}
 
-	g := errgroup.Group{}
+	loadCost := c.opts.Clock.Since(start) / time.Duration(len(IDs))
+
+	g, gctx := errgroup.WithContext(ctx)
 	g.SetLimit(100)
 
 	// обновляем хэш
 	for _, ord := range ordersMap {
 		ord := ord
-		ord.ExpiredAt = time.Now().Add(c.cacheTTL)
+		ord.ExpiredAt = c.opts.Clock.Now().Add(c.cacheTTL)
 
 		g.Go(func() error {
-			_ = c.cache.Add(ord.ID, &ord)
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+
+			if c.opts.Generations.AddIfValid(ord.ID, generation, func() {
+				_ = c.opts.TTLPolicy.Add(c.cache, ord.ID, &ord, loadCost)
+			}) {
+				c.opts.Registry.Tag(&ord)
+			}
 
 			return nil
 		})
 	}
-	_ = g.Wait()
+	if err := g.Wait(); err != nil {
+		span.RecordError(err)
+		return err
+	}
 
 	log.Info().
 		Int("count", len(ordersMap)).
-		Str("elapsed time", time.Since(start).String()).
+		Str("elapsed time", c.opts.Clock.Since(start).String()).
 		Msg("refresh orders in cache")
+
+	return nil
 }
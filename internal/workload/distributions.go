@@ -0,0 +1,154 @@
+package workload
+
+import (
+	"math"
+	"math/rand"
+)
+
+type uniform struct {
+	rnd *rand.Rand
+	n   uint64
+}
+
+// Uniform picks every key with the same probability, the worst case for any cache
+func Uniform(rnd *rand.Rand, n uint64) Generator {
+	return &uniform{rnd: rnd, n: n}
+}
+
+func (g *uniform) Next() uint64 {
+	return intn(g.rnd, g.n)
+}
+
+type zipf struct {
+	rnd *rand.Rand
+	n   uint64
+
+	// skew > 1 is handled by math/rand
+	std *rand.Zipf
+
+	// skew < 1, "Quickly generating billion-record synthetic databases", Gray et al.
+	theta, zetan, alpha, eta float64
+}
+
+// Zipf picks key k with probability proportional to 1/(k+1)^skew,
+// so small keys are hot, skew must be positive and not equal to 1
+func Zipf(rnd *rand.Rand, n uint64, skew float64) Generator {
+	g := &zipf{rnd: rnd, n: n, theta: skew}
+	if skew > 1 {
+		g.std = rand.NewZipf(rnd, skew, 1, n-1)
+		return g
+	}
+
+	for i := uint64(1); i <= n; i++ {
+		g.zetan += 1 / math.Pow(float64(i), skew)
+	}
+	zeta2 := 1 + math.Pow(0.5, skew)
+	g.alpha = 1 / (1 - skew)
+	g.eta = (1 - math.Pow(2/float64(n), 1-skew)) / (1 - zeta2/g.zetan)
+
+	return g
+}
+
+func (g *zipf) Next() uint64 {
+	if g.std != nil {
+		return g.std.Uint64()
+	}
+	if g.n == 1 {
+		return 0
+	}
+
+	u := g.rnd.Float64()
+	uz := u * g.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, g.theta) {
+		return 1
+	}
+
+	key := uint64(float64(g.n) * math.Pow(g.eta*u-g.eta+1, g.alpha))
+	if key >= g.n {
+		return g.n - 1
+	}
+	return key
+}
+
+type scan struct {
+	n, next uint64
+}
+
+// Scan walks keys sequentially and starts over, the best case for LRU
+// while keys fit in cache and the worst one once they don't
+func Scan(n uint64) Generator {
+	return &scan{n: n}
+}
+
+func (g *scan) Next() uint64 {
+	key := g.next
+	g.next = (g.next + 1) % g.n
+	return key
+}
+
+type hotSet struct {
+	rnd            *rand.Rand
+	n, size        uint64
+	hotProbability float64
+	// shiftEvery == 0 keeps hot set in place
+	shiftEvery int
+	requests   int
+}
+
+// Hotspot sends hotProbability of requests to the first size keys, the rest are uniform
+func Hotspot(rnd *rand.Rand, n, size uint64, hotProbability float64) Generator {
+	return &hotSet{rnd: rnd, n: n, size: size, hotProbability: hotProbability}
+}
+
+// ShiftingHotSet is Hotspot that moves its hot set to the next size keys every shiftEvery requests,
+// cache has to forget old hot keys to keep hit ratio
+func ShiftingHotSet(rnd *rand.Rand, n, size uint64, hotProbability float64, shiftEvery int) Generator {
+	return &hotSet{rnd: rnd, n: n, size: size, hotProbability: hotProbability, shiftEvery: shiftEvery}
+}
+
+func (g *hotSet) Next() uint64 {
+	var start uint64
+	if g.shiftEvery > 0 {
+		start = uint64(g.requests/g.shiftEvery) * g.size % g.n
+	}
+	g.requests++
+
+	if g.rnd.Float64() < g.hotProbability {
+		return (start + intn(g.rnd, g.size)) % g.n
+	}
+	return intn(g.rnd, g.n)
+}
+
+type bursty struct {
+	rnd           *rand.Rand
+	n, size       uint64
+	period, burst int
+	requests      int
+	start         uint64
+}
+
+// Bursty is uniform except the first burst requests of every period,
+// they go to size keys starting from a random one
+func Bursty(rnd *rand.Rand, n, size uint64, period, burst int) Generator {
+	return &bursty{rnd: rnd, n: n, size: size, period: period, burst: burst}
+}
+
+func (g *bursty) Next() uint64 {
+	phase := g.requests % g.period
+	g.requests++
+
+	if phase >= g.burst {
+		return intn(g.rnd, g.n)
+	}
+	if phase == 0 {
+		g.start = intn(g.rnd, g.n)
+	}
+	return (g.start + intn(g.rnd, g.size)) % g.n
+}
+
+func intn(rnd *rand.Rand, n uint64) uint64 {
+	return uint64(rnd.Int63n(int64(n)))
+}
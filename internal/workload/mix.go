@@ -0,0 +1,40 @@
+package workload
+
+import "math/rand"
+
+// Op is a read of Keys batch or a write of the single key
+type Op struct {
+	Write bool
+	Keys  []uint64
+}
+
+// Mix turns key stream into reads and writes, like Generator it isn't safe for concurrent use
+type Mix struct {
+	keys      Generator
+	rnd       *rand.Rand
+	readRatio float64
+	batchSize int
+}
+
+// NewMix reads batchSize keys with readRatio probability, otherwise writes one key,
+// rnd isn't used and may be nil if readRatio is 1
+func NewMix(keys Generator, rnd *rand.Rand, readRatio float64, batchSize int) *Mix {
+	return &Mix{
+		keys:      keys,
+		rnd:       rnd,
+		readRatio: readRatio,
+		batchSize: batchSize,
+	}
+}
+
+func (m *Mix) Next() Op {
+	if m.readRatio < 1 && m.rnd.Float64() >= m.readRatio {
+		return Op{Write: true, Keys: []uint64{m.keys.Next()}}
+	}
+
+	keys := make([]uint64, m.batchSize)
+	for i := range keys {
+		keys[i] = m.keys.Next()
+	}
+	return Op{Keys: keys}
+}
@@ -0,0 +1,143 @@
+package workload
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+const (
+	DistributionUniform  = "uniform"
+	DistributionZipf     = "zipf"
+	DistributionHotspot  = "hotspot"
+	DistributionScan     = "scan"
+	DistributionShifting = "shifting"
+	DistributionBursty   = "bursty"
+)
+
+// Distributions are all supported key distributions
+var Distributions = []string{
+	DistributionUniform,
+	DistributionZipf,
+	DistributionHotspot,
+	DistributionScan,
+	DistributionShifting,
+	DistributionBursty,
+}
+
+// Generator produces keys in range [0, n), generators aren't safe for concurrent use,
+// every client should have its own
+type Generator interface {
+	Next() uint64
+}
+
+// Spec describes key distribution, only parameters of the chosen distribution are used
+type Spec struct {
+	Distribution string
+	Keys         uint64
+
+	// Skew of zipf distribution, must be positive and not equal to 1, bigger is more skewed
+	Skew float64
+	// HotFraction is a share of keys in hot set of hotspot, shifting and bursty distributions
+	HotFraction float64
+	// HotProbability is a share of requests to hot set of hotspot and shifting distributions
+	HotProbability float64
+	// ShiftEvery is a number of requests after which shifting hot set moves to the next keys
+	ShiftEvery int
+	// BurstPeriod and BurstLength: first BurstLength requests of every BurstPeriod
+	// go to a random hot set, the rest are uniform
+	BurstPeriod int
+	BurstLength int
+}
+
+func DefaultSpec(keys uint64) Spec {
+	return Spec{
+		Distribution:   DistributionUniform,
+		Keys:           keys,
+		Skew:           0.99,
+		HotFraction:    0.1,
+		HotProbability: 0.9,
+		ShiftEvery:     10000,
+		BurstPeriod:    10000,
+		BurstLength:    1000,
+	}
+}
+
+// RegisterFlags binds distribution parameters to command line flags, key count is left to the command
+func (s *Spec) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.Distribution, "distribution", s.Distribution, "key distribution: "+strings.Join(Distributions, ", "))
+	fs.Float64Var(&s.Skew, "skew", s.Skew, "zipf skew")
+	fs.Float64Var(&s.HotFraction, "hot-fraction", s.HotFraction,
+		"share of keys in hot set of hotspot, shifting and bursty distributions")
+	fs.Float64Var(&s.HotProbability, "hot-probability", s.HotProbability,
+		"share of requests to hot set of hotspot and shifting distributions")
+	fs.IntVar(&s.ShiftEvery, "shift-every", s.ShiftEvery, "requests of a client before shifting hot set moves")
+	fs.IntVar(&s.BurstPeriod, "burst-period", s.BurstPeriod, "requests of a client between starts of bursts")
+	fs.IntVar(&s.BurstLength, "burst-length", s.BurstLength, "requests of a client in burst")
+}
+
+func (s Spec) Validate() error {
+	if s.Keys == 0 {
+		return fmt.Errorf("keys must be positive")
+	}
+
+	switch s.Distribution {
+	case DistributionUniform, DistributionScan:
+	case DistributionZipf:
+		if s.Skew <= 0 || s.Skew == 1 {
+			return fmt.Errorf("zipf skew must be positive and not equal to 1, got %g", s.Skew)
+		}
+	case DistributionHotspot, DistributionShifting, DistributionBursty:
+		if s.HotFraction <= 0 || s.HotFraction > 1 {
+			return fmt.Errorf("hot fraction must be in range (0, 1], got %g", s.HotFraction)
+		}
+		if s.Distribution == DistributionBursty {
+			if s.BurstPeriod <= 0 || s.BurstLength < 0 || s.BurstLength > s.BurstPeriod {
+				return fmt.Errorf("burst length must be in range [0, period], got %d of %d", s.BurstLength, s.BurstPeriod)
+			}
+			break
+		}
+		if s.HotProbability < 0 || s.HotProbability > 1 {
+			return fmt.Errorf("hot probability must be in range [0, 1], got %g", s.HotProbability)
+		}
+		if s.Distribution == DistributionShifting && s.ShiftEvery <= 0 {
+			return fmt.Errorf("shift interval must be positive, got %d", s.ShiftEvery)
+		}
+	default:
+		return fmt.Errorf("unknown distribution %q, expected one of %v", s.Distribution, Distributions)
+	}
+
+	return nil
+}
+
+// New creates generator of the spec distribution using rnd as a source of randomness
+func (s Spec) New(rnd *rand.Rand) (Generator, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch s.Distribution {
+	case DistributionZipf:
+		return Zipf(rnd, s.Keys, s.Skew), nil
+	case DistributionHotspot:
+		return Hotspot(rnd, s.Keys, s.hotSize(), s.HotProbability), nil
+	case DistributionScan:
+		return Scan(s.Keys), nil
+	case DistributionShifting:
+		return ShiftingHotSet(rnd, s.Keys, s.hotSize(), s.HotProbability, s.ShiftEvery), nil
+	case DistributionBursty:
+		return Bursty(rnd, s.Keys, s.hotSize(), s.BurstPeriod, s.BurstLength), nil
+	default:
+		return Uniform(rnd, s.Keys), nil
+	}
+}
+
+func (s Spec) hotSize() uint64 {
+	size := uint64(math.Ceil(s.HotFraction * float64(s.Keys)))
+	if size == 0 {
+		return 1
+	}
+	return size
+}
@@ -0,0 +1,158 @@
+package workload
+
+import (
+	"math/rand"
+	"testing"
+)
+
+const (
+	keys     = 1000
+	requests = 100000
+)
+
+func histogram(g Generator, n int) map[uint64]int {
+	counts := make(map[uint64]int)
+	for i := 0; i < n; i++ {
+		key := g.Next()
+		if key >= keys {
+			panic("key out of range")
+		}
+		counts[key]++
+	}
+	return counts
+}
+
+// share of requests to keys in [from, to)
+func share(counts map[uint64]int, from, to uint64) float64 {
+	var hits, total int
+	for key, count := range counts {
+		total += count
+		if key >= from && key < to {
+			hits += count
+		}
+	}
+	return float64(hits) / float64(total)
+}
+
+func TestDistributions(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	uniform := histogram(Uniform(rnd, keys), requests)
+	if len(uniform) != keys {
+		t.Fatalf("expected every key to be requested, got %d", len(uniform))
+	}
+	if s := share(uniform, 0, keys/10); s < 0.08 || s > 0.12 {
+		t.Fatalf("expected 10%% of keys to get about 10%% of uniform requests, got %.3f", s)
+	}
+
+	for _, skew := range []float64{0.99, 1.2} {
+		counts := histogram(Zipf(rnd, keys, skew), requests)
+		if s := share(counts, 0, keys/10); s < 0.6 {
+			t.Fatalf("skew %g: expected 10%% of keys to get most requests, got %.3f", skew, s)
+		}
+		if counts[0] <= counts[1] || counts[1] <= counts[10] {
+			t.Fatalf("skew %g: expected frequency to fall with key, got %d, %d, %d", skew, counts[0], counts[1], counts[10])
+		}
+	}
+
+	hotspot := histogram(Hotspot(rnd, keys, keys/10, 0.9), requests)
+	if s := share(hotspot, 0, keys/10); s < 0.88 || s > 0.93 {
+		t.Fatalf("expected hot set to get about 91%% of requests, got %.3f", s)
+	}
+
+	scan := Scan(3)
+	for i, expected := range []uint64{0, 1, 2, 0, 1} {
+		if key := scan.Next(); key != expected {
+			t.Fatalf("scan step %d: expected %d, got %d", i, expected, key)
+		}
+	}
+}
+
+func TestShiftingHotSet(t *testing.T) {
+	g := ShiftingHotSet(rand.New(rand.NewSource(1)), keys, 100, 1, 1000)
+
+	first := histogram(g, 1000)
+	second := histogram(g, 1000)
+	if share(first, 0, 100) != 1 || share(second, 100, 200) != 1 {
+		t.Fatalf("expected hot set to move after 1000 requests, got %.3f and %.3f",
+			share(first, 0, 100), share(second, 100, 200))
+	}
+
+	// hot set wraps around
+	histogram(g, 8000)
+	if wrapped := histogram(g, 1000); share(wrapped, 0, 100) != 1 {
+		t.Fatalf("expected hot set to wrap around, got %.3f", share(wrapped, 0, 100))
+	}
+}
+
+func TestBursty(t *testing.T) {
+	g := Bursty(rand.New(rand.NewSource(1)), keys, 10, 1000, 100)
+
+	for period := 0; period < 10; period++ {
+		burst := histogram(g, 100)
+		if len(burst) > 10 {
+			t.Fatalf("expected burst to request at most 10 keys, got %d", len(burst))
+		}
+		if quiet := histogram(g, 900); len(quiet) < 300 {
+			t.Fatalf("expected uniform requests between bursts, got %d keys", len(quiet))
+		}
+	}
+}
+
+func TestSpec(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, distribution := range Distributions {
+		spec := DefaultSpec(keys)
+		spec.Distribution = distribution
+		g, err := spec.New(rnd)
+		if err != nil {
+			t.Fatalf("%s: %v", distribution, err)
+		}
+		histogram(g, 1000)
+	}
+
+	invalid := []Spec{
+		{Distribution: DistributionUniform},
+		{Distribution: "gauss", Keys: keys},
+		{Distribution: DistributionZipf, Keys: keys, Skew: 1},
+		{Distribution: DistributionHotspot, Keys: keys, HotFraction: 0},
+		{Distribution: DistributionShifting, Keys: keys, HotFraction: 0.1, HotProbability: 0.9},
+		{Distribution: DistributionBursty, Keys: keys, HotFraction: 0.1, BurstPeriod: 10, BurstLength: 20},
+	}
+	for _, spec := range invalid {
+		if _, err := spec.New(rnd); err == nil {
+			t.Fatalf("expected error for %+v", spec)
+		}
+	}
+}
+
+func TestMix(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	mix := NewMix(Uniform(rnd, keys), rnd, 0.8, 5)
+
+	var reads, writes int
+	for i := 0; i < requests; i++ {
+		op := mix.Next()
+		if op.Write {
+			writes++
+			if len(op.Keys) != 1 {
+				t.Fatalf("expected write of one key, got %d", len(op.Keys))
+			}
+			continue
+		}
+		reads++
+		if len(op.Keys) != 5 {
+			t.Fatalf("expected read of 5 keys, got %d", len(op.Keys))
+		}
+	}
+
+	if ratio := float64(reads) / requests; ratio < 0.78 || ratio > 0.82 {
+		t.Fatalf("expected 80%% of reads, got %.3f", ratio)
+	}
+
+	// read only mix doesn't need randomness
+	if op := NewMix(Scan(keys), nil, 1, 1).Next(); op.Write || op.Keys[0] != 0 {
+		t.Fatalf("unexpected op of read only mix: %+v", op)
+	}
+}